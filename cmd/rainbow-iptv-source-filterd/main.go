@@ -1,21 +1,30 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
+	_ "time/tzdata" // embedded time zone database for the timezone of the update time channel
 
 	"github.com/rambollwong/rainbow-iptv-source-filter/conf"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/filex"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/logx"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/sourcex"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/txtx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
 	"github.com/rambollwong/rainbowcat/pool"
 	"github.com/rambollwong/rainbowcat/util"
 	"github.com/rambollwong/rainbowlog/log"
@@ -30,6 +39,13 @@ const (
 	ExtTxt  = ".txt"
 	ExtM3u8 = ".m3u8"
 	ExtM3u  = ".m3u"
+	ExtJson = ".json"
+	ExtGz   = ".gz"
+	ExtTgz  = ".tgz"
+	ExtTar  = ".tar"
+	ExtZip  = ".zip"
+
+	FormatTxt = "txt"
 )
 
 func main() {
@@ -63,18 +79,25 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed to initialize config").Done()
 	}
 	if conf.Config.CustomUA != "" {
-		httpx.UA = conf.Config.CustomUA
 		log.Info().Msg("Use global custom UA.").Str("ua", conf.Config.CustomUA).Done()
 	}
 	if len(conf.Config.HostCustomUA) > 0 {
 		log.Info().Msg("Use host custom UA.").Any("host_custom_ua", conf.Config.HostCustomUA).Done()
 	}
+	if len(conf.Config.HeaderProfiles) > 0 {
+		log.Info().Msg("Use header profiles.").Int("header_profiles", len(conf.Config.HeaderProfiles)).Done()
+	}
+	urlx.SetDefaultCanonicalizer(urlx.NewCanonicalizer(conf.Config.IgnoredQueryParams))
+	fetchClient, testClient, err := newClients()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize http clients").Done()
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	workerPool := pool.NewWorkerPool(int(conf.Config.ParallelExecutorNum), pool.WithContext(ctx))
 	defer workerPool.Close()
 
-	go mainLogic(ctx, cancel, workerPool)
+	go mainLogic(ctx, cancel, workerPool, fetchClient, testClient)
 
 	// Graceful shutdown
 	go func() {
@@ -92,135 +115,206 @@ func main() {
 	<-ctx.Done()
 }
 
-func mainLogic(ctx context.Context, cancel context.CancelFunc, workerPool *pool.WorkerPool) {
+// newClients creates the client fetching sources and EPGs, which rewrites urls by the rewrite rules,
+// and the client testing channel streams, with the UAs, header profiles and network profiles of the config.
+func newClients() (fetchClient, testClient *httpx.Client, err error) {
+	urlRewriter, err := httpx.NewUrlRewriter(conf.Config.UrlRewriteRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	headerProfiles, err := httpx.NewHeaderProfiles(conf.Config.HostHeaderProfiles())
+	if err != nil {
+		return nil, nil, err
+	}
+	fetchHttpClient, err := httpx.NewHttpClient(conf.Config.Network.GetFetch())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid fetch network: %w", err)
+	}
+	testHttpClient, err := httpx.NewHttpClient(conf.Config.Network.GetTest())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid test network: %w", err)
+	}
+	// the limiter is shared, so that fetching and testing never exceed the limits of a host together
+	hostLimiter := httpx.NewHostLimiter(conf.Config.HostLimits)
+	fetchClient = httpx.NewClient(
+		httpx.WithHttpClient(fetchHttpClient),
+		httpx.WithUA(conf.Config.CustomUA),
+		httpx.WithHeaderProfiles(headerProfiles),
+		httpx.WithUrlRewriter(urlRewriter),
+		httpx.WithHostLimiter(hostLimiter),
+		httpx.WithTimeouts(httpx.NewTimeouts(conf.Config.Network.GetFetch().GetTimeouts())),
+	)
+	testClient = httpx.NewClient(
+		httpx.WithHttpClient(testHttpClient),
+		httpx.WithUA(conf.Config.CustomUA),
+		httpx.WithHeaderProfiles(headerProfiles),
+		httpx.WithHostLimiter(hostLimiter),
+		httpx.WithTimeouts(httpx.NewTimeouts(conf.Config.Network.GetTest().GetTimeouts())),
+	)
+	return fetchClient, testClient, nil
+}
+
+func mainLogic(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	workerPool *pool.WorkerPool,
+	fetchClient, testClient *httpx.Client,
+) {
 	defer cancel()
+	startTime := time.Now()
 	// worker pool
 	log.Info().Int64("parallel_executor_num", conf.Config.ParallelExecutorNum).Done()
 	wg := &sync.WaitGroup{}
 
-	newFilteredSources := make([]*m3u8x.ProgramListSource, 0, 16)
-	newFilteredSourcesMutex := &sync.Mutex{}
-
 	localPath := conf.Config.ProgramListSourceFileLocalPath
 	groupList := conf.Config.GroupList
+	var files []string
 	if localPath != "" {
 		// search local files
-		log.Info().Msg("Searching local m3u/m3u8/txt files...").Str("path", localPath).Done()
-		files, err := filex.SearchFilesBySuffix(localPath, ExtM3u8, ExtM3u, ExtTxt)
+		log.Info().Msg("Searching local m3u/m3u8/txt/json files and archives...").Str("path", localPath).Done()
+		var err error
+		files, err = filex.SearchFilesBySuffix(localPath, ExtM3u8, ExtM3u, ExtTxt, ExtJson, ExtGz, ExtTgz, ExtTar, ExtZip)
 		if err != nil {
 			log.Error().Msg("Failed to search files, ignore").Err(err).Done()
 		}
 		if len(files) > 0 {
 			log.Info().Msg("Found files").Strs("files", files...).Done()
 		}
-		for _, file := range files {
-			wg.Add(1)
-			taskFunc := func() {
-				defer wg.Done()
-
-				var fileBytes []byte
-				var newSource *m3u8x.ProgramListSource
-				readFile := func(file string) {
-					fileBytes, err = os.ReadFile(file)
-					if err != nil {
-						log.Error().Msg("Failed to read file, ignore").Str("file", file).Err(err).Done()
-						return
-					}
-					log.Debug().Msg("Loaded local file.").Str("file", file).Done()
-				}
-
-				switch path.Ext(file) {
-				case ExtM3u8, ExtM3u:
-					log.Info().Msg("Processing local m3u/m3u8 file...").Str("file", file).Done()
-					readFile(file)
-					if err != nil {
-						return
-					}
-
-					//parse file to source
-					newSource = m3u8x.NewProgramListSource()
-					if err := newSource.ParseProgramListSource(fileBytes); err != nil {
-						log.Error().Msg("Failed to parse file, ignore").Str("file", file).Err(err).Done()
-						return
-					}
-					log.Debug().Msg("Parsed local file.").Str("file", file).Done()
-				case ExtTxt:
-					log.Info().Msg("Processing local txt file...").Str("file", file).Done()
-					readFile(file)
-					if err != nil {
-						return
-					}
-
-					tncs := txtx.NewTvgNameChannels()
-					tncs.ParseTxt(fileBytes)
-					newSource = txtx.ToM3u(tncs)
-					if len(newSource.TvgNameChannels) == 0 {
-						log.Info().Msg("No channels found in txt file, ignore").Str("file", file).Done()
-						return
-					}
-				default:
-					log.Info().Msg("Unknown file type, ignore").Str("file", file).Done()
-					return
-				}
-
-				m3u8x.FilterTvgNameOfSource(newSource, groupList)
-				newFilteredSourcesMutex.Lock()
-				newFilteredSources = append(newFilteredSources, newSource)
-				newFilteredSourcesMutex.Unlock()
-			}
-			err := workerPool.Submit(taskFunc)
+	}
+	sourceUrls := conf.Config.ProgramListSourceUrls
+	var mirror *httpx.Mirror
+	if conf.Config.SourceMirrorDir != "" {
+		mirror = httpx.NewMirror(fetchClient, conf.Config.SourceMirrorDir)
+	}
+	xtreamSources := conf.Config.XtreamSources
+	// retry policies of each phase, retryTimes is the default retry times of all of them
+	retryPolicies := conf.Config.RetryPolicies
+	sourceRetry := httpx.NewRetryPolicy(retryPolicies.GetSource(), conf.Config.RetryTimes)
+	startupLatency := conf.Config.StartupLatency
+	minResolutions, err := m3u8x.MinResolutionsOf(groupList)
+	if err != nil {
+		log.Fatal().Msg("Invalid min resolution of the group list.").Err(err).Done()
+	}
+	speedHeadroom, err := m3u8x.SpeedHeadroomOf(conf.Config.SpeedThreshold)
+	if err != nil {
+		log.Fatal().Msg("Invalid speed threshold.").Err(err).Done()
+	}
+	if err := m3u8x.ValidateUpdateTimeChannel(conf.Config.UpdateTimeChannel); err != nil {
+		log.Fatal().Msg("Invalid update time channel.").Err(err).Done()
+	}
+	if err := m3u8x.ValidateSlateDetection(conf.Config.SlateDetection); err != nil {
+		log.Fatal().Msg("Invalid slate detection.").Err(err).Done()
+	}
+	outputs := conf.Config.Outputs
+	if len(outputs) == 0 {
+		outputs = []*proto.Output{{File: conf.Config.OutputFile}}
+	}
+	for _, output := range outputs {
+		if err := m3u8x.ValidateOutput(output, groupList); err != nil {
+			log.Fatal().Msg("Invalid output.").Str("output_file", output.File).Err(err).Done()
+		}
+	}
+	testOptions := &m3u8x.TestOptions{
+		Retry: m3u8x.TestRetryPolicies{
+			Playlist: httpx.NewRetryPolicy(retryPolicies.GetPlaylist(), conf.Config.RetryTimes),
+			Segment:  httpx.NewRetryPolicy(retryPolicies.GetSegment(), conf.Config.RetryTimes),
+		},
+		MaxStartupLatency: time.Duration(startupLatency.GetMaxMs()) * time.Millisecond,
+		MaxFirstFrame:     time.Duration(startupLatency.GetMaxFirstFrameMs()) * time.Millisecond,
+		MinResolutions:    minResolutions,
+		SpeedHeadroom:     speedHeadroom,
+		MinSpeeds:         m3u8x.MinSpeedsOf(groupList),
+		Slate:             m3u8x.NewSlateDetector(conf.Config.SlateDetection),
+		// EPGs are checked on the fetch network like sources, e.g. through its proxy
+		EpgClient: fetchClient,
+	}
+	loadUrl := func(ctx context.Context, url string) ([]byte, error) {
+		return fetchClient.LoadUrlContentWithRetry(ctx, url, sourceRetry)
+	}
+	openUrl := func(ctx context.Context, url string) (io.ReadCloser, error) {
+		return fetchClient.OpenUrlWithRetry(ctx, url, sourceRetry)
+	}
+	sourcex.Register(sourcex.FormatJson, sourcex.NewTvboxParser(openUrl))
+	// filter the channels while parsing, so that large sources are never held in memory entirely
+	parseOptions := &m3u8x.ParseOptions{
+		MaxLineLength: int(conf.Config.MaxLineLength),
+		Filter:        m3u8x.NewChannelFilter(groupList, conf.Config.LineLabels),
+	}
+
+	// Each task stores its sources at its declaration index, local files first, then remote urls
+	// and xtream sources, so that the merging order does not depend on the order in which tasks complete.
+	// A task may produce several sources if its file is an archive.
+	parsedSources := make([][]*m3u8x.ProgramListSource, len(files)+len(sourceUrls)+len(xtreamSources))
+	for i, file := range files {
+		wg.Add(1)
+		taskFunc := func() {
+			defer wg.Done()
+			log.Info().Msg("Processing local file...").Str("file", file).Done()
+			f, err := os.Open(file)
 			if err != nil {
-				log.Debug().Err(err).Msg("Failed to submit task func").Done()
+				log.Error().Msg("Failed to open file, ignore").Str("file", file).Err(err).Done()
 				return
 			}
+			log.Debug().Msg("Opened local file.").Str("file", file).Done()
 
+			newSources := parseSources(ctx, file, f, parseOptions)
+			for _, newSource := range newSources {
+				newSource.Priority = conf.Config.SourcePriority(file)
+			}
+			parsedSources[i] = newSources
+		}
+		err := workerPool.Submit(taskFunc)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to submit task func").Done()
+			return
 		}
 	}
 
-	sourceUrls := conf.Config.ProgramListSourceUrls
 	if len(sourceUrls) > 0 {
 		log.Info().Msg("Testing remote m3u/m3u8/txt files...").Strs("urls", sourceUrls...).Done()
 	}
-	for _, sourceUrl := range sourceUrls {
+	for i, sourceUrl := range sourceUrls {
 		wg.Add(1)
 		taskFunc := func() {
 			defer wg.Done()
-			log.Info().Msg("Processing remote m3u/m3u8/txt file...").Str("url", sourceUrl).Done()
-			sourceContent, err := httpx.LoadUrlContentWithRetry(ctx, sourceUrl, conf.Config.RetryTimes)
+			log.Info().Msg("Processing remote file...").Str("url", sourceUrl).Done()
+			body, stale, err := openSourceUrl(ctx, fetchClient, mirror, sourceRetry, sourceUrl)
 			if err != nil {
 				log.Error().Msg("Failed to load url, ignore").Str("url", sourceUrl).Err(err).Done()
 				return
 			}
-			log.Debug().Msg("Loaded url content").Str("url", sourceUrl).Done()
-
-			var newSource *m3u8x.ProgramListSource
-			if strings.HasPrefix(string(sourceContent), "#") {
-				// m3u/m3u8
-				log.Info().Msg("Parsing remote m3u/m3u8 file...").Str("url", sourceUrl).Done()
-				// parse url to source
-				newSource = m3u8x.NewProgramListSource()
-				if err := newSource.ParseProgramListSource(sourceContent); err != nil {
-					log.Error().Msg("Failed to parse url, ignore").Str("url", sourceUrl).Err(err).Done()
-					return
-				}
-				log.Debug().Msg("Parsed url content").Str("url", sourceUrl).Done()
-			} else {
-				// txt
-				log.Info().Msg("Parsing remote txt file...").Str("url", sourceUrl).Done()
-				// parse url to source
-				tncs := txtx.NewTvgNameChannels()
-				tncs.ParseTxt(sourceContent)
-				newSource = txtx.ToM3u(tncs)
-				if len(newSource.TvgNameChannels) == 0 {
-					log.Info().Msg("No channels found in remote txt file, ignore").Str("url", sourceUrl).Done()
-					return
-				}
-			}
+			log.Debug().Msg("Opened url").Str("url", sourceUrl).Done()
 
-			m3u8x.FilterTvgNameOfSource(newSource, groupList)
-			newFilteredSourcesMutex.Lock()
-			newFilteredSources = append(newFilteredSources, newSource)
-			newFilteredSourcesMutex.Unlock()
+			newSources := parseSources(ctx, sourceUrl, body, parseOptions)
+			for _, newSource := range newSources {
+				newSource.Priority = conf.Config.SourcePriority(sourceUrl)
+				newSource.Stale = stale
+			}
+			parsedSources[len(files)+i] = newSources
+		}
+		err := workerPool.Submit(taskFunc)
+		if err != nil {
+			log.Debug().Err(err).Msg("Failed to submit task func").Done()
+			return
+		}
+	}
+	for i, xs := range xtreamSources {
+		wg.Add(1)
+		taskFunc := func() {
+			defer wg.Done()
+			name := sourcex.XtreamSourceName(xs)
+			log.Info().Msg("Processing xtream source...").Str("source", name).Done()
+			newSource, err := sourcex.LoadXtreamSource(ctx, loadUrl, xs)
+			if err != nil {
+				log.Error().Msg("Failed to load xtream source, ignore").Str("source", name).Err(err).Done()
+				return
+			}
+			newSource = filterSource(name, newSource)
+			if newSource == nil {
+				return
+			}
+			newSource.Priority = xs.Priority
+			parsedSources[len(files)+len(sourceUrls)+i] = []*m3u8x.ProgramListSource{newSource}
 		}
 		err := workerPool.Submit(taskFunc)
 		if err != nil {
@@ -228,8 +322,13 @@ func mainLogic(ctx context.Context, cancel context.CancelFunc, workerPool *pool.
 			return
 		}
 	}
+
 	// wait all tasks done
 	wg.Wait()
+	newFilteredSources := make([]*m3u8x.ProgramListSource, 0, len(parsedSources))
+	for _, sources := range parsedSources {
+		newFilteredSources = append(newFilteredSources, sources...)
+	}
 
 	// merge all filtered sources
 	mergedSource := m3u8x.MergeProgramListSources(newFilteredSources)
@@ -238,38 +337,245 @@ func mainLogic(ctx context.Context, cancel context.CancelFunc, workerPool *pool.
 	// test merged source
 	targetSource := m3u8x.ParallelTestProgramListSource(
 		ctx,
+		testClient,
 		mergedSource,
 		conf.Config.TestPingMinLatency,
 		conf.Config.TestLoadMinSpeed,
-		conf.Config.RetryTimes,
-		workerPool, groupList, conf.Config.HostCustomUA)
+		testOptions,
+		workerPool, groupList)
 	log.Info().Msg("All source tests are completed.").Done()
 
-	// fix channel group
+	// report of the slate streams
+	if slateDetection := conf.Config.SlateDetection; slateDetection.GetEnable() {
+		slateReports := testOptions.Slate.Reports()
+		log.Info().Msg("Slate streams detected.").Int("slate_urls", len(slateReports)).Done()
+		if slateDetection.ReportFile != "" {
+			reportBz, err := json.MarshalIndent(slateReports, "", "  ")
+			if err == nil {
+				err = filex.WriteBytesToFile(reportBz, slateDetection.ReportFile)
+			}
+			if err != nil {
+				log.Error().Msg("Failed to write slate report file, ignore.").
+					Str("slate_report_file", slateDetection.ReportFile).Err(err).Done()
+			}
+		}
+	}
+
+	// statistics of each source
+	sourceStats := m3u8x.NewSourceStats(newFilteredSources, targetSource)
+	for _, stat := range sourceStats {
+		log.Info().Msg("Source statistics.").
+			Str("source", stat.Source).
+			Int("contributed", stat.Contributed).
+			Int("surviving", stat.Surviving).
+			Int("unique", stat.Unique).
+			Any("stale", stat.Stale).
+			Int64("avg_ttfb_ms", stat.AvgTTFBMs).
+			Done()
+	}
+	if conf.Config.SourceStatsFile != "" {
+		statsBz, err := json.MarshalIndent(sourceStats, "", "  ")
+		if err == nil {
+			err = filex.WriteBytesToFile(statsBz, conf.Config.SourceStatsFile)
+		}
+		if err != nil {
+			log.Error().Msg("Failed to write source statistics file, ignore.").
+				Str("source_stats_file", conf.Config.SourceStatsFile).Err(err).Done()
+		}
+	}
+
+	// fix channel group and sort channels by the startup latency and then the preferred line labels,
+	// so that the preferred line labels come first
+	m3u8x.SortChannelsByStartupLatency(targetSource, time.Duration(startupLatency.GetRankBucketMs())*time.Millisecond)
+	m3u8x.SortChannelsByLineLabelPreference(targetSource, conf.Config.LineLabels.GetPrefer())
 	m3u8x.FixChannelGroup(targetSource, groupList)
 
-	// output to the result file
+	// output to the result files
+	updateTimeChannel := conf.Config.UpdateTimeChannel
+	if updateTimeChannel.GetEnable() && updateTimeChannel.Url == "" && updateTimeChannel.PlaceholderFile != "" {
+		err := filex.WriteBytesToFile(m3u8x.OutputPlaceholderM3u8Bz(), updateTimeChannel.PlaceholderFile)
+		if err != nil {
+			log.Fatal().Msg("Failed to write placeholder file.").
+				Str("placeholder_file", updateTimeChannel.PlaceholderFile).Err(err).Done()
+		}
+	}
+	for _, output := range outputs {
+		if err := writeOutput(testClient, targetSource, groupList, output, startTime); err != nil {
+			log.Fatal().Msg("Failed to write to file.").Str("output_file", output.File).Err(err).Done()
+		}
+	}
+	log.Info().Msg("All done.").Done()
+}
+
+// openSourceUrl opens the content of a remote url with the retry policy, through the mirror if it is not nil.
+// It reports whether the content is the last mirrored copy because the url could not be fetched.
+func openSourceUrl(
+	ctx context.Context,
+	client *httpx.Client,
+	mirror *httpx.Mirror,
+	retry *httpx.RetryPolicy,
+	sourceUrl string,
+) (io.ReadCloser, bool, error) {
+	if mirror == nil {
+		body, err := client.OpenUrlWithRetry(ctx, sourceUrl, retry)
+		return body, false, err
+	}
+	body, result, err := mirror.Open(ctx, sourceUrl, retry)
+	if err != nil {
+		return nil, false, err
+	}
+	switch {
+	case result.Stale:
+		log.Warn().Msg("Failed to load url, use the last mirrored copy.").
+			Str("url", sourceUrl).
+			Str("fetched_at", result.Meta.FetchedAt.Format(time.DateTime)).
+			Err(result.Err).
+			Done()
+	case result.NotModified:
+		log.Info().Msg("Url is not modified, use the mirrored copy.").
+			Str("url", sourceUrl).
+			Str("fetched_at", result.Meta.FetchedAt.Format(time.DateTime)).
+			Done()
+	}
+	return body, result.Stale, nil
+}
+
+// parseSources streams the content of a local file or remote url to parseSource,
+// or expands it and parses each playlist in it by parseSource if it is an archive.
+// Archives are read entirely, since their members can only be located in the whole content.
+// Members of archives are named by the archive followed by "!" and their path in the archive.
+// The content is closed once it is read, before loading any url referenced by it.
+func parseSources(
+	ctx context.Context,
+	name string,
+	rc io.ReadCloser,
+	opts *m3u8x.ParseOptions,
+) []*m3u8x.ProgramListSource {
+	br := bufio.NewReader(rc)
+	// the magic number of tar archives is at offset 257
+	head, _ := br.Peek(512)
+	if !sourcex.IsArchive(head) {
+		if source := parseSource(ctx, name, readCloser{Reader: br, Closer: rc}, opts); source != nil {
+			return []*m3u8x.ProgramListSource{source}
+		}
+		return nil
+	}
+
+	content, err := io.ReadAll(br)
+	_ = rc.Close()
+	if err != nil {
+		log.Error().Msg("Failed to read archive, ignore").Str("source", name).Err(err).Done()
+		return nil
+	}
+	members, err := sourcex.ExpandArchive(name, content)
+	if err != nil {
+		log.Error().Msg("Failed to expand archive, ignore").Str("source", name).Err(err).Done()
+		return nil
+	}
+	if len(members) > 1 || (len(members) == 1 && members[0].Name != name) {
+		log.Info().Msg("Expanded archive.").Str("source", name).Int("members", len(members)).Done()
+	}
+	sources := make([]*m3u8x.ProgramListSource, 0, len(members))
+	for _, member := range members {
+		if source := parseSource(ctx, member.Name, io.NopCloser(bytes.NewReader(member.Content)), opts); source != nil {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// readCloser reads from the reader and closes the closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// parseSource detects the format of the content of a local file or remote url and parses it
+// as it is read, then filters it by filterSource. The content is closed once it is read.
+// It returns nil if the content could not be parsed or no channel is found.
+func parseSource(ctx context.Context, name string, rc io.ReadCloser, opts *m3u8x.ParseOptions) *m3u8x.ProgramListSource {
+	newSource, format, err := sourcex.ParseReadCloser(ctx, name, rc, opts)
+	if err != nil {
+		log.Error().Msg("Failed to parse source, ignore").
+			Str("source", name).
+			Str("format", string(format)).
+			Err(err).
+			Done()
+		return nil
+	}
+	log.Debug().Msg("Parsed source.").Str("source", name).Str("format", string(format)).Done()
+	return filterSource(name, newSource)
+}
+
+// filterSource records the origin of the channels of a parsed source and filters them
+// by the group list and line labels. It returns nil if no channel is found.
+func filterSource(name string, newSource *m3u8x.ProgramListSource) *m3u8x.ProgramListSource {
+	if len(newSource.TvgNameChannels) == 0 {
+		log.Info().Msg("No channels found in source, ignore").Str("source", name).Done()
+		return nil
+	}
+
+	newSource.SetChannelSource(name)
+	m3u8x.FilterTvgNameOfSource(newSource, conf.Config.GroupList)
+	m3u8x.FilterLineLabelOfSource(newSource, conf.Config.LineLabels)
+	return newSource
+}
+
+// writeOutput writes the channels of the groups selected by the output to its file in the output format.
+// The request headers of the channel urls are those the client testing them sends.
+func writeOutput(
+	client *httpx.Client,
+	targetSource *m3u8x.ProgramListSource,
+	groupList []*proto.GroupList,
+	output *proto.Output,
+	startTime time.Time,
+) error {
+	outputSource, outputGroupList := m3u8x.SelectOutputSource(targetSource, groupList, output)
+	now := time.Now()
+	updateTimeChannels, err := m3u8x.NewUpdateTimeChannels(
+		conf.Config.UpdateTimeChannel,
+		m3u8x.NewUpdateSummary(outputSource, now, now.Sub(startTime)),
+	)
+	if err != nil {
+		return err
+	}
+	outputOptions := &m3u8x.OutputOptions{
+		UpdateTimeChannels: updateTimeChannels,
+		SourceAttribute:    output.SourceAttribute,
+		HeaderFormat:       strings.ToLower(output.HeaderFormat),
+		Resolution:         strings.ToLower(output.Resolution),
+		Header:             client.ProfileHeader,
+	}
+
+	outputFile := path.Join(output.File)
+	format := strings.ToLower(output.Format)
+	if format == "" {
+		format = strings.TrimPrefix(path.Ext(outputFile), ".")
+	}
 	log.Info().Msg("Writing the final source to the file...").
-		Str("output_file", conf.Config.OutputFile).
+		Str("output_file", outputFile).
+		Str("format", format).
+		Strs("groups", output.Groups...).
 		Done()
 
-	outputFile := path.Join(conf.Config.OutputFile)
 	var outputBz []byte
-	switch path.Ext(outputFile) {
-	case ExtTxt:
-		outputBz = txtx.OutputTvgNameChannelsToTxtBz(txtx.FromM3u(targetSource), groupList)
+	switch format {
+	case FormatTxt:
+		outputBz = txtx.OutputTvgNameChannelsToTxtBz(txtx.FromM3u(outputSource), outputGroupList, outputOptions)
+		if path.Ext(outputFile) != ExtTxt {
+			outputFile += ExtTxt
+		}
 	default:
-		outputBz = m3u8x.OutputProgramListSourceToM3u8Bz(targetSource, groupList)
+		outputBz = m3u8x.OutputProgramListSourceToM3u8Bz(outputSource, outputGroupList, outputOptions)
 		if !util.SliceContains([]string{ExtM3u8, ExtM3u}, path.Ext(outputFile)) {
 			outputFile += ExtM3u
 		}
 	}
-	err := filex.WriteBytesToFile(outputBz, outputFile)
-	if err != nil {
-		log.Fatal().Msg("Failed to write to file.").Err(err).Done()
+	if err := filex.WriteBytesToFile(outputBz, outputFile); err != nil {
+		return err
 	}
-	log.Info().Msg("The file writing is completed.").Done()
-	log.Info().Msg("All done.").Done()
+	log.Info().Msg("The file writing is completed.").Str("output_file", outputFile).Done()
+	return nil
 }
 
 func printLogo() {
@@ -295,7 +601,7 @@ Options:
   -h, --help             Show this help message
   -c, --config.path      Config file path (default "./conf")
   -l, --local-path       Path of local program list source file
-  -o, --output           Output file path
+  -o, --output           Output file path, replaces the outputs in the config file
 
 Description:
   This tool filters and processes IPTV source lists in M3U8 format. It can read from local files or remote URLs, test stream availability, and generate a merged, filtered output.
@@ -2,12 +2,16 @@ package conf
 
 import (
 	"path"
+	"slices"
+	"sort"
 	"strings"
 
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
 	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
 	"github.com/rambollwong/rainbowlog/log"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	protobuf "google.golang.org/protobuf/proto"
 )
 
 var (
@@ -49,7 +53,9 @@ func InitConfig() error {
 		pConf.ProgramListSourceFileLocalPath = path.Join(localPath)
 	}
 	if outputPath != "" {
+		// the output file specified by flag replaces all outputs in the config file
 		pConf.OutputFile = path.Join(outputPath)
+		pConf.Outputs = nil
 	}
 
 	Config = &config{
@@ -68,3 +74,43 @@ func InitConfig() error {
 
 	return nil
 }
+
+// SourcePriority returns the priority of an upstream url or local file.
+// The source is matched against the configured sources exactly or as a path.Match pattern,
+// and the first match wins. Sources without any match have priority 0.
+func (c *config) SourcePriority(source string) int64 {
+	for _, sp := range c.SourcePriorities {
+		if sp.Source == source {
+			return sp.Priority
+		}
+		if matched, err := path.Match(sp.Source, source); err == nil && matched {
+			return sp.Priority
+		}
+	}
+	return 0
+}
+
+// HostHeaderProfiles returns the profiles of the host custom UAs followed by the configured header profiles,
+// so that a host custom UA is never shadowed by an overlapping header profile, as only the first matching
+// profile is used. The profile of a host custom UA keeps the other headers of the first configured profile
+// matching its host, with the User-Agent replaced.
+func (c *config) HostHeaderProfiles() []*proto.HeaderProfile {
+	hosts := make([]string, 0, len(c.HostCustomUA))
+	for host := range c.HostCustomUA {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	profiles := make([]*proto.HeaderProfile, 0, len(hosts)+len(c.Config.HeaderProfiles))
+	for _, host := range hosts {
+		profile := &proto.HeaderProfile{}
+		if i := slices.IndexFunc(c.Config.HeaderProfiles, func(p *proto.HeaderProfile) bool {
+			return urlx.MatchHost(p.Host, host)
+		}); i >= 0 {
+			profile = protobuf.Clone(c.Config.HeaderProfiles[i]).(*proto.HeaderProfile)
+		}
+		profile.Host = host
+		profile.UserAgent = c.HostCustomUA[host]
+		profiles = append(profiles, profile)
+	}
+	return append(profiles, c.Config.HeaderProfiles...)
+}
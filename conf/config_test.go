@@ -0,0 +1,41 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+func requireProfiles(t *testing.T, expected, actual []*proto.HeaderProfile) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.True(t, protobuf.Equal(expected[i], actual[i]), "%d: expected %v, actual %v", i, expected[i], actual[i])
+	}
+}
+
+func TestConfig_HostHeaderProfiles(t *testing.T) {
+	c := &config{
+		Config: &proto.Config{HeaderProfiles: []*proto.HeaderProfile{
+			{Host: "*.example.com", UserAgent: "profile-ua", Referer: "http://www.example.com/"},
+			{Host: "*", Headers: []string{"X-Any: 1"}},
+		}},
+		HostCustomUA: map[string]string{"live.example.com": "custom-ua", "1.2.3.4:8080": "ip-ua"},
+	}
+
+	// the host custom UAs come first and keep the other headers of the overlapping profiles
+	requireProfiles(t, []*proto.HeaderProfile{
+		{Host: "1.2.3.4:8080", UserAgent: "ip-ua", Headers: []string{"X-Any: 1"}},
+		{Host: "live.example.com", UserAgent: "custom-ua", Referer: "http://www.example.com/"},
+		{Host: "*.example.com", UserAgent: "profile-ua", Referer: "http://www.example.com/"},
+		{Host: "*", Headers: []string{"X-Any: 1"}},
+	}, c.HostHeaderProfiles())
+	require.Equal(t, "profile-ua", c.Config.HeaderProfiles[0].UserAgent)
+
+	c.Config.HeaderProfiles = nil
+	requireProfiles(t, []*proto.HeaderProfile{
+		{Host: "1.2.3.4:8080", UserAgent: "ip-ua"},
+		{Host: "live.example.com", UserAgent: "custom-ua"},
+	}, c.HostHeaderProfiles())
+}
@@ -0,0 +1,72 @@
+package filex
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// DefaultMaxLineLength is the max line length used if none is configured.
+const DefaultMaxLineLength = 1 << 20 // 1MB
+
+// LineReader reads lines from a reader one by one without loading the whole content.
+// Unlike bufio.Scanner, which stops at the first line longer than its buffer,
+// it skips lines longer than the max line length and counts them, so that one bad line
+// does not silently drop the rest of a large playlist.
+type LineReader struct {
+	r             *bufio.Reader
+	maxLineLength int
+	lineNo        int
+	skipped       int
+}
+
+// NewLineReader creates a LineReader, maxLineLength <= 0 means DefaultMaxLineLength.
+func NewLineReader(r io.Reader, maxLineLength int) *LineReader {
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+	return &LineReader{
+		r:             bufio.NewReaderSize(r, 64*1024),
+		maxLineLength: maxLineLength,
+	}
+}
+
+// Next returns the next line without the line ending and its line number starting from 1.
+// It returns io.EOF if there is no more line.
+func (lr *LineReader) Next() (line string, lineNo int, err error) {
+	for {
+		var buf []byte
+		tooLong := false
+		for {
+			fragment, isPrefix, err := lr.r.ReadLine()
+			if err != nil {
+				if errors.Is(err, io.EOF) && (len(buf) > 0 || tooLong) {
+					break
+				}
+				return "", lr.lineNo, err
+			}
+			if !tooLong {
+				if len(buf)+len(fragment) > lr.maxLineLength {
+					tooLong, buf = true, nil
+				} else {
+					buf = append(buf, fragment...)
+				}
+			}
+			if !isPrefix {
+				break
+			}
+		}
+		lr.lineNo++
+		if tooLong {
+			lr.skipped++
+			continue
+		}
+		return string(bytes.TrimSuffix(buf, []byte("\r"))), lr.lineNo, nil
+	}
+}
+
+// Skipped returns the number of lines skipped because they are longer than the max line length.
+func (lr *LineReader) Skipped() int {
+	return lr.skipped
+}
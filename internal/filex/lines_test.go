@@ -0,0 +1,85 @@
+package filex
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readLines reads all lines and their line numbers from the LineReader until io.EOF.
+func readLines(t *testing.T, lr *LineReader) ([]string, []int) {
+	var lines []string
+	var lineNos []int
+	for {
+		line, lineNo, err := lr.Next()
+		if err == io.EOF {
+			return lines, lineNos
+		}
+		require.NoError(t, err)
+		lines = append(lines, line)
+		lineNos = append(lineNos, lineNo)
+	}
+}
+
+func TestLineReader(t *testing.T) {
+	t.Run("LF", func(t *testing.T) {
+		lr := NewLineReader(strings.NewReader("a\nb\n"), 0)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"a", "b"}, lines)
+		require.Equal(t, []int{1, 2}, lineNos)
+		require.Zero(t, lr.Skipped())
+	})
+
+	t.Run("CRLF", func(t *testing.T) {
+		lr := NewLineReader(strings.NewReader("a\r\nb\r\nc\n"), 0)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"a", "b", "c"}, lines)
+		require.Equal(t, []int{1, 2, 3}, lineNos)
+	})
+
+	t.Run("NoFinalNewline", func(t *testing.T) {
+		lr := NewLineReader(strings.NewReader("a\nb"), 0)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"a", "b"}, lines)
+		require.Equal(t, []int{1, 2}, lineNos)
+
+		lr = NewLineReader(strings.NewReader("a\r\nb\r"), 0)
+		lines, _ = readLines(t, lr)
+		require.Equal(t, []string{"a", "b"}, lines)
+	})
+
+	t.Run("EmptyLines", func(t *testing.T) {
+		lr := NewLineReader(strings.NewReader("\na\n\r\n\nb\n"), 0)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"", "a", "", "", "b"}, lines)
+		require.Equal(t, []int{1, 2, 3, 4, 5}, lineNos)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		lr := NewLineReader(strings.NewReader(""), 0)
+		lines, _ := readLines(t, lr)
+		require.Empty(t, lines)
+	})
+
+	t.Run("LongerThanBuffer", func(t *testing.T) {
+		// The line is read in several fragments from the 64KB buffer
+		long := strings.Repeat("a", 200*1024)
+		lr := NewLineReader(strings.NewReader("a\n"+long+"\r\nb\n"+long), 0)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"a", long, "b", long}, lines)
+		require.Equal(t, []int{1, 2, 3, 4}, lineNos)
+		require.Zero(t, lr.Skipped())
+	})
+
+	t.Run("MaxLineLength", func(t *testing.T) {
+		long := strings.Repeat("a", 200*1024)
+		content := "a\n" + long + "\n" + strings.Repeat("b", 10) + "\n" + strings.Repeat("c", 11) + "\nd\n" + long
+		lr := NewLineReader(strings.NewReader(content), 10)
+		lines, lineNos := readLines(t, lr)
+		require.Equal(t, []string{"a", strings.Repeat("b", 10), "d"}, lines)
+		require.Equal(t, []int{1, 3, 5}, lineNos)
+		require.Equal(t, 3, lr.Skipped())
+	})
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package httpx
+
+import (
+	"syscall"
+)
+
+// bindToInterface returns the dialer control function binding sockets to the network interface by SO_BINDTODEVICE,
+// which usually requires the CAP_NET_RAW capability.
+func bindToInterface(iface string) (func(network, address string, c syscall.RawConn) error, error) {
+	return func(network, address string, c syscall.RawConn) error {
+		var bindErr error
+		err := c.Control(func(fd uintptr) {
+			bindErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err != nil {
+			return err
+		}
+		return bindErr
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package httpx
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindToInterface is not supported on this platform, use the source ip instead.
+func bindToInterface(_ string) (func(network, address string, c syscall.RawConn) error, error) {
+	return nil, errors.New("binding to network interface is only supported on linux, use the source ip instead")
+}
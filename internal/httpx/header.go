@@ -0,0 +1,76 @@
+package httpx
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+// HeaderProfiles are the request headers of hosts, e.g. the Referer checked by CDNs.
+type HeaderProfiles struct {
+	profiles []*headerProfile
+}
+
+type headerProfile struct {
+	host   string
+	header http.Header
+}
+
+// NewHeaderProfiles creates HeaderProfiles from the config.
+// The extra headers of a profile are in the "Name: value" form, and the basic auth is sent if the
+// username is not empty.
+func NewHeaderProfiles(profiles []*proto.HeaderProfile) (*HeaderProfiles, error) {
+	hp := &HeaderProfiles{}
+	for _, profile := range profiles {
+		header := http.Header{}
+		for _, h := range profile.Headers {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("invalid header of host %s: %s", profile.Host, h)
+			}
+			header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		if profile.UserAgent != "" {
+			header.Set("User-Agent", profile.UserAgent)
+		}
+		if profile.Referer != "" {
+			header.Set("Referer", profile.Referer)
+		}
+		if profile.Origin != "" {
+			header.Set("Origin", profile.Origin)
+		}
+		if profile.Cookie != "" {
+			header.Set("Cookie", profile.Cookie)
+		}
+		if profile.Username != "" {
+			auth := base64.StdEncoding.EncodeToString([]byte(profile.Username + ":" + profile.Password))
+			header.Set("Authorization", "Basic "+auth)
+		}
+		hp.profiles = append(hp.profiles, &headerProfile{host: profile.Host, header: header})
+	}
+	return hp, nil
+}
+
+// Header returns a copy of the headers of the first profile matching the host of the url,
+// or nil if none matches. The host pattern of a profile (see urlx.MatchHost) is matched against
+// both the host name and the host with the port of the url.
+func (hp *HeaderProfiles) Header(rawUrl string) http.Header {
+	if hp == nil || len(hp.profiles) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return nil
+	}
+	for _, profile := range hp.profiles {
+		if urlx.MatchHost(profile.host, u.Hostname()) || urlx.MatchHost(profile.host, u.Host) {
+			return profile.header.Clone()
+		}
+	}
+	return nil
+}
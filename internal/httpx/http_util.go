@@ -2,142 +2,429 @@ package httpx
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/mediax"
 )
 
-var HttpClient = &http.Client{
-	Timeout: time.Second * 5,
-	Transport: &http.Transport{
+// DefaultUA is the User-Agent of requests if no custom UA is configured.
+const DefaultUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
+
+func newTransport(timeouts Timeouts) *http.Transport {
+	return &http.Transport{
+		DialContext:           newDialer(timeouts).DialContext,
 		MaxIdleConns:          100,
 		IdleConnTimeout:       90 * time.Second,
-		ResponseHeaderTimeout: time.Second * 10,
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
 		DisableCompression:    false,
 		Proxy:                 http.ProxyFromEnvironment,
-	},
+	}
+}
+
+// Client sends the requests of fetching sources and testing channel streams.
+// It carries the http client with its transport, the timeouts, the headers of each host and
+// the url rewriter, and is passed into the parsers and testers instead of using global state.
+type Client struct {
+	httpClient     *http.Client
+	ua             string
+	headerProfiles *HeaderProfiles
+	rewriter       *UrlRewriter
+	limiter        *HostLimiter
+	timeouts       Timeouts
 }
 
-var UA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
+// ClientOption is the option of NewClient.
+type ClientOption func(c *Client)
 
-// LoadUrlContent fetches content from the specified URL and returns it as a byte slice.
-// It handles HTTP request creation, execution, and response body reading.
-// Returns an error if the request fails or the status code is not OK (200).
-func LoadUrlContent(ctx context.Context, url string) (content []byte, err error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// WithHttpClient sets the http client sending the requests, see NewHttpClient.
+func WithHttpClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithUA sets the User-Agent of requests, the empty UA means DefaultUA.
+func WithUA(ua string) ClientOption {
+	return func(c *Client) {
+		if ua != "" {
+			c.ua = ua
+		}
+	}
+}
+
+// WithHeaderProfiles sets the headers of requests to the hosts, which override the default ones.
+func WithHeaderProfiles(headerProfiles *HeaderProfiles) ClientOption {
+	return func(c *Client) {
+		c.headerProfiles = headerProfiles
+	}
+}
+
+// WithUrlRewriter sets the UrlRewriter used by LoadUrlContentWithRetry and OpenUrlWithRetry.
+// It should only be set for the client fetching sources and EPGs, never for the one testing channel streams.
+func WithUrlRewriter(rewriter *UrlRewriter) ClientOption {
+	return func(c *Client) {
+		c.rewriter = rewriter
+	}
+}
+
+// WithHostLimiter sets the HostLimiter every request of the client waits for.
+// The same limiter should be shared by the clients fetching sources and testing channel streams.
+func WithHostLimiter(limiter *HostLimiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithTimeouts sets the first-byte, request and test timeouts of the client.
+// The dial, TLS handshake and response header timeouts are those of the transport of the http client,
+// see NewHttpClient.
+func WithTimeouts(timeouts Timeouts) ClientOption {
+	return func(c *Client) {
+		c.timeouts = timeouts
+	}
+}
+
+// NewClient creates a Client, which uses DefaultUA, the default timeouts and the proxy of the environment by default.
+func NewClient(opts ...ClientOption) *Client {
+	timeouts := NewTimeouts(nil)
+	c := &Client{
+		httpClient: &http.Client{Transport: newTransport(timeouts)},
+		ua:         DefaultUA,
+		timeouts:   timeouts,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Timeouts returns the timeouts of the client.
+func (c *Client) Timeouts() Timeouts {
+	return c.timeouts
+}
+
+// MaxConcurrency returns the max number of concurrent requests to the host limited by the HostLimiter
+// of the client, 0 if unlimited.
+func (c *Client) MaxConcurrency(host string) int {
+	return c.limiter.MaxConcurrency(host)
+}
+
+// ProfileHeader returns the headers of the header profile matching the url, or nil if none matches.
+func (c *Client) ProfileHeader(rawUrl string) http.Header {
+	return c.headerProfiles.Header(rawUrl)
+}
+
+// NewRequest creates a request accepting any content with the User-Agent of the client,
+// and the headers of the header profile matching the url, which override the default ones.
+func (c *Client) NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", UA)
+	req.Header.Set("User-Agent", c.ua)
 	req.Header.Set("Accept", "*/*")
+	for key, values := range c.ProfileHeader(url) {
+		req.Header[key] = values
+	}
+	return req, nil
+}
 
-	resp, err := HttpClient.Do(req)
+// Do sends the request by the http client of the client.
+// The request is canceled if it is not completed within the request timeout, including reading the body,
+// or the first byte of the body is not received within the first-byte timeout after the response headers.
+// Waiting for the host limiter before sending the request is limited by the request timeout separately.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.do(req, c.timeouts.Request, 0)
+}
+
+// DoStream sends the request like Do but without the request timeout, for the body read as a stream
+// for a duration limited by the caller, e.g. a speed test.
+func (c *Client) DoStream(req *http.Request) (*http.Response, error) {
+	return c.do(req, 0, 0)
+}
+
+// do sends the request canceled after the timeout if it is positive,
+// or when no data of the body is received within the idle timeout if it is positive.
+func (c *Client) do(req *http.Request, timeout, idleTimeout time.Duration) (*http.Response, error) {
+	release, err := c.acquire(req)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	// the time waiting for the limiter is not part of the request timeout
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Context())
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		release()
+		return nil, err
+	}
+	c.limiter.Observe(req.URL.Host, resp)
+	resp.Body = &releaseBody{ReadCloser: newTimedBody(resp.Body, c.timeouts.FirstByte, idleTimeout, cancel), release: release}
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed, status code: %d", resp.StatusCode)
+// acquire waits for the limiter to send the request to its host, at most for the request timeout,
+// so that a request waiting for a host whose requests are all held never hangs.
+func (c *Client) acquire(req *http.Request) (release func(), err error) {
+	ctx := req.Context()
+	if c.timeouts.Request > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeouts.Request)
+		defer cancel()
+	}
+	release, err = c.limiter.Acquire(ctx, req.URL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("wait for the limit of host %s: %w", req.URL.Host, err)
 	}
+	return release, nil
+}
 
-	return io.ReadAll(resp.Body)
+// LoadUrlContent fetches content from the specified URL and returns it as a byte slice.
+// It handles HTTP request creation, execution, and response body reading.
+// Returns an error if the request fails or the status code is not OK (200).
+func (c *Client) LoadUrlContent(ctx context.Context, url string) (content []byte, err error) {
+	body, err := c.open(ctx, url, false)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
 }
 
-// LoadUrlContentWithRetry attempts to fetch content from the specified URL with multiple retries.
-// It uses LoadUrlContent internally and retries the request up to retryTimes if errors occur.
+// LoadUrlContentWithRetry attempts to fetch content from the specified URL, retrying transient failures
+// by the retry policy, then falls back to the next candidate url rewritten by the UrlRewriter of the client if any.
 // Returns the content if any attempt succeeds, otherwise returns the last error encountered.
-func LoadUrlContentWithRetry(ctx context.Context, url string, retryTimes int64) (content []byte, err error) {
-	for i := int64(0); i < retryTimes; i++ {
-		content, err = LoadUrlContent(ctx, url)
+func (c *Client) LoadUrlContentWithRetry(ctx context.Context, url string, retry *RetryPolicy) (content []byte, err error) {
+	for _, candidate := range c.rewriter.Candidates(url) {
+		err = retry.Do(ctx, func(ctx context.Context) error {
+			content, err = c.LoadUrlContent(ctx, candidate)
+			return err
+		})
 		if err == nil {
 			return content, nil
 		}
+		if ctx.Err() != nil {
+			break
+		}
 	}
 	return nil, err
 }
 
+// OpenUrl sends a GET request to the specified URL and returns the response body
+// to be read as a stream, which must be closed by the caller. Reading the body is not limited
+// by the request timeout, but it fails if no data is received within the idle read timeout.
+// Returns an error if the request fails or the status code is not OK (200).
+func (c *Client) OpenUrl(ctx context.Context, url string) (body io.ReadCloser, err error) {
+	return c.open(ctx, url, true)
+}
+
+// open sends a GET request to the specified URL and returns the response body, see get for the stream.
+func (c *Client) open(ctx context.Context, url string, stream bool) (body io.ReadCloser, err error) {
+	resp, err := c.get(ctx, url, nil, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp.Body, nil
+}
+
+// OpenUrlWithRetry attempts to open the specified URL by OpenUrl, retrying transient failures
+// and falling back to the candidate urls like LoadUrlContentWithRetry.
+// Only opening is retried, errors while reading the body are returned to the reader.
+func (c *Client) OpenUrlWithRetry(ctx context.Context, url string, retry *RetryPolicy) (body io.ReadCloser, err error) {
+	for _, candidate := range c.rewriter.Candidates(url) {
+		err = retry.Do(ctx, func(ctx context.Context) error {
+			body, err = c.OpenUrl(ctx, candidate)
+			return err
+		})
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// get sends a GET request with the default headers and the extra headers to the specified URL.
+// The body of a stream is limited by the idle read timeout instead of the request timeout.
+func (c *Client) get(ctx context.Context, url string, header http.Header, stream bool) (*http.Response, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if stream {
+		return c.do(req, 0, c.timeouts.IdleRead)
+	}
+	return c.Do(req)
+}
+
 // PingURL measures the latency (response time) of the specified URL using an HTTP HEAD request.
 // It calculates the time taken from sending the request to receiving the response headers.
 // Returns the latency in milliseconds and any error that occurred during the request.
-func PingURL(url string) (latency int64, err error) {
+func (c *Client) PingURL(ctx context.Context, url string) (latency int64, err error) {
+	req, err := c.NewRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return 0, err
+	}
 	start := time.Now()
-	resp, err := HttpClient.Head(url)
+	resp, err := c.Do(req)
 	if err != nil {
 		return 0, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("request failed, status code: %d", resp.StatusCode)
+		return 0, &StatusError{StatusCode: resp.StatusCode}
 	}
 	latency = int64(time.Since(start) / time.Millisecond)
 	return latency, nil
 }
 
-// TestDownloadSpeed measures the download speed of a given URL by downloading a portion or the entire file.
-// It returns the download speed in kilobytes per second (KB/s) and any error that occurred during the test.
-// For files larger than 5MB, it downloads the first 5MB to calculate the speed.
-// For smaller files, it downloads the entire file.
-func TestDownloadSpeed(ctx context.Context, url string) (kbps float64, err error) {
-	// Determine the size of data to download
-	testSize := int64(10 * (1 << 20)) // 10MB
-
-	// Send GET request to start downloading
-	getReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return 0, err
+// PingURLWithRetry pings the url by PingURL, retrying transient failures and falling back to the candidate urls
+// like LoadUrlContentWithRetry. Returns the latency of the first url answering, otherwise the last error encountered.
+func (c *Client) PingURLWithRetry(ctx context.Context, url string, retry *RetryPolicy) (latency int64, err error) {
+	for _, candidate := range c.rewriter.Candidates(url) {
+		err = retry.Do(ctx, func(ctx context.Context) error {
+			latency, err = c.PingURL(ctx, candidate)
+			return err
+		})
+		if err == nil {
+			return latency, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
 	}
-	getReq.Header.Set("User-Agent", UA)
-	getReq.Header.Set("Accept", "*/*")
-	getReq.Header.Set("Cache-Control", "no-cache")
+	return 0, err
+}
+
+// SpeedResult is the result of a download speed test.
+type SpeedResult struct {
+	Kbps       float64       // Kbps is the download speed in kilobytes per second
+	TTFB       time.Duration // TTFB is the time to first byte, from sending the request to receiving the first byte of the body
+	Downloaded int64         // Downloaded is the number of bytes downloaded
+	Elapsed    time.Duration // Elapsed is the duration of downloading the body
+	DNS        time.Duration // DNS is the duration of the DNS lookup
+	Connect    time.Duration // Connect is the duration of connecting
+	TLS        time.Duration // TLS is the duration of the TLS handshake
+	// FirstFrame is the time from sending the request to receiving the first decodable media bytes,
+	// see mediax.Probe. It is zero if they are not detected, e.g. the format is unknown.
+	FirstFrame time.Duration
+	// Complete reports whether the whole body is downloaded.
+	Complete bool
+	// SHA256 is the hex SHA-256 of the body if it is completely downloaded, otherwise empty.
+	SHA256 string
+	// Stream is the information of the media stream probed from the downloaded bytes, nil if it is not detected.
+	Stream *mediax.StreamInfo
+}
 
-	getResp, err := HttpClient.Do(getReq)
+// SpeedTest measures the download speed of the url by downloading up to maxSize bytes,
+// and stops after the speed test duration of the client even if fewer bytes are downloaded,
+// so that slow links report a low speed instead of a timeout.
+// The durations of the phases of the request and the arrival of the first decodable media bytes are recorded,
+// and the downloaded bytes are probed for the information of the media stream.
+func (c *Client) SpeedTest(ctx context.Context, url string, maxSize int64) (*SpeedResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, trace := WithRequestTrace(ctx)
+	req, err := c.NewRequest(ctx, http.MethodGet, url)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer getResp.Body.Close()
+	req.Header.Set("Cache-Control", "no-cache")
 
-	// Validate response status code
-	if getResp.StatusCode != http.StatusOK && getResp.StatusCode != http.StatusPartialContent {
-		return 0, fmt.Errorf("request failed, status code: %d", getResp.StatusCode)
+	resp, err := c.DoStream(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	// Create temporary buffer
 	buffer := make([]byte, 32*1024) // 32KB buffer
-	downloaded := int64(0)
-
-	// Start downloading and timing
-	start := time.Now()
-	for {
-		// Check if the test size has been reached
-		if downloaded >= testSize {
-			break
+	result := &SpeedResult{}
+	result.DNS, result.Connect, result.TLS = trace.Phases()
+	probe := mediax.NewProbe()
+	hash := sha256.New()
+	var (
+		bodyStart time.Time
+		exhausted atomic.Bool
+	)
+	for result.Downloaded < maxSize {
+		n, err := resp.Body.Read(buffer[:min(int64(len(buffer)), maxSize-result.Downloaded)])
+		if n > 0 {
+			if result.Downloaded == 0 {
+				// the time budget starts with the first byte, stalling before it is limited by the first-byte timeout
+				bodyStart = time.Now()
+				result.TTFB = trace.Since()
+				budget := time.AfterFunc(c.timeouts.SpeedTestDuration, func() {
+					exhausted.Store(true)
+					cancel()
+				})
+				defer budget.Stop()
+			}
+			result.Downloaded += int64(n)
+			hash.Write(buffer[:n])
+			if probe.Feed(buffer[:n]) && result.FirstFrame == 0 {
+				result.FirstFrame = trace.Since()
+			}
 		}
-
-		// Calculate the remaining bytes to read
-		bytesToRead := testSize - downloaded
-		if bytesToRead > int64(len(buffer)) {
-			bytesToRead = int64(len(buffer))
+		if err == io.EOF {
+			result.Complete = true
+			result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+			break // Normal end
 		}
-
-		// Read data
-		n, err := getResp.Body.Read(buffer[:bytesToRead])
-
 		if err != nil {
-			if err == io.EOF {
-				break // Normal end
+			if exhausted.Load() {
+				break // Time budget exhausted
 			}
-			return 0, err
+			return nil, err
 		}
-		downloaded += int64(n)
+	}
+	if result.Downloaded == 0 {
+		return nil, errors.New("empty body")
 	}
 
-	// Calculate download speed
-	elapsedTime := time.Since(start)
-	if elapsedTime > 0 {
-		seconds := elapsedTime.Seconds()
-		kbps = float64(downloaded) / seconds / 1024
+	result.Stream = probe.Info()
+	result.Elapsed = time.Since(bodyStart)
+	if result.Elapsed > 0 {
+		result.Kbps = float64(result.Downloaded) / result.Elapsed.Seconds() / 1024
+	}
+	return result, nil
+}
+
+// TestDownloadSpeed measures the download speed of a given URL by downloading a portion or the entire file.
+// It returns the download speed in kilobytes per second (KB/s) and any error that occurred during the test.
+// It downloads up to 10MB within the speed test duration of the client, see SpeedTest.
+func (c *Client) TestDownloadSpeed(ctx context.Context, url string) (kbps float64, err error) {
+	result, err := c.SpeedTest(ctx, url, 10*(1<<20))
+	if err != nil {
+		return 0, err
 	}
-	return kbps, nil
+	return result.Kbps, nil
 }
@@ -2,15 +2,19 @@ package httpx
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
 	"github.com/stretchr/testify/require"
 )
 
 func TestLoadUrlContent(t *testing.T) {
 	url := "https://raw.githubusercontent.com/Guovin/iptv-api/gd/output/result.m3u"
 
-	content, err := LoadUrlContent(context.Background(), url)
+	content, err := NewClient().LoadUrlContent(context.Background(), url)
 
 	require.NoError(t, err, "LoadUrlContent failed")
 	require.NotEmpty(t, content)
@@ -18,14 +22,41 @@ func TestLoadUrlContent(t *testing.T) {
 
 func TestPingURL(t *testing.T) {
 	url := "https://www.github.com"
-	lagency, err := PingURL(url)
+	lagency, err := NewClient().PingURL(context.Background(), url)
 	require.NoError(t, err, "PingURL failed")
 	require.NotZero(t, lagency)
 }
 
 func TestTestDownloadSpeed(t *testing.T) {
 	url := "https://download.jetbrains.com/go/goland-2025.1.3-aarch64.dmg"
-	kbps, err := TestDownloadSpeed(context.Background(), url)
+	kbps, err := NewClient().TestDownloadSpeed(context.Background(), url)
 	require.NoError(t, err, "TestDownloadSpeed failed")
 	require.NotZero(t, kbps)
 }
+
+func TestClient_NewRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		_, _ = fmt.Fprintf(w, "%s|%s|%s|%s|%s", r.UserAgent(), r.Referer(), r.Header.Get("X-Token"), user, password)
+	}))
+	defer server.Close()
+
+	content, err := NewClient().LoadUrlContent(context.Background(), server.URL)
+	require.NoError(t, err)
+	require.Equal(t, DefaultUA+"||||", string(content))
+
+	headerProfiles, err := NewHeaderProfiles([]*proto.HeaderProfile{
+		{Host: "*.example.com", UserAgent: "other"},
+		{Host: "127.0.0.1", Referer: "http://host/", Headers: []string{"X-Token: abc"}, Username: "user", Password: "pass"},
+	})
+	require.NoError(t, err)
+	client := NewClient(WithUA("custom"), WithHeaderProfiles(headerProfiles))
+	content, err = client.LoadUrlContent(context.Background(), server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "custom|http://host/|abc|user|pass", string(content))
+	require.Equal(t, "other", client.ProfileHeader("http://live.example.com/live.m3u8").Get("User-Agent"))
+	require.Nil(t, client.ProfileHeader("http://other/live.m3u8"))
+
+	_, err = NewHeaderProfiles([]*proto.HeaderProfile{{Host: "*", Headers: []string{"X-Token"}}})
+	require.Error(t, err)
+}
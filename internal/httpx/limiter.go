@@ -0,0 +1,194 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+const (
+	// DefaultMaxBackoff is the max time a host is paused after rate limiting responses.
+	DefaultMaxBackoff = time.Minute
+	// minBackoff is the first backoff of a host rate limiting without Retry-After.
+	minBackoff = time.Second
+)
+
+// HostLimiter limits the concurrency and the rate of requests per host, and pauses the requests to a host
+// after it answers 429 Too Many Requests or 503 Service Unavailable, for the Retry-After time if given
+// or an exponential backoff otherwise. It is shared by the clients fetching sources and testing streams.
+type HostLimiter struct {
+	limits []*proto.HostLimit
+	mu     sync.Mutex
+	hosts  map[string]*hostState
+}
+
+type hostState struct {
+	sem         chan struct{} // sem limits the concurrency, nil if unlimited
+	interval    time.Duration // interval between the starts of requests, 0 if unlimited
+	maxBackoff  time.Duration
+	mu          sync.Mutex
+	next        time.Time // next is the earliest start of the next request
+	pausedUntil time.Time
+	backoff     time.Duration
+}
+
+// NewHostLimiter creates a HostLimiter with the limits, the first limit whose host pattern
+// (see urlx.MatchHost) matches a host applies to it. The hosts matching no limit are only paused
+// after rate limiting responses.
+func NewHostLimiter(limits []*proto.HostLimit) *HostLimiter {
+	return &HostLimiter{
+		limits: limits,
+		hosts:  make(map[string]*hostState),
+	}
+}
+
+// state returns the state of the host, the host is the host of the url including the port if any.
+func (l *HostLimiter) state(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.hosts[host]; ok {
+		return s
+	}
+	s := &hostState{maxBackoff: DefaultMaxBackoff}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	for _, limit := range l.limits {
+		if !urlx.MatchHost(limit.Host, hostname) && !urlx.MatchHost(limit.Host, host) {
+			continue
+		}
+		if limit.MaxConcurrency > 0 {
+			s.sem = make(chan struct{}, limit.MaxConcurrency)
+		}
+		if limit.RequestsPerSecond > 0 {
+			s.interval = time.Duration(float64(time.Second) / limit.RequestsPerSecond)
+		}
+		if limit.MaxBackoffSeconds > 0 {
+			s.maxBackoff = time.Duration(limit.MaxBackoffSeconds) * time.Second
+		}
+		break
+	}
+	l.hosts[host] = s
+	return s
+}
+
+// MaxConcurrency returns the max number of concurrent requests to the host, 0 if unlimited.
+func (l *HostLimiter) MaxConcurrency(host string) int {
+	if l == nil {
+		return 0
+	}
+	return cap(l.state(host).sem)
+}
+
+// Acquire waits until a request to the host may be sent, and returns the function releasing it,
+// which must be called after the response has been read.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	s := l.state(host)
+	if s.sem != nil {
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	release = func() {
+		if s.sem != nil {
+			<-s.sem
+		}
+	}
+
+	s.mu.Lock()
+	start := time.Now()
+	if s.next.After(start) {
+		start = s.next
+	}
+	if s.pausedUntil.After(start) {
+		start = s.pausedUntil
+	}
+	s.next = start.Add(s.interval)
+	s.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
+
+// Observe pauses the host if the response is rate limiting, and resets its backoff otherwise.
+func (l *HostLimiter) Observe(host string, resp *http.Response) {
+	if l == nil {
+		return
+	}
+	s := l.state(host)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		s.backoff = 0
+		return
+	}
+
+	pause, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if !ok {
+		if s.backoff == 0 {
+			s.backoff = minBackoff
+		} else {
+			s.backoff *= 2
+		}
+		pause = s.backoff
+	}
+	pause = min(pause, s.maxBackoff)
+	if until := time.Now().Add(pause); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+		log.Warn().Msg("Host is rate limiting, pause requests to it.").
+			Str("host", host).
+			Int("status_code", resp.StatusCode).
+			Str("pause", pause.String()).
+			Done()
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, which is either seconds or an HTTP date.
+func parseRetryAfter(retryAfter string) (time.Duration, bool) {
+	if retryAfter == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(retryAfter); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// releaseBody releases the limit of the request when the response body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
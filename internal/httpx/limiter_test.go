@@ -0,0 +1,148 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter_Concurrency(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	limiter := NewHostLimiter([]*proto.HostLimit{{Host: "127.0.0.1", MaxConcurrency: 2}})
+	// the limit is shared by the clients
+	clients := []*Client{NewClient(WithHostLimiter(limiter)), NewClient(WithHostLimiter(limiter))}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(client *Client) {
+			defer wg.Done()
+			_, err := client.LoadUrlContent(context.Background(), server.URL)
+			require.NoError(t, err)
+		}(clients[i%2])
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), maxRunning.Load())
+}
+
+func TestHostLimiter_RequestsPerSecond(t *testing.T) {
+	limiter := NewHostLimiter([]*proto.HostLimit{{Host: "*.example.com", RequestsPerSecond: 20}})
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := limiter.Acquire(ctx, "a.example.com:8080")
+		require.NoError(t, err)
+		release()
+	}
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// other hosts are unlimited
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := limiter.Acquire(ctx, "other.org")
+		require.NoError(t, err)
+		release()
+	}
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestHostLimiter_RetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewClient(WithHostLimiter(NewHostLimiter(nil)))
+	_, err := client.LoadUrlContent(context.Background(), server.URL)
+	require.Error(t, err)
+
+	start := time.Now()
+	content, err := client.LoadUrlContent(context.Background(), server.URL)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(content))
+	require.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+
+	// the wait of a paused host is canceled with the context
+	client.limiter.Observe(server.Listener.Addr().String(), &http.Response{StatusCode: http.StatusServiceUnavailable})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.LoadUrlContent(ctx, server.URL)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("120")
+	require.True(t, ok)
+	require.Equal(t, 2*time.Minute, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.True(t, ok)
+	require.Greater(t, d, 59*time.Minute)
+
+	_, ok = parseRetryAfter("")
+	require.False(t, ok)
+	_, ok = parseRetryAfter("soon")
+	require.False(t, ok)
+}
+
+func TestHostLimiter_AcquireTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	limiter := NewHostLimiter([]*proto.HostLimit{{Host: "127.0.0.1", MaxConcurrency: 1}})
+	timeouts := NewTimeouts(nil)
+	timeouts.Request = 100 * time.Millisecond
+	client := NewClient(WithHostLimiter(limiter), WithTimeouts(timeouts))
+	body, err := client.OpenUrl(context.Background(), server.URL)
+	require.NoError(t, err)
+
+	// the only request allowed is held by the open body, waiting for it is limited by the request timeout
+	start := time.Now()
+	_, err = client.LoadUrlContent(context.Background(), server.URL)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.NoError(t, body.Close())
+	_, err = client.LoadUrlContent(context.Background(), server.URL)
+	require.NoError(t, err)
+}
+
+func TestHostLimiter_MaxConcurrency(t *testing.T) {
+	limiter := NewHostLimiter([]*proto.HostLimit{
+		{Host: "*.example.com", MaxConcurrency: 3},
+		{Host: "slow.org", RequestsPerSecond: 1},
+	})
+	require.Equal(t, 3, limiter.MaxConcurrency("a.example.com:8080"))
+	require.Equal(t, 0, limiter.MaxConcurrency("slow.org"))
+	require.Equal(t, 0, limiter.MaxConcurrency("other.org"))
+	require.Equal(t, 0, (*HostLimiter)(nil).MaxConcurrency("a.example.com"))
+}
@@ -0,0 +1,234 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MirrorMeta is the metadata of a mirrored copy of a remote url.
+type MirrorMeta struct {
+	Url string `json:"url"`
+	// Candidate is the candidate url the copy was fetched from, whose ETag and Last-Modified are stored,
+	// the url itself if it is empty.
+	Candidate    string    `json:"candidate,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// MirrorResult describes where the content opened by Mirror.Open comes from.
+type MirrorResult struct {
+	// NotModified means the upstream answered 304 and the content is read from the mirror.
+	NotModified bool
+	// Stale means the upstream could not be fetched and the content is the last mirrored copy.
+	Stale bool
+	// Err is the error of fetching the upstream if the content is stale.
+	Err error
+	// Meta is the metadata of the mirrored copy if the content is read from the mirror.
+	Meta *MirrorMeta
+}
+
+// Mirror keeps the last fetched content of remote urls on disk together with its ETag and Last-Modified,
+// so that the urls are fetched by conditional requests, and the last copy is used when the upstream
+// is unreachable.
+type Mirror struct {
+	client *Client
+	dir    string
+}
+
+// NewMirror creates a Mirror fetching the urls by the client and storing the copies in the directory.
+func NewMirror(client *Client, dir string) *Mirror {
+	return &Mirror{client: client, dir: dir}
+}
+
+// Open opens the content of the url, retrying transient failures by the retry policy and falling back to the candidate urls
+// like Client.LoadUrlContentWithRetry. The copy is always keyed by the original url.
+// The content is fetched by a conditional request if the url has been mirrored, which is only sent to
+// the candidate url the copy was fetched from, and read from the mirror if it is not modified. The fetched content is mirrored as it is read,
+// and the copy is replaced only if it is read completely.
+// If the upstream can not be fetched, the last mirrored copy is opened and marked stale,
+// and the error is returned only if there is no copy.
+func (m *Mirror) Open(ctx context.Context, url string, retry *RetryPolicy) (io.ReadCloser, *MirrorResult, error) {
+	contentFile, metaFile := m.paths(url)
+	meta, metaErr := m.readMeta(metaFile)
+	if metaErr != nil {
+		meta = nil
+	}
+
+	var (
+		resp    *http.Response
+		err     error
+		fetched string
+	)
+	for _, candidate := range m.client.rewriter.Candidates(url) {
+		header := meta.conditionalHeader(candidate)
+		err = retry.Do(ctx, func(ctx context.Context) error {
+			resp, err = m.client.get(ctx, candidate, header, true)
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode == http.StatusOK || (resp.StatusCode == http.StatusNotModified && len(header) > 0) {
+				return nil
+			}
+			_ = resp.Body.Close()
+			statusErr := &StatusError{StatusCode: resp.StatusCode}
+			resp = nil
+			return statusErr
+		})
+		if err == nil {
+			fetched = candidate
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	switch {
+	case err != nil:
+		if meta == nil {
+			return nil, nil, err
+		}
+		f, openErr := os.Open(contentFile)
+		if openErr != nil {
+			return nil, nil, err
+		}
+		return f, &MirrorResult{Stale: true, Err: err, Meta: meta}, nil
+	case resp.StatusCode == http.StatusNotModified:
+		_ = resp.Body.Close()
+		f, err := os.Open(contentFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, &MirrorResult{NotModified: true, Meta: meta}, nil
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		_ = resp.Body.Close()
+		return nil, nil, err
+	}
+	tmp, err := os.CreateTemp(m.dir, ".mirror-*")
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, nil, err
+	}
+	return &mirrorReader{
+		body: resp.Body,
+		tmp:  tmp,
+		meta: &MirrorMeta{
+			Url:          url,
+			Candidate:    fetched,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		},
+		contentFile: contentFile,
+		metaFile:    metaFile,
+	}, &MirrorResult{}, nil
+}
+
+// conditionalHeader returns the headers of the conditional request to the candidate url,
+// which is empty if the copy was not fetched from it.
+func (meta *MirrorMeta) conditionalHeader(candidate string) http.Header {
+	header := http.Header{}
+	if meta == nil {
+		return header
+	}
+	fetchedFrom := meta.Candidate
+	if fetchedFrom == "" {
+		fetchedFrom = meta.Url
+	}
+	if fetchedFrom != candidate {
+		return header
+	}
+	if meta.ETag != "" {
+		header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		header.Set("If-Modified-Since", meta.LastModified)
+	}
+	return header
+}
+
+// paths returns the content and metadata file paths of the url, named by the hash of the url.
+func (m *Mirror) paths(url string) (contentFile, metaFile string) {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(m.dir, name), filepath.Join(m.dir, name+".json")
+}
+
+func (m *Mirror) readMeta(metaFile string) (*MirrorMeta, error) {
+	bz, err := os.ReadFile(metaFile)
+	if err != nil {
+		return nil, err
+	}
+	meta := &MirrorMeta{}
+	if err := json.Unmarshal(bz, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// mirrorReader reads the response body and writes it to a temporary file at the same time,
+// which replaces the mirrored copy when the body is closed after being read completely.
+type mirrorReader struct {
+	body        io.ReadCloser
+	tmp         *os.File
+	meta        *MirrorMeta
+	contentFile string
+	metaFile    string
+	eof         bool
+	err         error
+}
+
+func (r *mirrorReader) Read(p []byte) (n int, err error) {
+	n, err = r.body.Read(p)
+	if n > 0 && r.err == nil {
+		if _, werr := r.tmp.Write(p[:n]); werr != nil {
+			r.err = werr
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		r.eof = true
+	} else if err != nil && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// Close reads the rest of the body if the reader stopped early, e.g. after a JSON document,
+// so that the copy is complete, and then replaces the mirrored copy if no error occurred.
+func (r *mirrorReader) Close() error {
+	if !r.eof && r.err == nil {
+		_, _ = io.Copy(io.Discard, r)
+	}
+	closeErr := r.body.Close()
+	tmpName := r.tmp.Name()
+	if err := r.tmp.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if !r.eof || r.err != nil {
+		_ = os.Remove(tmpName)
+		return closeErr
+	}
+	if err := os.Rename(tmpName, r.contentFile); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	metaBz, err := json.Marshal(r.meta)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.metaFile, metaBz, 0644); err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror_Open(t *testing.T) {
+	const etag = `"v1"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("CCTV1,http://host/cctv1.m3u8\n"))
+	}))
+	ctx := context.Background()
+	mirror := NewMirror(NewClient(), t.TempDir())
+	url := server.URL + "/live.txt"
+
+	readAll := func() ([]byte, *MirrorResult) {
+		body, result, err := mirror.Open(ctx, url, nil)
+		require.NoError(t, err)
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.NoError(t, body.Close())
+		return content, result
+	}
+
+	// the first fetch is mirrored
+	content, result := readAll()
+	require.Equal(t, "CCTV1,http://host/cctv1.m3u8\n", string(content))
+	require.False(t, result.NotModified)
+	require.False(t, result.Stale)
+
+	// the second fetch is conditional and read from the mirror
+	content, result = readAll()
+	require.Equal(t, "CCTV1,http://host/cctv1.m3u8\n", string(content))
+	require.True(t, result.NotModified)
+	require.False(t, result.Stale)
+	require.Equal(t, 2, requests)
+
+	// the last copy is used if the upstream is unreachable
+	server.Close()
+	content, result = readAll()
+	require.Equal(t, "CCTV1,http://host/cctv1.m3u8\n", string(content))
+	require.True(t, result.Stale)
+	require.Error(t, result.Err)
+
+	// no copy of other urls
+	_, _, err := mirror.Open(ctx, server.URL+"/other.txt", nil)
+	require.Error(t, err)
+}
+
+func TestMirror_OpenCandidates(t *testing.T) {
+	var mirrorDown bool
+	var mirrorConditional []string
+	mirrorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mirrorDown {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		mirrorConditional = append(mirrorConditional, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"mirror"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"mirror"`)
+		_, _ = w.Write([]byte("CCTV1,http://host/cctv1.m3u8\n"))
+	}))
+	defer mirrorServer.Close()
+	originServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"origin"`)
+		_, _ = w.Write([]byte("CCTV1,http://host/cctv1.m3u8\n"))
+	}))
+	defer originServer.Close()
+	rewriter, err := NewUrlRewriter([]*proto.UrlRewriteRule{{
+		Host:      "127.0.0.1",
+		Pattern:   "^" + regexp.QuoteMeta(originServer.URL) + "(/.*)$",
+		Templates: []string{mirrorServer.URL + "$1"},
+	}})
+	require.NoError(t, err)
+	ctx := context.Background()
+	mirror := NewMirror(NewClient(WithUrlRewriter(rewriter)), t.TempDir())
+	url := originServer.URL + "/live.txt"
+
+	open := func() *MirrorResult {
+		body, result, err := mirror.Open(ctx, url, nil)
+		require.NoError(t, err)
+		_, err = io.ReadAll(body)
+		require.NoError(t, err)
+		require.NoError(t, body.Close())
+		return result
+	}
+
+	// the copy is fetched from the original url as the mirror is down
+	mirrorDown = true
+	require.False(t, open().NotModified)
+
+	// the ETag of the original url is not sent to the mirror
+	mirrorDown = false
+	require.False(t, open().NotModified)
+	require.Equal(t, []string{""}, mirrorConditional)
+
+	// the ETag of the mirror is sent to the mirror
+	require.True(t, open().NotModified)
+	require.Equal(t, []string{"", `"mirror"`}, mirrorConditional)
+}
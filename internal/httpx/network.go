@@ -0,0 +1,95 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+const (
+	// ProxyEnvironment uses the proxy of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+	// which is the default.
+	ProxyEnvironment = "env"
+	// ProxyDirect connects directly without any proxy.
+	ProxyDirect = "direct"
+)
+
+// NewHttpClient creates a http client connecting through the proxies and local address of the profile,
+// which is usually used by a Client fetching sources or testing channel streams, see WithHttpClient.
+// The proxy is "env" (the default if empty), "direct", or an http, https, socks5 or socks5h proxy url.
+// The first host proxy whose host pattern (see urlx.MatchHost) matches the host of a request overrides it.
+// Outgoing connections, including those to the proxies, are bound to the source ip and the interface
+// if configured. Binding to an interface is only supported on linux.
+// The dial, TLS handshake and response header timeouts of the profile are set to the transport,
+// the other timeouts are applied by the Client, see WithTimeouts.
+func NewHttpClient(profile *proto.NetworkProfile) (*http.Client, error) {
+	timeouts := NewTimeouts(profile.GetTimeouts())
+	transport := newTransport(timeouts)
+
+	defaultProxy, err := proxyFunc(profile.GetProxy())
+	if err != nil {
+		return nil, err
+	}
+	hostProxies := make([]func(*http.Request) (*url.URL, error), len(profile.GetHostProxies()))
+	for i, hp := range profile.GetHostProxies() {
+		if hostProxies[i], err = proxyFunc(hp.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy of host %s: %w", hp.Host, err)
+		}
+	}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		for i, hp := range profile.GetHostProxies() {
+			if urlx.MatchHost(hp.Host, req.URL.Hostname()) {
+				return hostProxies[i](req)
+			}
+		}
+		return defaultProxy(req)
+	}
+
+	if profile.GetSourceIp() != "" || profile.GetInterface() != "" {
+		dialer := newDialer(timeouts)
+		if profile.GetSourceIp() != "" {
+			ip := net.ParseIP(profile.GetSourceIp())
+			if ip == nil {
+				return nil, fmt.Errorf("invalid source ip: %s", profile.GetSourceIp())
+			}
+			dialer.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+		if profile.GetInterface() != "" {
+			if dialer.Control, err = bindToInterface(profile.GetInterface()); err != nil {
+				return nil, err
+			}
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// proxyFunc returns the proxy function of the transport for the proxy config.
+func proxyFunc(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	switch strings.ToLower(strings.TrimSpace(proxy)) {
+	case "", ProxyEnvironment:
+		return http.ProxyFromEnvironment, nil
+	case ProxyDirect:
+		return func(*http.Request) (*url.URL, error) { return nil, nil }, nil
+	}
+	proxyUrl, err := url.Parse(strings.TrimSpace(proxy))
+	if err != nil {
+		return nil, err
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, errors.New("unsupported proxy scheme: " + proxyUrl.Scheme)
+	}
+	if proxyUrl.Host == "" {
+		return nil, errors.New("proxy host is empty")
+	}
+	return http.ProxyURL(proxyUrl), nil
+}
@@ -0,0 +1,47 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHttpClient(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied " + r.URL.Host))
+	}))
+	defer proxy.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient(&proto.NetworkProfile{
+		Proxy:       ProxyDirect,
+		HostProxies: []*proto.HostProxy{{Host: "*.example.com", Proxy: proxy.URL}},
+		SourceIp:    "127.0.0.1",
+	})
+	require.NoError(t, err)
+
+	get := func(url string) string {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	require.Equal(t, "proxied live.example.com", get("http://live.example.com/live.txt"))
+	require.Equal(t, "direct", get(server.URL))
+
+	_, err = NewHttpClient(&proto.NetworkProfile{Proxy: "ftp://host"})
+	require.Error(t, err)
+	_, err = NewHttpClient(&proto.NetworkProfile{SourceIp: "host"})
+	require.Error(t, err)
+	_, err = NewHttpClient(nil)
+	require.NoError(t, err)
+}
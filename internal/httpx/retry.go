@@ -0,0 +1,202 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+const (
+	// DefaultRetryBaseDelay is the delay before the first retry if not configured.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay is the max delay between retries if not configured.
+	DefaultRetryMaxDelay = 10 * time.Second
+	// minBudgetRetries is the number of retries always allowed by a retry budget.
+	minBudgetRetries = 10
+)
+
+// ErrorClass is the class of a request error, which decides whether it is retried.
+type ErrorClass string
+
+const (
+	ErrorClassDNS            ErrorClass = "dns"
+	ErrorClassConnectRefused ErrorClass = "connect_refused"
+	ErrorClassConnectReset   ErrorClass = "connect_reset"
+	ErrorClassTimeout        ErrorClass = "timeout"
+	ErrorClassTLS            ErrorClass = "tls"
+	ErrorClassRateLimited    ErrorClass = "rate_limited"
+	ErrorClass4xx            ErrorClass = "4xx"
+	ErrorClass5xx            ErrorClass = "5xx"
+	ErrorClassCanceled       ErrorClass = "canceled"
+	ErrorClassOther          ErrorClass = "other"
+)
+
+// StatusError is the error of a response with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed, status code: %d", e.StatusCode)
+}
+
+// ClassifyError returns the class of the error of a request.
+func ClassifyError(err error) ErrorClass {
+	var (
+		statusErr *StatusError
+		dnsErr    *net.DNSError
+		netErr    net.Error
+	)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.As(err, &statusErr):
+		switch {
+		case statusErr.StatusCode == http.StatusTooManyRequests:
+			return ErrorClassRateLimited
+		case statusErr.StatusCode == http.StatusRequestTimeout:
+			return ErrorClassTimeout
+		case statusErr.StatusCode >= 500:
+			return ErrorClass5xx
+		case statusErr.StatusCode >= 400:
+			return ErrorClass4xx
+		}
+		return ErrorClassOther
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case isTLSError(err):
+		return ErrorClassTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorClassConnectReset
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	}
+	return ErrorClassOther
+}
+
+// isTLSError reports whether the error is a TLS handshake or certificate error.
+func isTLSError(err error) bool {
+	var (
+		recordErr    tls.RecordHeaderError
+		alertErr     tls.AlertError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// IsTransientError reports whether the error of a request is transient, so that the request is worth retrying:
+// timeouts, reset connections, rate limiting, 5xx responses and DNS failures other than unknown hosts.
+// Refused connections, TLS errors, 4xx responses and other errors are permanent.
+func IsTransientError(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassTimeout, ErrorClassConnectReset, ErrorClassRateLimited, ErrorClass5xx:
+		return true
+	case ErrorClassDNS:
+		var dnsErr *net.DNSError
+		return errors.As(err, &dnsErr) && !dnsErr.IsNotFound
+	}
+	return false
+}
+
+// RetryPolicy retries the transient failures of requests with exponential backoff and jitter.
+// The retries of all requests using the same policy are limited by its retry budget,
+// so that a broken host or network does not multiply the requests.
+type RetryPolicy struct {
+	retryTimes int64
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+
+	budgetRatio float64
+	mu          sync.Mutex
+	requests    int64
+	retries     int64
+}
+
+// NewRetryPolicy creates a RetryPolicy from the config, the retry times of which default to defaultRetryTimes
+// if 0, and a negative one disables retries. The budget ratio is the max ratio of retries to requests,
+// besides a few retries always allowed, 0 is unlimited.
+func NewRetryPolicy(policy *proto.RetryPolicy, defaultRetryTimes int64) *RetryPolicy {
+	p := &RetryPolicy{
+		retryTimes:  defaultRetryTimes,
+		baseDelay:   DefaultRetryBaseDelay,
+		maxDelay:    DefaultRetryMaxDelay,
+		budgetRatio: policy.GetBudgetRatio(),
+	}
+	if policy.GetRetryTimes() != 0 {
+		p.retryTimes = max(policy.GetRetryTimes(), 0)
+	}
+	if policy.GetBaseDelayMs() > 0 {
+		p.baseDelay = time.Duration(policy.GetBaseDelayMs()) * time.Millisecond
+	}
+	if policy.GetMaxDelayMs() > 0 {
+		p.maxDelay = time.Duration(policy.GetMaxDelayMs()) * time.Millisecond
+	}
+	p.maxDelay = max(p.maxDelay, p.baseDelay)
+	return p
+}
+
+// Do calls the function once and retries it up to the retry times while it fails transiently,
+// the budget allows and the context is not done. It returns the last error.
+// A nil policy calls the function once.
+func (p *RetryPolicy) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	err := f(ctx)
+	if p == nil {
+		return err
+	}
+	p.mu.Lock()
+	p.requests++
+	p.mu.Unlock()
+	for i := int64(0); err != nil && i < p.retryTimes; i++ {
+		if ctx.Err() != nil || !IsTransientError(err) || !p.withdraw() {
+			return err
+		}
+		timer := time.NewTimer(p.delay(i))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		err = f(ctx)
+	}
+	return err
+}
+
+// withdraw takes a retry from the budget, it reports false if the budget is exhausted.
+func (p *RetryPolicy) withdraw() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.budgetRatio > 0 && float64(p.retries) >= minBudgetRetries+p.budgetRatio*float64(p.requests) {
+		return false
+	}
+	p.retries++
+	return true
+}
+
+// delay returns the delay before the retry of the index, which is doubled for each retry up to the max delay,
+// with a random jitter of up to half of it.
+func (p *RetryPolicy) delay(retry int64) time.Duration {
+	d := p.baseDelay
+	for i := int64(0); i < retry && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxDelay)
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err       error
+		class     ErrorClass
+		transient bool
+	}{
+		{&StatusError{StatusCode: http.StatusNotFound}, ErrorClass4xx, false},
+		{fmt.Errorf("load: %w", &StatusError{StatusCode: http.StatusBadGateway}), ErrorClass5xx, true},
+		{&StatusError{StatusCode: http.StatusTooManyRequests}, ErrorClassRateLimited, true},
+		{&net.DNSError{Err: "no such host", IsNotFound: true}, ErrorClassDNS, false},
+		{&net.DNSError{Err: "i/o timeout", IsTimeout: true}, ErrorClassDNS, true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, ErrorClassConnectRefused, false},
+		{&net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrorClassConnectReset, true},
+		{context.DeadlineExceeded, ErrorClassTimeout, true},
+		{context.Canceled, ErrorClassCanceled, false},
+		{errors.New("ts segment not found"), ErrorClassOther, false},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.class, ClassifyError(c.err), c.err.Error())
+		require.Equal(t, c.transient, IsTransientError(c.err), c.err.Error())
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	ctx := context.Background()
+	policy := NewRetryPolicy(&proto.RetryPolicy{BaseDelayMs: 1, MaxDelayMs: 2}, 3)
+
+	// transient failures are retried
+	calls := 0
+	err := policy.Do(ctx, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return &StatusError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+
+	// permanent failures are not
+	calls = 0
+	err = policy.Do(ctx, func(ctx context.Context) error {
+		calls++
+		return &StatusError{StatusCode: http.StatusNotFound}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, calls)
+
+	// a negative retry times disables retries, and a nil policy calls once
+	for _, p := range []*RetryPolicy{NewRetryPolicy(&proto.RetryPolicy{RetryTimes: -1}, 3), nil} {
+		calls = 0
+		err = p.Do(ctx, func(ctx context.Context) error {
+			calls++
+			return context.DeadlineExceeded
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, calls)
+	}
+}
+
+func TestRetryPolicy_Budget(t *testing.T) {
+	ctx := context.Background()
+	policy := NewRetryPolicy(&proto.RetryPolicy{BaseDelayMs: 1, MaxDelayMs: 1, BudgetRatio: 0.1}, 2)
+	calls := 0
+	for i := 0; i < 20; i++ {
+		_ = policy.Do(ctx, func(ctx context.Context) error {
+			calls++
+			return context.DeadlineExceeded
+		})
+	}
+	// 20 requests and the retries allowed by the budget
+	require.Less(t, calls, 20+20*2)
+	require.GreaterOrEqual(t, calls, 20+minBudgetRetries)
+}
+
+func TestLoadUrlContentWithRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/flaky":
+			if requests.Load() == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client := NewClient()
+
+	// no retry still loads the content once
+	content, err := client.LoadUrlContentWithRetry(ctx, server.URL+"/live.txt", NewRetryPolicy(nil, 0))
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(content))
+
+	retry := NewRetryPolicy(&proto.RetryPolicy{BaseDelayMs: 1}, 3)
+	requests.Store(0)
+	content, err = client.LoadUrlContentWithRetry(ctx, server.URL+"/flaky", retry)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(content))
+	require.Equal(t, int32(2), requests.Load())
+
+	requests.Store(0)
+	_, err = client.LoadUrlContentWithRetry(ctx, server.URL+"/missing", retry)
+	require.Equal(t, ErrorClass4xx, ClassifyError(err))
+	require.Equal(t, int32(1), requests.Load())
+}
@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+// UrlRewriter rewrites urls by the rules of their hosts, e.g. to fetch GitHub raw urls through
+// a mirror prefix or jsDelivr. It is used by the Client fetching sources and EPGs, see WithUrlRewriter.
+type UrlRewriter struct {
+	rules []*urlRewriteRule
+}
+
+type urlRewriteRule struct {
+	host      string
+	pattern   *regexp.Regexp
+	templates []string
+}
+
+// NewUrlRewriter creates a UrlRewriter with the rules, see Candidates for how they are applied.
+// An empty pattern matches any url of the host.
+func NewUrlRewriter(rules []*proto.UrlRewriteRule) (*UrlRewriter, error) {
+	r := &UrlRewriter{}
+	for _, rule := range rules {
+		pattern := rule.Pattern
+		if pattern == "" {
+			pattern = "^.*$"
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url rewrite pattern of host %s: %w", rule.Host, err)
+		}
+		r.rules = append(r.rules, &urlRewriteRule{host: rule.Host, pattern: re, templates: rule.Templates})
+	}
+	return r, nil
+}
+
+// Candidates returns the urls to try in order when fetching the url.
+// The first rule whose host pattern (see urlx.MatchHost) matches the host of the url and whose
+// pattern matches the url rewrites it by each of its templates, in which $0 is the whole match and
+// $1, ${name} etc. are the submatches of the pattern. The original url is always the last fallback.
+func (r *UrlRewriter) Candidates(rawUrl string) []string {
+	if r == nil {
+		return []string{rawUrl}
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return []string{rawUrl}
+	}
+	for _, rule := range r.rules {
+		if !urlx.MatchHost(rule.host, u.Hostname()) {
+			continue
+		}
+		match := rule.pattern.FindStringSubmatchIndex(rawUrl)
+		if match == nil {
+			continue
+		}
+		candidates := make([]string, 0, len(rule.templates)+1)
+		for _, template := range rule.templates {
+			rewritten := string(rule.pattern.ExpandString(nil, template, rawUrl, match))
+			if rewritten != "" && rewritten != rawUrl {
+				candidates = append(candidates, rewritten)
+			}
+		}
+		return append(candidates, rawUrl)
+	}
+	return []string{rawUrl}
+}
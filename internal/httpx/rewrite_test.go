@@ -0,0 +1,78 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUrlRewriter_Candidates(t *testing.T) {
+	r, err := NewUrlRewriter([]*proto.UrlRewriteRule{
+		{
+			Host:    "raw.githubusercontent.com",
+			Pattern: `^https://raw\.githubusercontent\.com/([^/]+)/([^/]+)/([^/]+)/(.*)$`,
+			Templates: []string{
+				"https://ghproxy.net/$0",
+				"https://cdn.jsdelivr.net/gh/$1/$2@$3/$4",
+			},
+		},
+		{
+			Host:      "*.example.com",
+			Templates: []string{"https://mirror.example.org/?url=$0"},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"https://ghproxy.net/https://raw.githubusercontent.com/user/repo/main/live.m3u",
+		"https://cdn.jsdelivr.net/gh/user/repo@main/live.m3u",
+		"https://raw.githubusercontent.com/user/repo/main/live.m3u",
+	}, r.Candidates("https://raw.githubusercontent.com/user/repo/main/live.m3u"))
+	require.Equal(t, []string{
+		"https://mirror.example.org/?url=http://a.example.com/live.txt",
+		"http://a.example.com/live.txt",
+	}, r.Candidates("http://a.example.com/live.txt"))
+	require.Equal(t, []string{"http://host/live.txt"}, r.Candidates("http://host/live.txt"))
+
+	var nilRewriter *UrlRewriter
+	require.Equal(t, []string{"http://host/live.txt"}, nilRewriter.Candidates("http://host/live.txt"))
+
+	_, err = NewUrlRewriter([]*proto.UrlRewriteRule{{Host: "*", Pattern: "("}})
+	require.Error(t, err)
+}
+
+func TestLoadUrlContentWithRetry_Fallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/live.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	r, err := NewUrlRewriter([]*proto.UrlRewriteRule{{
+		Host:      "127.0.0.1",
+		Pattern:   `^(https?://[^/]+)/(.*)$`,
+		Templates: []string{"$1/broken/$2", "$1/mirror/$2"},
+	}})
+	require.NoError(t, err)
+	client := NewClient(WithUrlRewriter(r))
+
+	content, err := client.LoadUrlContentWithRetry(context.Background(), server.URL+"/live.txt", nil)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(content))
+
+	// only the urls fetched with retry are rewritten
+	_, err = client.LoadUrlContent(context.Background(), server.URL+"/live.txt")
+	require.Error(t, err)
+
+	_, err = client.PingURLWithRetry(context.Background(), server.URL+"/live.txt", nil)
+	require.NoError(t, err)
+	_, err = client.PingURL(context.Background(), server.URL+"/live.txt")
+	require.Error(t, err)
+}
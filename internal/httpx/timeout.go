@@ -0,0 +1,136 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+const (
+	// DefaultDialTimeout is the timeout of establishing a connection.
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultTLSHandshakeTimeout is the timeout of the TLS handshake.
+	DefaultTLSHandshakeTimeout = 5 * time.Second
+	// DefaultResponseHeaderTimeout is the timeout of receiving the response headers after sending a request.
+	DefaultResponseHeaderTimeout = 5 * time.Second
+	// DefaultFirstByteTimeout is the timeout of receiving the first byte of the body after the response headers.
+	DefaultFirstByteTimeout = 5 * time.Second
+	// DefaultTimeout is the total timeout of a request, including reading the response body,
+	// which does not apply to speed tests and streamed bodies.
+	DefaultTimeout = 30 * time.Second
+	// DefaultIdleReadTimeout is the timeout of receiving no data while reading a streamed body, e.g. a source.
+	DefaultIdleReadTimeout = 15 * time.Second
+	// DefaultTestDuration is the max duration of testing a channel url, including all its requests and retries.
+	DefaultTestDuration = 30 * time.Second
+	// DefaultSpeedTestDuration is the time budget of downloading a stream in a speed test.
+	DefaultSpeedTestDuration = 5 * time.Second
+)
+
+// Timeouts are the timeouts of the requests of a Client.
+type Timeouts struct {
+	Dial              time.Duration
+	TLSHandshake      time.Duration
+	ResponseHeader    time.Duration
+	FirstByte         time.Duration
+	Request           time.Duration
+	TestDuration      time.Duration
+	SpeedTestDuration time.Duration
+	IdleRead          time.Duration
+}
+
+// NewTimeouts creates the Timeouts from the config, the missing ones use the defaults.
+func NewTimeouts(timeouts *proto.Timeouts) Timeouts {
+	ms := func(v int64, def time.Duration) time.Duration {
+		if v > 0 {
+			return time.Duration(v) * time.Millisecond
+		}
+		return def
+	}
+	return Timeouts{
+		Dial:              ms(timeouts.GetDialMs(), DefaultDialTimeout),
+		TLSHandshake:      ms(timeouts.GetTlsHandshakeMs(), DefaultTLSHandshakeTimeout),
+		ResponseHeader:    ms(timeouts.GetResponseHeaderMs(), DefaultResponseHeaderTimeout),
+		FirstByte:         ms(timeouts.GetFirstByteMs(), DefaultFirstByteTimeout),
+		Request:           ms(timeouts.GetRequestMs(), DefaultTimeout),
+		TestDuration:      ms(timeouts.GetTestDurationMs(), DefaultTestDuration),
+		SpeedTestDuration: ms(timeouts.GetSpeedTestDurationMs(), DefaultSpeedTestDuration),
+		IdleRead:          ms(timeouts.GetIdleReadMs(), DefaultIdleReadTimeout),
+	}
+}
+
+// newDialer creates the dialer of the transport with the dial timeout.
+func newDialer(timeouts Timeouts) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeouts.Dial,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+// ErrFirstByteTimeout is the error of reading a body whose first byte is not received within the first-byte timeout.
+var ErrFirstByteTimeout = fmt.Errorf("first byte timeout: %w", context.DeadlineExceeded)
+
+// ErrIdleReadTimeout is the error of reading a streamed body which receives no data within the idle read timeout.
+var ErrIdleReadTimeout = fmt.Errorf("idle read timeout: %w", context.DeadlineExceeded)
+
+// timedBody cancels the request if the first byte of the body is not read within the first-byte timeout,
+// or no data is read within the idle timeout after that if it is positive,
+// and releases the context of the request when it is closed.
+type timedBody struct {
+	io.ReadCloser
+	cancel   context.CancelFunc
+	once     sync.Once
+	idle     time.Duration
+	timer    *time.Timer
+	started  atomic.Bool // started means the first byte has been read
+	timedOut atomic.Bool
+}
+
+func newTimedBody(body io.ReadCloser, firstByteTimeout, idleTimeout time.Duration, cancel context.CancelFunc) *timedBody {
+	b := &timedBody{
+		ReadCloser: body,
+		cancel:     cancel,
+		idle:       idleTimeout,
+	}
+	b.timer = time.AfterFunc(firstByteTimeout, func() {
+		b.timedOut.Store(true)
+		cancel()
+	})
+	return b
+}
+
+func (b *timedBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	switch {
+	case err != nil:
+		b.timer.Stop()
+	case n > 0 && b.idle > 0:
+		b.started.Store(true)
+		b.timer.Reset(b.idle)
+	case n > 0:
+		b.started.Store(true)
+		b.timer.Stop()
+	}
+	if err != nil && err != io.EOF && b.timedOut.Load() {
+		if b.started.Load() {
+			err = ErrIdleReadTimeout
+		} else {
+			err = ErrFirstByteTimeout
+		}
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.timer.Stop()
+		b.cancel()
+	})
+	return err
+}
@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTimeouts(t *testing.T) {
+	timeouts := NewTimeouts(&proto.Timeouts{DialMs: 1000, SpeedTestDurationMs: 2000})
+	require.Equal(t, time.Second, timeouts.Dial)
+	require.Equal(t, 2*time.Second, timeouts.SpeedTestDuration)
+	require.Equal(t, DefaultFirstByteTimeout, timeouts.FirstByte)
+	require.Equal(t, DefaultTimeout, NewTimeouts(nil).Request)
+}
+
+func TestClient_SpeedTest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flv":
+			// the header, an empty PreviousTagSize and a video tag of 4 bytes
+			_, _ = w.Write([]byte{'F', 'L', 'V', 1, 1, 0, 0, 0, 9, 0, 0, 0, 0})
+			_, _ = w.Write([]byte{9, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0x17, 0, 0, 0, 0, 0, 0, 15})
+		case "/stall":
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			// an endless slow stream
+			time.Sleep(50 * time.Millisecond)
+			for {
+				if _, err := w.Write(make([]byte, 1024)); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		}
+	}))
+	defer server.Close()
+	client := NewClient(WithTimeouts(Timeouts{
+		FirstByte:         100 * time.Millisecond,
+		Request:           250 * time.Millisecond,
+		SpeedTestDuration: 300 * time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	// the speed test stops after its time budget, which is not limited by the request timeout
+	result, err := client.SpeedTest(ctx, server.URL+"/slow", 10<<20)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.TTFB, 50*time.Millisecond)
+	require.GreaterOrEqual(t, result.Elapsed, 300*time.Millisecond)
+	require.Less(t, result.Elapsed, time.Second)
+	require.Greater(t, result.Kbps, float64(0))
+	require.False(t, result.Complete)
+	require.Empty(t, result.SHA256)
+
+	// the first decodable media bytes are detected, and the complete body is hashed
+	result, err = client.SpeedTest(ctx, server.URL+"/flv", 10<<20)
+	require.NoError(t, err)
+	require.Positive(t, result.FirstFrame)
+	require.GreaterOrEqual(t, result.FirstFrame, result.TTFB)
+	require.True(t, result.Complete)
+	require.Len(t, result.SHA256, 64)
+	result, err = client.SpeedTest(ctx, server.URL+"/slow", 1024)
+	require.NoError(t, err)
+	require.Zero(t, result.FirstFrame)
+
+	// other requests are limited by the request timeout
+	_, err = client.LoadUrlContent(ctx, server.URL+"/slow")
+	require.Equal(t, ErrorClassTimeout, ClassifyError(err))
+
+	// the first byte must be received in time
+	body, err := client.OpenUrl(ctx, server.URL+"/stall")
+	require.NoError(t, err)
+	defer body.Close()
+	_, err = io.ReadAll(body)
+	require.ErrorIs(t, err, ErrFirstByteTimeout)
+	require.True(t, IsTransientError(err))
+}
+
+func TestClient_OpenUrlStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunks := 8
+		if r.URL.Path == "/pause" {
+			chunks = 1
+		}
+		for i := 0; i < chunks; i++ {
+			_, _ = w.Write([]byte("CCTV1,http://host/cctv1.m3u8\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+		if r.URL.Path == "/pause" {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	client := NewClient(WithTimeouts(Timeouts{
+		FirstByte: 100 * time.Millisecond,
+		Request:   200 * time.Millisecond,
+		IdleRead:  150 * time.Millisecond,
+	}))
+	ctx := context.Background()
+
+	// a streamed body is not limited by the request timeout
+	body, err := client.OpenUrl(ctx, server.URL+"/chunks")
+	require.NoError(t, err)
+	content, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	require.Len(t, content, 8*29)
+	_, err = client.LoadUrlContent(ctx, server.URL+"/chunks")
+	require.Equal(t, ErrorClassTimeout, ClassifyError(err))
+
+	// but it fails if no data is received within the idle read timeout
+	body, err = client.OpenUrl(ctx, server.URL+"/pause")
+	require.NoError(t, err)
+	defer body.Close()
+	_, err = io.ReadAll(body)
+	require.ErrorIs(t, err, ErrIdleReadTimeout)
+}
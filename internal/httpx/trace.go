@@ -0,0 +1,88 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTrace records the durations of the phases of the requests sent with its context.
+// The phases of a reused connection are zero.
+type RequestTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+}
+
+// WithRequestTrace returns a context tracing the requests sent with it by the RequestTrace.
+func WithRequestTrace(ctx context.Context) (context.Context, *RequestTrace) {
+	t := &RequestTrace{}
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.start.IsZero() {
+				t.start = time.Now()
+			}
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dns = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// several addresses may be dialed in parallel, the connect time is from the first one
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.connect = time.Since(t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tls = time.Since(t.tlsStart)
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), t
+}
+
+// Since returns the duration since the first request started getting a connection,
+// which excludes the time waiting for the HostLimiter.
+func (t *RequestTrace) Since() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.start.IsZero() {
+		return 0
+	}
+	return time.Since(t.start)
+}
+
+// Phases returns the durations of the DNS lookup, connecting and the TLS handshake.
+func (t *RequestTrace) Phases() (dns, connect, tls time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dns, t.connect, t.tls
+}
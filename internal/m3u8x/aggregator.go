@@ -1,6 +1,11 @@
 package m3u8x
 
 import (
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
 	"github.com/rambollwong/rainbowcat/types"
 	"github.com/rambollwong/rainbowcat/util"
 	"github.com/rambollwong/rainbowlog/log"
@@ -8,17 +13,27 @@ import (
 
 // MergeProgramListSources merges multiple ProgramListSource instances into a single one.
 // It combines XTvgUrls and channels grouped by TvgName, ensuring no duplicate URLs
+// (compared in their canonical form, see urlx.Canonicalize)
 // and validating that TvgName matches Title before merging.
+// Sources are merged by priority in descending order and then by their order in the given slice,
+// so the URLs of higher priority sources come first within a channel and win the deduplication.
+// The TvgNames of each source are merged in sorted order, so a URL listed under several TvgNames
+// of a source is always kept under the same one.
 func MergeProgramListSources(sources []*ProgramListSource) (merged *ProgramListSource) {
 	merged = NewProgramListSource()
 	existUrl := types.NewSet[string]()
+	sources = slices.Clone(sources)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Priority > sources[j].Priority
+	})
 	for _, programListSource := range sources {
 		// Merge program list URLs
 		merged.XTvgUrls = append(merged.XTvgUrls, programListSource.XTvgUrls...)
 		merged.XTvgUrls = util.SliceUnion(merged.XTvgUrls)
 
 		// Merge channels by TvgName
-		for tvgName, channels := range programListSource.TvgNameChannels {
+		for _, tvgName := range slices.Sorted(maps.Keys(programListSource.TvgNameChannels)) {
+			channels := programListSource.TvgNameChannels[tvgName]
 			ch, ok := merged.TvgNameChannels[tvgName]
 			if !ok {
 				ch = make([]*Channel, 0, 16)
@@ -35,14 +50,15 @@ func MergeProgramListSources(sources []*ProgramListSource) (merged *ProgramListS
 					c.Title = c.TvgName
 				}
 				// Skip if URL already exists
-				if existUrl.Exist(c.Url) {
+				canonicalUrl := urlx.Canonicalize(c.Url)
+				if existUrl.Exist(canonicalUrl) {
 					log.Debug().Msg("Channel url already exists, skip.").
 						Str("url", c.Url).
 						Done()
 					continue
 				}
 				// Add URL to set and append channel
-				existUrl.Put(c.Url)
+				existUrl.Put(canonicalUrl)
 				ch = append(ch, c)
 			}
 			merged.TvgNameChannels[tvgName] = ch
@@ -0,0 +1,62 @@
+package m3u8x
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeProgramListSources(t *testing.T) {
+	low := NewProgramListSource()
+	low.XTvgUrls = []string{"http://epg/low.xml"}
+	low.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://low/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://shared/cctv1.m3u8"},
+	}
+	low.SetChannelSource("low")
+	high := NewProgramListSource()
+	high.XTvgUrls = []string{"http://epg/high.xml"}
+	high.Priority = 10
+	high.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://shared/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://high/cctv1.m3u8"},
+	}
+	high.SetChannelSource("high")
+	other := NewProgramListSource()
+	other.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://other/cctv1.m3u8"},
+	}
+	other.SetChannelSource("other")
+
+	sources := []*ProgramListSource{low, high, other}
+	merged := MergeProgramListSources(sources)
+	require.Equal(t, []*ProgramListSource{low, high, other}, sources)
+	require.Equal(t, []string{"http://epg/high.xml", "http://epg/low.xml"}, merged.XTvgUrls)
+
+	var urls, origins []string
+	for _, channel := range merged.TvgNameChannels["CCTV1"] {
+		urls = append(urls, channel.Url)
+		origins = append(origins, channel.Source)
+	}
+	require.Equal(t, []string{
+		"http://shared/cctv1.m3u8",
+		"http://high/cctv1.m3u8",
+		"http://low/cctv1.m3u8",
+		"http://other/cctv1.m3u8",
+	}, urls)
+	require.Equal(t, []string{"high", "high", "low", "other"}, origins)
+}
+
+func TestMergeProgramListSources_SharedUrl(t *testing.T) {
+	// the url listed under two tvg names of a source is always kept under the same one
+	for range 20 {
+		source := NewProgramListSource()
+		source.TvgNameChannels["CCTV5+"] = []*Channel{{TvgName: "CCTV5+", Url: "http://host/cctv5.m3u8"}}
+		source.TvgNameChannels["CCTV5"] = []*Channel{{TvgName: "CCTV5", Url: "http://host/cctv5.m3u8"}}
+		source.TvgNameChannels["CCTV1"] = []*Channel{{TvgName: "CCTV1", Url: "http://host/cctv1.m3u8"}}
+		merged := MergeProgramListSources([]*ProgramListSource{source})
+		require.Len(t, merged.TvgNameChannels["CCTV5"], 1)
+		require.Empty(t, merged.TvgNameChannels["CCTV5+"])
+		require.Len(t, merged.TvgNameChannels["CCTV1"], 1)
+	}
+}
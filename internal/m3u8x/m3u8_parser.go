@@ -6,8 +6,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/filex"
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
 	"github.com/rambollwong/rainbowlog/log"
 )
@@ -21,16 +23,21 @@ const (
 )
 
 type Channel struct {
-	TvgName string // TvgName is the name of channel
-	TvgLogo string // TvgLogo is the logo url of channel
-	Group   string // Group of the channel
-	Title   string // Title of the channel, usually consistent with TvgName
-	Url     string // Url of the channel's live source
+	TvgName string             // TvgName is the name of channel
+	TvgLogo string             // TvgLogo is the logo url of channel
+	Group   string             // Group of the channel
+	Title   string             // Title of the channel, usually consistent with TvgName
+	Url     string             // Url of the channel's live source
+	Source  string             // Source is the upstream url or local file the channel comes from
+	Label   string             // Label of the line, which is the "$" suffix of the url, e.g. 电信, 联通 or IPV6
+	Test    *ChannelTestResult // Test is the result of testing the url, nil if not tested
 }
 
 type ProgramListSource struct {
 	XTvgUrls        []string              // XTvgUrls means the Live Program List
 	TvgNameChannels map[string][]*Channel // TvgNameChannels are channels that grouped by TvgName
+	Priority        int64                 // Priority of the source when merging, higher first
+	Stale           bool                  // Stale means the source is the last mirrored copy because its upstream could not be fetched
 }
 
 func NewProgramListSource() *ProgramListSource {
@@ -40,13 +47,72 @@ func NewProgramListSource() *ProgramListSource {
 	}
 }
 
+// SetChannelSource records the upstream url or local file the channels of the source come from.
+// The channels whose source has already been recorded, e.g. by a nested source, are not changed.
+func (s *ProgramListSource) SetChannelSource(source string) {
+	for _, channels := range s.TvgNameChannels {
+		for _, channel := range channels {
+			if channel.Source == "" {
+				channel.Source = source
+			}
+		}
+	}
+}
+
+// ParseOptions are the options of parsing a source from a reader.
+type ParseOptions struct {
+	// MaxLineLength is the max length of a line, longer lines are skipped.
+	// Zero means filex.DefaultMaxLineLength.
+	MaxLineLength int
+	// Filter reports whether a channel with the tvg name and line label should be kept.
+	// It is applied as the channels are read, so that the filtered channels are never held in memory.
+	// Nil keeps all channels.
+	Filter func(tvgName, label string) bool
+}
+
+// MaxLineLengthOrDefault returns the max line length of the options.
+func (o *ParseOptions) MaxLineLengthOrDefault() int {
+	if o == nil || o.MaxLineLength <= 0 {
+		return filex.DefaultMaxLineLength
+	}
+	return o.MaxLineLength
+}
+
+// Keep reports whether a channel with the tvg name and line label should be kept by the options.
+func (o *ParseOptions) Keep(tvgName, label string) bool {
+	return o == nil || o.Filter == nil || o.Filter(tvgName, label)
+}
+
+// ParseProgramListSource parses the m3u content into the source.
 func (s *ProgramListSource) ParseProgramListSource(source []byte) (err error) {
-	scanner := bufio.NewScanner(bytes.NewReader(source))
-	lineNo := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNo++
-		if lineNo == 1 {
+	return s.ParseProgramListSourceFromReader(bytes.NewReader(source), nil)
+}
+
+// ParseProgramListSourceFromReader parses the m3u content read line by line from the reader
+// into the source, keeping only the channels accepted by the options.
+// Lines longer than the max line length of the options are skipped with a warning,
+// and a channel whose url line is skipped is dropped.
+func (s *ProgramListSource) ParseProgramListSourceFromReader(r io.Reader, opts *ParseOptions) (err error) {
+	lr := filex.NewLineReader(r, opts.MaxLineLengthOrDefault())
+	defer func() {
+		if lr.Skipped() > 0 {
+			log.Warn().Msg("Some lines are too long, skipped.").
+				Int("skipped", lr.Skipped()).
+				Int("max_line_length", opts.MaxLineLengthOrDefault()).
+				Done()
+		}
+	}()
+	// channel is the channel whose info has been read and is waiting for its url
+	var channel *Channel
+	for {
+		line, lineNo, err := lr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if lineNo == 1 && strings.HasPrefix(strings.ToUpper(strings.TrimSpace(line)), TagExtm3u) {
 			// read program list config
 			s.XTvgUrls, err = readXTvgUrlsFromLine(line)
 			if err != nil {
@@ -56,40 +122,62 @@ func (s *ProgramListSource) ParseProgramListSource(source []byte) (err error) {
 		}
 
 		// read info of channel
-		channel := &Channel{}
-		if !channel.readInfoFromLine(line) {
-			log.Warn().Msg("Can not read tvg info from line.").
-				Int("line_no", lineNo).Str("line", line).Done()
+		if strings.HasPrefix(line, TagExtinf) || channel == nil {
+			channel = &Channel{}
+			if !channel.readInfoFromLine(line) {
+				log.Warn().Msg("Can not read tvg info from line.").
+					Int("line_no", lineNo).Str("line", line).Done()
+				channel = nil
+			}
 			continue
 		}
-		if scanner.Scan() {
-			line = scanner.Text()
-			lineNo++
-			// read live stream url
-			channel.Url = strings.TrimSpace(line)
-			if dlIdx := strings.Index(channel.Url, "$"); dlIdx != -1 {
-				channel.Url = channel.Url[:dlIdx]
-			}
+		// skip other tags and empty lines between the info and url of the channel
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// read live stream url and line label
+		channel.Url, channel.Label = SplitUrlLabel(line)
+		if opts.Keep(channel.TvgName, channel.Label) {
 			s.TvgNameChannels[channel.TvgName] = append(s.TvgNameChannels[channel.TvgName], channel)
-
 		}
+		channel = nil
 	}
-	return scanner.Err()
 }
 
+// SplitUrlLabel splits a url line of DIYP-style lists into the url and the line label after "$".
+func SplitUrlLabel(line string) (url, label string) {
+	url, label, _ = strings.Cut(strings.TrimSpace(line), "$")
+	return strings.TrimSpace(url), strings.TrimSpace(label)
+}
+
+// readXTvgUrlsFromLine reads the program list urls from the x-tvg-url or url-tvg attribute of the
+// #EXTM3U header line. A header line without any of them is valid and has no urls.
 func readXTvgUrlsFromLine(line string) ([]string, error) {
-	arr := strings.Split(line, " ")
-	if len(arr) != 2 {
-		return nil, fmt.Errorf("invalid x-tvg-url line: %s", line)
-	}
-	if arr[0] != TagExtm3u {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(strings.ToUpper(line), TagExtm3u) {
 		return nil, fmt.Errorf("invalid tag of x-tvg-url line: %s", line)
 	}
-	arr = strings.Split(arr[1], "=")
-	if strings.ToLower(arr[0]) != "x-tvg-url" {
-		return nil, fmt.Errorf("invalid x-tvg-url line: %s", line)
+	lowerLine := strings.ToLower(line)
+	for _, attr := range []string{"x-tvg-url=", "url-tvg="} {
+		idx := strings.Index(lowerLine, attr)
+		if idx == -1 {
+			continue
+		}
+		value := line[idx+len(attr):]
+		if strings.HasPrefix(value, "\"") {
+			value, _, _ = strings.Cut(value[1:], "\"")
+		} else {
+			value, _, _ = strings.Cut(value, " ")
+		}
+		var urls []string
+		for _, u := range strings.Split(value, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+		return urls, nil
 	}
-	return strings.Split(strings.ReplaceAll(arr[1], "\"", ""), ","), nil
+	return nil, nil
 }
 
 func (c *Channel) readInfoFromLine(line string) bool {
@@ -161,7 +249,7 @@ func NewLiveStreamSource(sourceURL string) *LiveStreamSource {
 	}
 }
 
-func (s *LiveStreamSource) ParseLiveStreamSource(ctx context.Context, source []byte) error {
+func (s *LiveStreamSource) ParseLiveStreamSource(ctx context.Context, client *httpx.Client, source []byte) error {
 	scanner := bufio.NewScanner(bytes.NewReader(source))
 	lineNo := 0
 	for scanner.Scan() {
@@ -188,7 +276,7 @@ func (s *LiveStreamSource) ParseLiveStreamSource(ctx context.Context, source []b
 		} else if strings.HasPrefix(line, "#") {
 			log.Debug().Msg("unknown tag of line").Int("line_no", lineNo).Str("line", line).Done()
 		} else if strings.HasSuffix(line, ".m3u8") {
-			urlContent, err := httpx.LoadUrlContent(ctx, line)
+			urlContent, err := client.LoadUrlContent(ctx, line)
 			if err != nil {
 				log.Error().Msg("Failed to load channel url, ignore.").
 					Str("channel_url", line).
@@ -197,7 +285,7 @@ func (s *LiveStreamSource) ParseLiveStreamSource(ctx context.Context, source []b
 				return err
 			}
 			s.FileURI = line[:strings.LastIndex(line, "/")]
-			return s.ParseLiveStreamSource(ctx, urlContent)
+			return s.ParseLiveStreamSource(ctx, client, urlContent)
 		} else {
 			return errors.New("source information is incomplete")
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
 	"github.com/stretchr/testify/require"
 )
 
@@ -36,7 +37,7 @@ cctv1hd-373.ts
 
 func TestParseLiveStreamSource(t *testing.T) {
 	source := NewLiveStreamSource(baseUrl)
-	err := source.ParseLiveStreamSource(context.Background(), liveStreamSource)
+	err := source.ParseLiveStreamSource(context.Background(), httpx.NewClient(), liveStreamSource)
 	require.NoError(t, err, "ParseLiveStreamSource failed")
 }
 
@@ -7,31 +7,159 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/mediax"
 	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
 	"github.com/rambollwong/rainbowcat/pool"
 	"github.com/rambollwong/rainbowlog/log"
 )
 
+// maxTestSize is the max size downloaded from a stream or segment in a speed test.
+const maxTestSize = 10 * 1024 * 1024 // 10MB
+
+// TestRetryPolicies are the retry policies of the requests testing channel streams.
+type TestRetryPolicies struct {
+	// Playlist retries fetching m3u8 playlists.
+	Playlist *httpx.RetryPolicy
+	// Segment retries downloading segments and the streams which are not m3u8 playlists.
+	Segment *httpx.RetryPolicy
+}
+
+// TestOptions are the options of testing channel urls besides the speed.
+type TestOptions struct {
+	// Retry are the retry policies of the requests.
+	Retry TestRetryPolicies
+	// MaxStartupLatency fails the urls whose startup latency (see StartupLatency.Total) exceeds it, 0 is unlimited.
+	MaxStartupLatency time.Duration
+	// MaxFirstFrame fails the urls whose first decodable media bytes arrive later than it
+	// after requesting the media, 0 is unlimited.
+	MaxFirstFrame time.Duration
+	// MinResolutions are the min lines of the resolution of the channels by their main tvg names,
+	// which fail the urls of a lower or unknown resolution, see MinResolutionsOf.
+	MinResolutions map[string]int
+	// SpeedHeadroom is the min ratio of the download speed to the bitrate of the stream, 0 disables it,
+	// see SpeedThreshold.
+	SpeedHeadroom float64
+	// MinSpeeds are the min download speeds in kb/s of the channels by their main tvg names,
+	// which override the global one, see MinSpeedsOf.
+	MinSpeeds map[string]int64
+	// Slate detects the slate streams, nil disables it.
+	Slate *SlateDetector
+	// EpgClient pings the XTvgUrls through the candidate urls of its UrlRewriter, which should be the client
+	// fetching sources and EPGs. The client testing channel streams is used if it is nil.
+	EpgClient *httpx.Client
+}
+
+// speedThreshold returns the SpeedThreshold of the channel of the main tvg name,
+// whose min speed is the global loadMinSpeed if its group does not override it.
+func (opts *TestOptions) speedThreshold(tvgName string, loadMinSpeed int64) SpeedThreshold {
+	if minSpeed, ok := opts.MinSpeeds[tvgName]; ok {
+		loadMinSpeed = minSpeed
+	}
+	return SpeedThreshold{MinSpeed: float64(loadMinSpeed), Headroom: opts.SpeedHeadroom}
+}
+
+const (
+	SpeedModeAbsolute = "absolute" // SpeedModeAbsolute requires the download speed to reach the min speed
+	SpeedModeRelative = "relative" // SpeedModeRelative requires the download speed to exceed the bitrate of the stream by the headroom
+)
+
+// DefaultSpeedHeadroom is the default min ratio of the download speed to the bitrate of the stream in the relative mode.
+const DefaultSpeedHeadroom = 1.5
+
+// SpeedThreshold is the criterion of the download speed of a channel url.
+type SpeedThreshold struct {
+	// MinSpeed is the min download speed in kb/s, which applies if Headroom is 0 or the bitrate of the stream is unknown.
+	MinSpeed float64
+	// Headroom is the min ratio of the download speed to the bitrate of the stream, 0 disables it.
+	Headroom float64
+}
+
+// Passes reports whether the download speed in kb/s of the media stream meets the threshold.
+func (t SpeedThreshold) Passes(speed float64, media *mediax.StreamInfo) bool {
+	if t.Headroom > 0 && media != nil && media.Bitrate > 0 {
+		// the speed is in kilobytes per second and the bitrate is in bits per second
+		return speed*1024*8 >= t.Headroom*float64(media.Bitrate)
+	}
+	return speed >= t.MinSpeed
+}
+
+// SpeedHeadroomOf returns the headroom of the speed threshold config, which is 0 in the absolute mode.
+func SpeedHeadroomOf(cfg *proto.SpeedThreshold) (float64, error) {
+	switch strings.ToLower(cfg.GetMode()) {
+	case "", SpeedModeAbsolute:
+		return 0, nil
+	case SpeedModeRelative:
+		if cfg.GetHeadroom() > 0 {
+			return cfg.GetHeadroom(), nil
+		}
+		return DefaultSpeedHeadroom, nil
+	}
+	return 0, fmt.Errorf("unknown speed threshold mode: %s", cfg.GetMode())
+}
+
+// MinSpeedsOf returns the min download speeds in kb/s of the channels by their main tvg names,
+// from the ones of their groups overriding the global one.
+func MinSpeedsOf(groupList []*proto.GroupList) map[string]int64 {
+	minSpeeds := make(map[string]int64)
+	for _, list := range groupList {
+		if list.TestLoadMinSpeed <= 0 {
+			continue
+		}
+		for _, tvgName := range list.TvgName {
+			minSpeeds[MainTvgName(tvgName)] = list.TestLoadMinSpeed
+		}
+	}
+	return minSpeeds
+}
+
+// MinResolutionsOf returns the min lines of the resolution of the channels by their main tvg names,
+// from the min resolutions of their groups.
+func MinResolutionsOf(groupList []*proto.GroupList) (map[string]int, error) {
+	minResolutions := make(map[string]int)
+	for _, list := range groupList {
+		if list.MinResolution == "" {
+			continue
+		}
+		lines, err := mediax.ParseResolution(list.MinResolution)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", list.Group, err)
+		}
+		for _, tvgName := range list.TvgName {
+			minResolutions[MainTvgName(tvgName)] = lines
+		}
+	}
+	return minResolutions, nil
+}
+
 // ParallelTestProgramListSource filters the given ProgramListSource by testing the latency of XTvgUrls
 // and the download speed of channel streams in parallel using a worker pool.
+// The requests of channel streams are sent by the client, which should be the one testing them,
+// and those of XTvgUrls by the EPG client of the options. The urls are tested with the options.
 // It returns a new ProgramListSource containing only the URLs and channels that pass the tests.
 func ParallelTestProgramListSource(
 	ctx context.Context,
+	client *httpx.Client,
 	source *ProgramListSource,
-	minLatency, loadMinSpeed, retryTimes int64,
+	minLatency, loadMinSpeed int64,
+	opts *TestOptions,
 	workerPool *pool.WorkerPool,
 	groupList []*proto.GroupList,
-	hostCustomUA map[string]string,
 ) (filteredSource *ProgramListSource) {
 	// Initialize the filtered source and synchronization primitives
 	filteredSource = NewProgramListSource()
 	mu := sync.Mutex{}
 	wg := sync.WaitGroup{}
+	epgClient := opts.EpgClient
+	if epgClient == nil {
+		epgClient = client
+	}
 
 	// Test each XTvgUrl for latency
 	for _, tvgUrl := range source.XTvgUrls {
@@ -42,8 +170,9 @@ func ParallelTestProgramListSource(
 		wg.Add(1)
 		testFunc := func() {
 			defer wg.Done()
-			// Ping the URL to measure latency
-			latency, err := httpx.PingURL(tvgUrl)
+			// Ping the URL to measure latency, through its candidate urls if it is rewritten,
+			// the original url is kept in the output
+			latency, err := epgClient.PingURLWithRetry(ctx, tvgUrl, nil)
 			if err != nil {
 				log.Error().Msg("Failed to ping tvg url, ignore.").Err(err).Done()
 				return
@@ -71,281 +200,540 @@ func ParallelTestProgramListSource(
 		}
 	}
 
-	// Test each channel in the program list
-	hostGroupChannels := make(map[string]map[string][]*Channel) // host -> tvgName -> channels
-	for _, list := range groupList {
-		for _, tvgName := range list.TvgName {
-			tvgNames := splitTvgNames(tvgName) // Support merging multiple tvgNames
-			tvgNameMain := tvgNames[0]         // Use the first tvgName as the main tvgName
-			// Initialize the channel slice in the filtered source if it doesn't exist
-			if _, exists := filteredSource.TvgNameChannels[tvgNameMain]; !exists {
-				filteredSource.TvgNameChannels[tvgNameMain] = make([]*Channel, 0, 8)
-			}
-			var chs []*Channel
-			for _, tn := range tvgNames {
-				channels, exist := source.TvgNameChannels[tn]
-				if !exist {
-					continue
-				}
-				chs = append(chs, channels...)
-			}
-			if len(chs) == 0 {
-				continue
+	// Test each channel in the program list, each unique url is tested once for all the channels referencing it
+	plan := newTestPlan(source, groupList)
+	log.Info().Msg("Channel urls are planned to test.").
+		Int("channels", plan.stats.Channels).
+		Int("unique_urls", plan.stats.UniqueUrls).
+		Int("shared_urls", plan.stats.SharedUrls).
+		Int("hosts", plan.stats.Hosts).
+		Int("saved_tests", plan.stats.Saved()).
+		Done()
+	for _, tvgName := range plan.tvgNames {
+		// Initialize the channel slice in the filtered source
+		filteredSource.TvgNameChannels[tvgName] = make([]*Channel, 0, 8)
+	}
+
+	// Dispatch the urls from a global queue taking turns between the hosts, so that all the workers are kept busy,
+	// while each host is tested by no more workers than its concurrency limited by the client
+	scheduler := newTestScheduler(plan.jobs, client.MaxConcurrency)
+	stopProgress := make(chan struct{})
+	go scheduler.logProgress(testProgressInterval, stopProgress)
+	for {
+		job, ok := scheduler.dispatch(ctx)
+		if !ok {
+			break
+		}
+
+		wg.Add(1)
+		testFunc := func() {
+			defer wg.Done()
+
+			// Log the start of channel URL testing
+			first := job.refs[0]
+			log.Info().Msg("Testing channel url...").
+				Str("tvg_name", first.tvgName).
+				Str("channel_url", first.ch.Url).
+				Str("host", job.host).
+				Int("channels", len(job.refs)).
+				Done()
+			testThreshold := job.threshold(opts, loadMinSpeed)
+			result := testChannelUrl(ctx, client, first.ch, first.tvgName, testThreshold, opts)
+			// The url passes if it is kept for any channel referencing it
+			passed := false
+			defer func() { scheduler.finish(job, passed) }()
+			if ctx.Err() != nil {
+				return
 			}
 
-			log.Info().Int("number_of_channels_waiting_for_testing", len(chs)).Str("tvg_name", tvgNameMain).Done()
-			// Iterate over each channel for the current tvgName
-			for _, ch := range chs {
-				if strings.Contains(ch.Url, "audio") {
+			opts.Slate.observe(first.ch.Url, result)
+			// Fan out the result to all the channels referencing the url
+			for _, ref := range job.refs {
+				tvgName, ch := ref.tvgName, ref.ch
+				if result.Slate != "" {
+					opts.Slate.report(tvgName, ch.Url, result.Slate, result.SlateHash)
+				}
+				if !result.Passed {
 					continue
 				}
-
-				// Group all channels by host first, then test each host sequentially
-				// This approach prevents test failures due to server request rate limiting
-				u, err := url.Parse(ch.Url)
-				if err != nil {
-					log.Error().Msg("Failed to parse channel url, ignore.").
-						Str("tvg_name", tvgNameMain).
+				// The url is tested with the lowest speed threshold of the channels referencing it
+				if threshold := opts.speedThreshold(tvgName, loadMinSpeed); threshold.MinSpeed > testThreshold.MinSpeed &&
+					!threshold.Passes(result.Speed, result.Media) {
+					log.Warn().Msg("Channel url load speed is too low, ignore.").
+						Str("tvg_name", tvgName).
 						Str("channel_url", ch.Url).
+						Float64("speed", result.Speed).
+						Int64("bitrate", result.Media.GetBitrate()).
 						Done()
-					return
-				}
-				host := u.Host
-				hostChannels, exist := hostGroupChannels[host]
-				if !exist {
-					hostChannels = make(map[string][]*Channel)
+					continue
 				}
-				hostTvgChs, exist := hostChannels[tvgNameMain]
-				if !exist {
-					hostTvgChs = make([]*Channel, 0, 16)
+				// The resolution is required by the group, which does not tell anything about the host
+				if minLines := opts.MinResolutions[tvgName]; minLines > 0 && result.Media.Lines() < minLines {
+					log.Warn().Msg("Channel url resolution is too low, ignore.").
+						Str("tvg_name", tvgName).
+						Str("channel_url", ch.Url).
+						Str("resolution", result.Media.Resolution()).
+						Int("min_lines", minLines).
+						Done()
+					continue
 				}
-				hostTvgChs = append(hostTvgChs, ch)
-				hostChannels[tvgNameMain] = hostTvgChs
-				hostGroupChannels[host] = hostChannels
+				ch.Test = result
+				passed = true
+
+				// Add the channel to the filtered source
+				mu.Lock()
+				filteredSource.TvgNameChannels[tvgName] = append(filteredSource.TvgNameChannels[tvgName], ch)
+				mu.Unlock()
+				log.Info().Msg("Channel is ok.").
+					Str("tvg_name", tvgName).
+					Str("channel_url", ch.Url).
+					Str("startup", result.Startup.Total().String()).
+					Str("resolution", result.Media.Resolution()).
+					Done()
+			}
+		}
+
+		// Submit the channel test function to the worker pool, which blocks until a worker is idle
+		if err := workerPool.Submit(testFunc); err != nil {
+			if !errors.Is(err, pool.ErrWorkerPoolClosed) && !errors.Is(err, pool.ErrWorkerPoolClosing) {
+				log.Warn().Msg("Failed to submit test task").
+					Err(err).
+					Done()
 			}
+			scheduler.finish(job, false)
+			wg.Done()
+			break
 		}
 	}
 
-	for _, list := range groupList {
-		for _, tvgName := range list.TvgName {
-			tvgName := splitTvgNames(tvgName)[0]
-			for host, tvgChs := range hostGroupChannels {
-				customUA := hostCustomUA[host]
-				chs, exist := tvgChs[tvgName]
-				if !exist {
-					continue
-				}
+	// Wait for the tvg url tests in case there is no channel to test
+	wg.Wait()
+	close(stopProgress)
+	progress := scheduler.Progress()
+	log.Info().Msg("Channel urls are tested.").
+		Int("done", progress.Done).
+		Int("total", progress.Total).
+		Int("passed", progress.Passed).
+		Done()
+	opts.Slate.removeCrossChannelSlates(filteredSource)
 
-				wg.Add(1)
-				testFunc := func() {
-					defer wg.Done()
-
-					for _, ch := range chs {
-						// Log the start of channel URL testing
-						log.Info().Msg("Testing channel url...").
-							Str("tvg_name", tvgName).
-							Str("channel_url", ch.Url).
-							Str("host", host).
-							Done()
-
-						u, err := url.Parse(ch.Url)
-						if err != nil {
-							log.Error().Msg("Failed to parse channel url, ignore.").
-								Str("tvg_name", tvgName).
-								Str("channel_url", ch.Url).
-								Done()
-							return
-						}
-						if strings.HasSuffix(u.Path, ".m3u8") {
-							if !TestM3u8DownloadSpeedWithRetry(
-								ctx, ch.Url, customUA, float64(loadMinSpeed), retryTimes) {
-								return
-							}
-						} else {
-							speed, err := httpx.TestDownloadSpeed(ctx, ch.Url)
-							if err != nil {
-								if errors.Is(err, context.Canceled) {
-									return
-								}
-								log.Error().Msg("Failed to test channel url load speed, ignore.").
-									Str("tvg_name", tvgName).
-									Str("channel_url", ch.Url).
-									Done()
-								return
-							}
-							if speed < float64(loadMinSpeed) {
-								log.Warn().Msg("Channel url load speed is too low, ignore.").
-									Str("tvg_name", tvgName).
-									Str("channel_url", ch.Url).
-									Float64("speed", speed).
-									Done()
-								return
-							}
-						}
-
-						// Add the channel to the filtered source and break to avoid duplicates
-						mu.Lock()
-						filteredSource.TvgNameChannels[tvgName] = append(filteredSource.TvgNameChannels[tvgName], ch)
-						mu.Unlock()
-						log.Info().Msg("Channel is ok.").
-							Str("tvg_name", tvgName).
-							Str("channel_url", ch.Url).
-							Done()
-					}
-				}
+	// Restore the order of the merged source, which is changed by the completion order of the tests
+	tvgUrlOrder := make(map[string]int, len(source.XTvgUrls))
+	for i, tvgUrl := range source.XTvgUrls {
+		tvgUrlOrder[tvgUrl] = i
+	}
+	sort.SliceStable(filteredSource.XTvgUrls, func(i, j int) bool {
+		return tvgUrlOrder[filteredSource.XTvgUrls[i]] < tvgUrlOrder[filteredSource.XTvgUrls[j]]
+	})
+	for _, chs := range filteredSource.TvgNameChannels {
+		sort.SliceStable(chs, func(i, j int) bool {
+			return plan.channelOrder[chs[i]] < plan.channelOrder[chs[j]]
+		})
+	}
 
-				// Submit the channel test function to the worker pool
-				if err := workerPool.Submit(testFunc); err != nil {
-					if !errors.Is(err, pool.ErrWorkerPoolClosed) && !errors.Is(err, pool.ErrWorkerPoolClosing) {
-						log.Warn().Msg("Failed to submit test task").
-							Err(err).
-							Done()
-					}
-				}
-			}
+	return filteredSource
+}
 
-			// Wait for all tests to complete
-			wg.Wait()
-		}
+// ChannelTestResult is the result of testing the url of a channel.
+type ChannelTestResult struct {
+	Passed  bool           // Passed means the url passed the test
+	Speed   float64        // Speed is the download speed of the stream in kb/s
+	TTFB    time.Duration  // TTFB is the time to first byte of the stream, of its first tested segment for m3u8
+	Startup StartupLatency // Startup is the startup latency of the url
+	// Media is the information of the media stream probed in the test, of the first tested segment for m3u8,
+	// completed by the variant stream declared in the master playlist. It is nil if nothing is known.
+	Media *mediax.StreamInfo
+	// Segments are the segments downloaded in the test of an m3u8 url, including the reloaded ones.
+	Segments []SegmentSample
+	// Slate is the reason why the url is detected as a slate stream, see SlateReason*, empty if it is not.
+	Slate string
+	// SlateHash is the hash of the segment the url is detected as a slate stream by.
+	SlateHash string
+}
+
+// StartupLatency is the time a player needs to start playing a channel url, measured in its test.
+type StartupLatency struct {
+	DNS      time.Duration // DNS is the duration of the DNS lookup of the first request
+	Connect  time.Duration // Connect is the duration of connecting of the first request
+	TLS      time.Duration // TLS is the duration of the TLS handshake of the first request
+	Playlist time.Duration // Playlist is the duration of fetching the m3u8 playlist, zero if the url is not m3u8
+	// FirstFrame is the time from requesting the media to receiving its first decodable bytes,
+	// or its first byte if the media format is unknown.
+	FirstFrame time.Duration
+}
+
+// Total returns the time from requesting the url to receiving the first decodable media bytes.
+func (l StartupLatency) Total() time.Duration {
+	return l.Playlist + l.FirstFrame
+}
+
+// firstFrameOf returns the first frame time of the speed test, or its time to first byte if not detected.
+func firstFrameOf(speed *httpx.SpeedResult) time.Duration {
+	if speed.FirstFrame > 0 {
+		return speed.FirstFrame
 	}
+	return speed.TTFB
+}
 
-	return filteredSource
+// testChannelUrl tests the download speed and the startup latency of the channel url
+// within the test duration of the client.
+func testChannelUrl(
+	ctx context.Context,
+	client *httpx.Client,
+	ch *Channel,
+	tvgName string,
+	threshold SpeedThreshold,
+	opts *TestOptions,
+) *ChannelTestResult {
+	result := testChannelUrlSpeed(ctx, client, ch, tvgName, threshold, opts)
+	if !result.Passed {
+		return result
+	}
+	startup := result.Startup
+	if (opts.MaxStartupLatency > 0 && startup.Total() > opts.MaxStartupLatency) ||
+		(opts.MaxFirstFrame > 0 && startup.FirstFrame > opts.MaxFirstFrame) {
+		log.Warn().Msg("Channel url starts too slowly, ignore.").
+			Str("tvg_name", tvgName).
+			Str("channel_url", ch.Url).
+			Str("startup", startup.Total().String()).
+			Str("dns", startup.DNS.String()).
+			Str("connect", startup.Connect.String()).
+			Str("tls", startup.TLS.String()).
+			Str("playlist", startup.Playlist.String()).
+			Str("first_frame", startup.FirstFrame.String()).
+			Done()
+		result.Passed = false
+		return result
+	}
+	if reason, hash := opts.Slate.inspect(result.Segments); reason != "" {
+		result.Passed = false
+		result.Slate, result.SlateHash = reason, hash
+	}
+	return result
+}
+
+// testChannelUrlSpeed tests the download speed of the channel url within the test duration of the client.
+func testChannelUrlSpeed(
+	ctx context.Context,
+	client *httpx.Client,
+	ch *Channel,
+	tvgName string,
+	threshold SpeedThreshold,
+	opts *TestOptions,
+) *ChannelTestResult {
+	u, err := url.Parse(ch.Url)
+	if err != nil {
+		log.Error().Msg("Failed to parse channel url, ignore.").
+			Str("tvg_name", tvgName).
+			Str("channel_url", ch.Url).
+			Done()
+		return &ChannelTestResult{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, client.Timeouts().TestDuration)
+	defer cancel()
+	if strings.HasSuffix(u.Path, ".m3u8") {
+		return TestM3u8DownloadSpeed(ctx, client, ch.Url, threshold, opts)
+	}
+	var speed *httpx.SpeedResult
+	err = opts.Retry.Segment.Do(ctx, func(ctx context.Context) (err error) {
+		speed, err = client.SpeedTest(ctx, ch.Url, maxTestSize)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return &ChannelTestResult{}
+		}
+		log.Error().Msg("Failed to test channel url load speed, ignore.").
+			Str("tvg_name", tvgName).
+			Str("channel_url", ch.Url).
+			Err(err).
+			Done()
+		return &ChannelTestResult{}
+	}
+	result := &ChannelTestResult{
+		Speed: speed.Kbps,
+		TTFB:  speed.TTFB,
+		Startup: StartupLatency{
+			DNS:        speed.DNS,
+			Connect:    speed.Connect,
+			TLS:        speed.TLS,
+			FirstFrame: firstFrameOf(speed),
+		},
+		Media: speed.Stream,
+	}
+	if !threshold.Passes(speed.Kbps, result.Media) {
+		log.Warn().Msg("Channel url load speed is too low, ignore.").
+			Str("tvg_name", tvgName).
+			Str("channel_url", ch.Url).
+			Float64("speed", speed.Kbps).
+			Int64("bitrate", result.Media.GetBitrate()).
+			Str("ttfb", speed.TTFB.String()).
+			Done()
+		return result
+	}
+	result.Passed = true
+	return result
 }
 
 // TestM3u8DownloadSpeed tests the download speed of media data corresponding to an m3u8 URL.
-// Input: Network URL of the m3u8 file and the required minimum download speed (kb/s).
-// Output: Returns true if any ts segment meets the speed requirement, otherwise returns false; along with possible error.
-func TestM3u8DownloadSpeed(ctx context.Context, m3u8URL, customUA string, requiredSpeed float64) bool {
-	// Download and parse the m3u8 file to get .ts segment URLs (first and last one)
-	tsURLs, err := getFirstAndLastTsSegmentURL(ctx, m3u8URL, customUA)
+// Input: Network URL of the m3u8 file and the speed threshold, which is relative to the bitrate of the stream
+// probed from the first downloaded segment if it has a headroom.
+// Output: Returns the result which passed if any ts segment meets the speed requirement,
+// with the speed and the time to first byte of the first downloaded segment.
+// Transient failures of fetching the m3u8 file and the segments are retried by the retry policies of the options,
+// but a low speed is never retried. If the slate detection of the options is enabled, all the segments are tested
+// and the m3u8 file is reloaded after the reload delay to test its new last segment.
+func TestM3u8DownloadSpeed(
+	ctx context.Context,
+	client *httpx.Client,
+	m3u8URL string,
+	threshold SpeedThreshold,
+	opts *TestOptions,
+) *ChannelTestResult {
+	retry := &opts.Retry
+	result := &ChannelTestResult{}
+	// Download and parse the m3u8 file to get .ts segments (first and last one)
+	var (
+		segments []playlistSegment
+		variant  *playlistVariant
+	)
+	err := retry.Playlist.Do(ctx, func(ctx context.Context) (err error) {
+		segments, variant, result.Startup, err = getFirstAndLastTsSegments(ctx, client, m3u8URL)
+		return err
+	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
-			return false
+			return result
 		}
 		log.Error().Msg("Failed to download m3u8 file, ignore.").
 			Str("m3u8_url", m3u8URL).Err(err).
 			Done()
-		return false
+		return result
 	}
 
-	// Test the download speed of .ts segments (limit max download to 10MB per segment to avoid resource waste)
-	const maxTestSize = 10 * 1024 * 1024 // 10MB
+	// Test the download speed of .ts segments (limit max download to maxTestSize per segment to avoid resource waste)
 	var totalSpeed float64
-	for _, tsURL := range tsURLs {
-		speed, err := testFileDownloadSpeed(ctx, tsURL, customUA, maxTestSize)
+	for _, segment := range segments {
+		var speed *httpx.SpeedResult
+		err := retry.Segment.Do(ctx, func(ctx context.Context) (err error) {
+			speed, err = client.SpeedTest(ctx, segment.url, maxTestSize)
+			return err
+		})
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
-				return false
+				return result
 			}
 			log.Error().Msg("Failed to test file download speed, skip this file.").
 				Str("m3u8_url", m3u8URL).
-				Str("file_url", tsURL).Err(err).
+				Str("file_url", segment.url).Err(err).
 				Done()
 			continue
 		}
-		totalSpeed += speed
-		// If any segment meets the speed requirement, return success immediately
-		if speed >= requiredSpeed {
+		if result.TTFB == 0 {
+			result.TTFB = speed.TTFB
+			result.Startup.FirstFrame = firstFrameOf(speed)
+			result.Media = segmentStreamInfo(speed, segment, variant)
+		}
+		result.Segments = append(result.Segments, SegmentSample{Url: segment.url, Size: speed.Downloaded, Hash: speed.SHA256})
+		totalSpeed += speed.Kbps
+		// If any segment meets the speed requirement, return success immediately unless all segments are compared
+		if threshold.Passes(speed.Kbps, result.Media) && opts.Slate == nil {
 			break
 		}
 	}
 
 	// If multiple segments were tested, calculate the average speed for judgment
-	if len(tsURLs) > 1 {
-		totalSpeed = totalSpeed / float64(len(tsURLs))
+	if len(segments) > 1 {
+		totalSpeed = totalSpeed / float64(len(segments))
+	}
+	if result.Media == nil && variant != nil && variant.width > 0 {
+		// no segment is downloaded, the declared variant stream is all that is known
+		result.Media = &mediax.StreamInfo{Width: variant.width, Height: variant.height, Bitrate: variant.bandwidth}
 	}
+	result.Speed = totalSpeed
 	// Return success if average speed meets the requirement
-	if totalSpeed >= requiredSpeed {
+	if threshold.Passes(totalSpeed, result.Media) {
 		log.Info().Msg("File download speed is ok.").
 			Float64("kbps", totalSpeed).
+			Int64("bitrate", result.Media.GetBitrate()).
+			Str("ttfb", result.TTFB.String()).
 			Str("m3u8_url", m3u8URL).
 			Done()
-		return true
+		result.Passed = true
+		if delay := opts.Slate.ReloadDelay(); delay > 0 {
+			if segment := reloadLastSegment(ctx, client, m3u8URL, delay, retry); segment != nil {
+				result.Segments = append(result.Segments, *segment)
+			}
+		}
+		return result
 	}
 	// None of the segments meet the speed requirement
 	log.Warn().Msg("M3u8 url load speed is too low, ignore.").
 		Str("m3u8_url", m3u8URL).
 		Float64("kbps", totalSpeed).
+		Int64("bitrate", result.Media.GetBitrate()).
+		Str("ttfb", result.TTFB.String()).
 		Done()
-	return false
+	return result
 }
 
-// TestM3u8DownloadSpeedWithRetry tests the download speed of an m3u8 URL with retry logic.
-// It attempts to test the download speed up to retryTimes+1 times (1 initial attempt + retryTimes retries).
-// Returns true if the test passes within the required speed at least once, otherwise returns false.
-func TestM3u8DownloadSpeedWithRetry(
-	ctx context.Context,
-	m3u8URL, customUA string,
-	requiredSpeed float64,
-	retryTimes int64,
-) bool {
-	var i int64
-	for {
-		i++
-		if TestM3u8DownloadSpeed(ctx, m3u8URL, customUA, requiredSpeed) {
-			return true
+// segmentStreamInfo returns the information of the media stream probed in the speed test of the segment,
+// with the bitrate from the segment duration if it is not measured from the PCR, and the resolution and the bandwidth
+// of the variant stream declared in the master playlist if they are not probed.
+func segmentStreamInfo(speed *httpx.SpeedResult, segment playlistSegment, variant *playlistVariant) *mediax.StreamInfo {
+	info := &mediax.StreamInfo{}
+	if speed.Stream != nil {
+		*info = *speed.Stream
+	}
+	if info.Bitrate == 0 && speed.Complete && segment.duration > 0 {
+		info.Bitrate = int64(float64(speed.Downloaded*8) / segment.duration)
+	}
+	if variant != nil {
+		if !info.HasResolution() {
+			info.Width, info.Height = variant.width, variant.height
 		}
-		if i > retryTimes {
-			return false
+		if info.Bitrate == 0 {
+			info.Bitrate = variant.bandwidth
 		}
-		log.Debug().Msg("Failed to test m3u8 download speed, retrying...").
-			Str("m3u8_url", m3u8URL).
-			Int64("retry_times", i).
-			Done()
 	}
+	if info.Format == mediax.FormatUnknown && !info.HasResolution() && info.Bitrate == 0 {
+		return nil
+	}
+	return info
 }
 
-// getFirstAndLastTsSegmentURL extracts the first and last valid .ts segment URLs from an m3u8 file.
-func getFirstAndLastTsSegmentURL(ctx context.Context, m3u8URL, customUA string) ([]string, error) {
-	// Download m3u8 file content
-	req, err := http.NewRequestWithContext(ctx, "GET", m3u8URL, nil)
+// reloadLastSegment reloads the m3u8 file after the delay and downloads its last segment,
+// or returns nil if it fails, which is not a failure of the test.
+func reloadLastSegment(
+	ctx context.Context,
+	client *httpx.Client,
+	m3u8URL string,
+	delay time.Duration,
+	retry *TestRetryPolicies,
+) *SegmentSample {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(delay):
+	}
+	var segments []playlistSegment
+	err := retry.Playlist.Do(ctx, func(ctx context.Context) (err error) {
+		segments, _, _, err = getFirstAndLastTsSegments(ctx, client, m3u8URL)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		log.Debug().Msg("Failed to reload m3u8 file, skip comparing its segments.").
+			Str("m3u8_url", m3u8URL).Err(err).
+			Done()
+		return nil
 	}
-	if customUA == "" {
-		req.Header.Set("User-Agent", httpx.UA)
-	} else {
-		req.Header.Set("User-Agent", customUA)
+	last := segments[len(segments)-1]
+	var speed *httpx.SpeedResult
+	err = retry.Segment.Do(ctx, func(ctx context.Context) (err error) {
+		speed, err = client.SpeedTest(ctx, last.url, maxTestSize)
+		return err
+	})
+	if err != nil {
+		log.Debug().Msg("Failed to download the reloaded segment, skip comparing it.").
+			Str("m3u8_url", m3u8URL).
+			Str("file_url", last.url).Err(err).
+			Done()
+		return nil
 	}
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Cache-Control", "no-cache")
+	return &SegmentSample{Url: last.url, Size: speed.Downloaded, Hash: speed.SHA256}
+}
 
-	resp, err := httpx.HttpClient.Do(req)
+// getFirstAndLastTsSegments extracts the first and last valid .ts segments from an m3u8 file.
+// If it is a master playlist, the media playlist of its variant stream of the highest bandwidth is used,
+// which is returned with the segments.
+// It also returns the startup latency of fetching the m3u8 files, without the first frame.
+func getFirstAndLastTsSegments(
+	ctx context.Context,
+	client *httpx.Client,
+	m3u8URL string,
+) ([]playlistSegment, *playlistVariant, StartupLatency, error) {
+	var startup StartupLatency
+	ctx, trace := httpx.WithRequestTrace(ctx)
+	pl, err := fetchPlaylist(ctx, client, m3u8URL)
 	if err != nil {
-		return nil, err
+		return nil, nil, startup, err
 	}
-	defer resp.Body.Close()
+	startup.DNS, startup.Connect, startup.TLS = trace.Phases()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed, status code: %d", resp.StatusCode)
+	var variant *playlistVariant
+	if len(pl.variants) > 0 {
+		variant = pl.bestVariant()
+		if pl, err = fetchPlaylist(ctx, client, variant.url); err != nil {
+			return nil, variant, startup, fmt.Errorf("failed to load variant stream: %w", err)
+		}
+		if len(pl.variants) > 0 {
+			return nil, variant, startup, fmt.Errorf("nested master playlist")
+		}
 	}
+	startup.Playlist = trace.Since()
 
-	m3u8Content, err := httpx.LoadUrlContent(ctx, m3u8URL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load content: %v", err.Error())
+	l := len(pl.segments)
+	if l == 0 {
+		return nil, variant, startup, fmt.Errorf("ts segment not found")
+	}
+	if l == 1 {
+		return pl.segments, variant, startup, nil
 	}
 
-	// Parse m3u8 content to extract .ts segment URLs
-	tsURLs, err := parseTsSegments(string(m3u8Content), m3u8URL)
+	return []playlistSegment{pl.segments[0], pl.segments[l-1]}, variant, startup, nil
+}
+
+// fetchPlaylist downloads and parses an m3u8 file.
+func fetchPlaylist(ctx context.Context, client *httpx.Client, m3u8URL string) (*playlist, error) {
+	resp, err := getNoCache(ctx, client, m3u8URL)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	l := len(tsURLs)
-	if l == 0 {
-		return nil, fmt.Errorf("ts segment not found")
-	}
-	if l == 1 {
-		return []string{tsURLs[0]}, nil
+	m3u8Content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load content: %w", err)
 	}
+	return parsePlaylist(string(m3u8Content), m3u8URL)
+}
+
+// playlist is a parsed m3u8 file, which is a master playlist listing variant streams,
+// or a media playlist listing segments.
+type playlist struct {
+	variants []playlistVariant
+	segments []playlistSegment
+}
+
+// playlistVariant is a variant stream declared by #EXT-X-STREAM-INF in a master playlist.
+type playlistVariant struct {
+	url           string
+	bandwidth     int64 // bandwidth is the BANDWIDTH in bits per second
+	width, height int   // width and height are the RESOLUTION, zero if absent
+}
+
+// playlistSegment is a segment of a media playlist.
+type playlistSegment struct {
+	url      string
+	duration float64 // duration is the #EXTINF duration in seconds, zero if absent
+}
 
-	return []string{tsURLs[0], tsURLs[l-1]}, nil
+// bestVariant returns the variant stream of the highest bandwidth, or of the highest resolution if they are equal.
+func (pl *playlist) bestVariant() *playlistVariant {
+	best := &pl.variants[0]
+	for i := range pl.variants {
+		v := &pl.variants[i]
+		if v.bandwidth > best.bandwidth || (v.bandwidth == best.bandwidth && v.width*v.height > best.width*best.height) {
+			best = v
+		}
+	}
+	return best
 }
 
-// parseTsSegments parses all .ts segment absolute URLs from m3u8 content.
-func parseTsSegments(m3u8Content, baseURL string) ([]string, error) {
+// parsePlaylist parses the variant streams or the segments with absolute URLs from m3u8 content.
+func parsePlaylist(m3u8Content, baseURL string) (*playlist, error) {
 	lines := strings.Split(m3u8Content, "\n")
-	var tsURLs []string
+	pl := &playlist{}
 
 	// Parse base URL for relative path concatenation
 	parsedBaseURL, err := url.Parse(baseURL)
@@ -353,91 +741,94 @@ func parseTsSegments(m3u8Content, baseURL string) ([]string, error) {
 		return nil, fmt.Errorf("parse base url failed")
 	}
 
+	var (
+		streamInf *playlistVariant // streamInf is the #EXT-X-STREAM-INF of the next URL
+		duration  float64          // duration is the #EXTINF duration of the next URL
+	)
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
-		// Skip m3u8 comment lines (starting with #)
 		if strings.HasPrefix(line, "#") {
+			if attrs, ok := strings.CutPrefix(line, "#EXT-X-STREAM-INF:"); ok {
+				streamInf = parseStreamInf(attrs)
+			} else if inf, ok := strings.CutPrefix(line, TagExtinf+":"); ok {
+				durationStr, _, _ := strings.Cut(inf, ",")
+				duration, _ = strconv.ParseFloat(strings.TrimSpace(durationStr), 64)
+			}
+			// Skip other m3u8 comment lines (starting with #)
 			continue
 		}
 		// Handle relative path .ts files
-		tsURL, err := parsedBaseURL.Parse(line)
+		u, err := parsedBaseURL.Parse(line)
 		if err != nil {
 			continue // Skip invalid URLs
 		}
-		tsURLs = append(tsURLs, tsURL.String())
+		if streamInf != nil {
+			streamInf.url = u.String()
+			pl.variants = append(pl.variants, *streamInf)
+		} else {
+			pl.segments = append(pl.segments, playlistSegment{url: u.String(), duration: duration})
+		}
+		streamInf, duration = nil, 0
 	}
 
-	return tsURLs, nil
+	return pl, nil
 }
 
-// testFileDownloadSpeed tests the download speed of a specified URL and returns kb/s.
-func testFileDownloadSpeed(ctx context.Context, fileURL, customUA string, maxDownloadSize int64) (float64, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
-	if err != nil {
-		return 0, err
-	}
-	if customUA == "" {
-		req.Header.Set("User-Agent", httpx.UA)
-	} else {
-		req.Header.Set("User-Agent", customUA)
-	}
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	resp, err := httpx.HttpClient.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("failed to load ts file")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("request failed, status code: %d", resp.StatusCode)
-	}
-
-	// Start timing and download data
-	startTime := time.Now()
-	buffer := make([]byte, 32*1024) // 32KB buffer
-	var downloadedBytes int64
-
-	for {
-		// Check if maximum download size is reached
-		if downloadedBytes >= maxDownloadSize {
-			break
-		}
-
-		// Calculate remaining bytes to download
-		remaining := maxDownloadSize - downloadedBytes
-		if remaining < int64(len(buffer)) {
-			buffer = buffer[:remaining]
-		}
-
-		// Read data
-		n, err := resp.Body.Read(buffer)
-		if n > 0 {
-			downloadedBytes += int64(n)
+// parseStreamInf parses the BANDWIDTH and RESOLUTION attributes of an #EXT-X-STREAM-INF tag.
+func parseStreamInf(attrs string) *playlistVariant {
+	v := &playlistVariant{}
+	for _, attr := range splitAttributes(attrs) {
+		key, value, _ := strings.Cut(attr, "=")
+		switch strings.TrimSpace(key) {
+		case "BANDWIDTH":
+			v.bandwidth, _ = strconv.ParseInt(value, 10, 64)
+		case "RESOLUTION":
+			w, h, _ := strings.Cut(value, "x")
+			v.width, _ = strconv.Atoi(w)
+			v.height, _ = strconv.Atoi(h)
 		}
+	}
+	return v
+}
 
-		// Handle read errors
-		if err != nil {
-			if err == io.EOF {
-				break // Normal end (file smaller than max test size)
-			}
-			return 0, err
+// splitAttributes splits an attribute list by the commas outside the quoted strings, e.g. CODECS="avc1,mp4a".
+func splitAttributes(attrs string) []string {
+	var (
+		res    []string
+		quoted bool
+		start  int
+	)
+	for i, c := range attrs {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			res = append(res, attrs[start:i])
+			start = i + 1
 		}
 	}
+	return append(res, attrs[start:])
+}
 
-	// Calculate download speed (kb/s = (bytes / 1024) / seconds)
-	elapsedSeconds := time.Since(startTime).Seconds()
-	if elapsedSeconds <= 0 {
-		return 0, fmt.Errorf("wrong elapsed seconds")
+// getNoCache sends a GET request bypassing caches to the url of a stream, and returns the response
+// if the status code is OK (200), whose body must be closed by the caller.
+func getNoCache(ctx context.Context, client *httpx.Client, streamUrl string) (*http.Response, error) {
+	req, err := client.NewRequest(ctx, http.MethodGet, streamUrl)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Cache-Control", "no-cache")
 
-	speedKbPerSec := float64(downloadedBytes) / elapsedSeconds / 1024
-	if speedKbPerSec <= 0 {
-		log.Debug().Msg("Wrong speed").Int64("downloaded_bytes", downloadedBytes).Float64("elapsed_seconds", elapsedSeconds).Done()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, &httpx.StatusError{StatusCode: resp.StatusCode}
 	}
-	return speedKbPerSec, nil
+	return resp, nil
 }
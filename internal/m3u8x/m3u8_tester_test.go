@@ -0,0 +1,207 @@
+package m3u8x
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/mediax"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/rambollwong/rainbowcat/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	master := `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720,CODECS="avc1.64001f,mp4a.40.2"
+720p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=16000000,RESOLUTION=3840x2160,CODECS="hvc1.2.4.L153,mp4a.40.2"
+2160p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=16000000,RESOLUTION=1920x1080
+1080p/index.m3u8
+`
+	pl, err := parsePlaylist(master, "http://example.com/live/cctv4k.m3u8")
+	require.NoError(t, err)
+	require.Empty(t, pl.segments)
+	require.Equal(t, []playlistVariant{
+		{url: "http://example.com/live/720p/index.m3u8", bandwidth: 2000000, width: 1280, height: 720},
+		{url: "http://example.com/live/2160p/index.m3u8", bandwidth: 16000000, width: 3840, height: 2160},
+		{url: "http://example.com/live/1080p/index.m3u8", bandwidth: 16000000, width: 1920, height: 1080},
+	}, pl.variants)
+	require.Equal(t, &pl.variants[1], pl.bestVariant())
+
+	media := `#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXTINF:6.006,
+1.ts
+#EXTINF:5.5,title
+http://cdn.example.com/2.ts
+3.ts
+`
+	pl, err = parsePlaylist(media, "http://example.com/live/2160p/index.m3u8")
+	require.NoError(t, err)
+	require.Empty(t, pl.variants)
+	require.Equal(t, []playlistSegment{
+		{url: "http://example.com/live/2160p/1.ts", duration: 6.006},
+		{url: "http://cdn.example.com/2.ts", duration: 5.5},
+		{url: "http://example.com/live/2160p/3.ts"},
+	}, pl.segments)
+}
+
+func TestSegmentStreamInfo(t *testing.T) {
+	segment := playlistSegment{url: "http://example.com/1.ts", duration: 4}
+	variant := &playlistVariant{bandwidth: 16000000, width: 3840, height: 2160}
+
+	// the probed stream wins over the declared variant stream
+	probed := &mediax.StreamInfo{Format: mediax.FormatTS, Width: 1920, Height: 1080, Bitrate: 4000000}
+	info := segmentStreamInfo(&httpx.SpeedResult{Stream: probed, Complete: true, Downloaded: 1000}, segment, variant)
+	require.Equal(t, probed, info)
+	require.NotSame(t, probed, info)
+
+	// the bitrate from the duration of a complete segment
+	probed = &mediax.StreamInfo{Format: mediax.FormatTS}
+	info = segmentStreamInfo(&httpx.SpeedResult{Stream: probed, Complete: true, Downloaded: 2000000}, segment, variant)
+	require.Equal(t, &mediax.StreamInfo{Format: mediax.FormatTS, Width: 3840, Height: 2160, Bitrate: 4000000}, info)
+
+	// the bandwidth of the variant stream if the segment is incomplete
+	info = segmentStreamInfo(&httpx.SpeedResult{Stream: probed, Downloaded: 2000000}, segment, variant)
+	require.Equal(t, &mediax.StreamInfo{Format: mediax.FormatTS, Width: 3840, Height: 2160, Bitrate: 16000000}, info)
+
+	require.Nil(t, segmentStreamInfo(&httpx.SpeedResult{Downloaded: 2000000}, segment, nil))
+}
+
+func TestMinResolutionsOf(t *testing.T) {
+	minResolutions, err := MinResolutionsOf([]*proto.GroupList{
+		{Group: "4K", TvgName: []string{"CCTV4K,CCTV-4K"}, MinResolution: "4k"},
+		{Group: "CCTV", TvgName: []string{"CCTV1", "CCTV2"}, MinResolution: "720p"},
+		{Group: "Other", TvgName: []string{"CGTN"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"CCTV4K": 2160, "CCTV1": 720, "CCTV2": 720}, minResolutions)
+
+	_, err = MinResolutionsOf([]*proto.GroupList{{Group: "4K", TvgName: []string{"CCTV4K"}, MinResolution: "best"}})
+	require.Error(t, err)
+}
+
+func TestSpeedThreshold_Passes(t *testing.T) {
+	uhd := &mediax.StreamInfo{Bitrate: 20_000_000}
+	sd := &mediax.StreamInfo{Bitrate: 1_000_000}
+
+	absolute := SpeedThreshold{MinSpeed: 800}
+	require.True(t, absolute.Passes(800, uhd))
+	require.True(t, absolute.Passes(900, nil))
+	require.False(t, absolute.Passes(500, sd))
+
+	// 20Mbit/s with a headroom of 1.5 requires 3662.1kb/s, 1Mbit/s requires 183.1kb/s
+	relative := SpeedThreshold{MinSpeed: 800, Headroom: 1.5}
+	require.False(t, relative.Passes(3600, uhd))
+	require.True(t, relative.Passes(3700, uhd))
+	require.True(t, relative.Passes(200, sd))
+	require.False(t, relative.Passes(180, sd))
+	// the min speed applies if the bitrate is unknown
+	require.False(t, relative.Passes(700, &mediax.StreamInfo{}))
+	require.True(t, relative.Passes(800, nil))
+}
+
+func TestSpeedHeadroomOf(t *testing.T) {
+	for cfg, headroom := range map[*proto.SpeedThreshold]float64{
+		nil:                               0,
+		{Mode: "absolute", Headroom: 2}:   0,
+		{Mode: "relative"}:                DefaultSpeedHeadroom,
+		{Mode: "Relative", Headroom: 1.2}: 1.2,
+	} {
+		v, err := SpeedHeadroomOf(cfg)
+		require.NoError(t, err)
+		require.Equal(t, headroom, v)
+	}
+	_, err := SpeedHeadroomOf(&proto.SpeedThreshold{Mode: "ratio"})
+	require.Error(t, err)
+}
+
+func TestTestOptions_SpeedThreshold(t *testing.T) {
+	opts := &TestOptions{
+		SpeedHeadroom: 1.5,
+		MinSpeeds: MinSpeedsOf([]*proto.GroupList{
+			{Group: "4K", TvgName: []string{"CCTV4K,CCTV-4K"}, TestLoadMinSpeed: 3000},
+			{Group: "Radio", TvgName: []string{"FM1"}},
+		}),
+	}
+	require.Equal(t, map[string]int64{"CCTV4K": 3000}, opts.MinSpeeds)
+	require.Equal(t, SpeedThreshold{MinSpeed: 3000, Headroom: 1.5}, opts.speedThreshold("CCTV4K", 800))
+	require.Equal(t, SpeedThreshold{MinSpeed: 800, Headroom: 1.5}, opts.speedThreshold("FM1", 800))
+}
+
+func TestParallelTestProgramListSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad.m3u8":
+			w.WriteHeader(http.StatusNotFound)
+		case "/good.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:6,\n1.ts\n"))
+		default:
+			_, _ = w.Write(make([]byte, 188*100))
+		}
+	}))
+	defer server.Close()
+	bad := &Channel{TvgName: "CCTV5", Url: server.URL + "/bad.m3u8"}
+	good := &Channel{TvgName: "CCTV5", Url: server.URL + "/good.m3u8"}
+	shared := &Channel{TvgName: "CCTV5+", Url: server.URL + "/good.m3u8"}
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV5"] = []*Channel{bad, good}
+	source.TvgNameChannels["CCTV5+"] = []*Channel{shared}
+	workerPool := pool.NewWorkerPool(2)
+	defer workerPool.Close()
+
+	// the failure of a url does not skip the other urls of the host, and the shared url is tested once
+	filtered := ParallelTestProgramListSource(context.Background(), httpx.NewClient(), source, 0, 0,
+		&TestOptions{}, workerPool, []*proto.GroupList{{Group: "CCTV", TvgName: []string{"CCTV5", "CCTV5+", "CCTV6"}}})
+	require.Equal(t, map[string][]*Channel{"CCTV5": {good}, "CCTV5+": {shared}, "CCTV6": {}}, filtered.TvgNameChannels)
+	require.True(t, good.Test.Passed)
+	require.Same(t, good.Test, shared.Test)
+}
+
+func TestParallelTestProgramListSource_XTvgUrls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/e.xml" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	rewriter, err := httpx.NewUrlRewriter([]*proto.UrlRewriteRule{{
+		Host:      "127.0.0.1",
+		Pattern:   `^(https?://[^/]+)/(.*)$`,
+		Templates: []string{"$1/mirror/$2"},
+	}})
+	require.NoError(t, err)
+	source := NewProgramListSource()
+	source.XTvgUrls = []string{server.URL + "/e.xml", server.URL + "/missing.xml"}
+	workerPool := pool.NewWorkerPool(2)
+	defer workerPool.Close()
+
+	// the epg is pinged through the rewritten url, and the original url is kept
+	filtered := ParallelTestProgramListSource(context.Background(), httpx.NewClient(), source, 1000, 0,
+		&TestOptions{EpgClient: httpx.NewClient(httpx.WithUrlRewriter(rewriter))}, workerPool, nil)
+	require.Equal(t, []string{server.URL + "/e.xml"}, filtered.XTvgUrls)
+}
+
+func TestParallelTestProgramListSource_XTvgUrlsFetchNetwork(t *testing.T) {
+	// the epg is only reachable through the proxy of the fetch network
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+	fetchHttpClient, err := httpx.NewHttpClient(&proto.NetworkProfile{Proxy: proxy.URL})
+	require.NoError(t, err)
+	source := NewProgramListSource()
+	source.XTvgUrls = []string{"http://epg.invalid/e.xml"}
+	workerPool := pool.NewWorkerPool(1)
+	defer workerPool.Close()
+
+	filtered := ParallelTestProgramListSource(context.Background(), httpx.NewClient(), source, 1000, 0,
+		&TestOptions{EpgClient: httpx.NewClient(httpx.WithHttpClient(fetchHttpClient))}, workerPool, nil)
+	require.Equal(t, []string{"http://epg.invalid/e.xml"}, filtered.XTvgUrls)
+	require.Equal(t, []string{"http://epg.invalid/e.xml"}, proxied)
+}
@@ -0,0 +1,224 @@
+package m3u8x
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/rambollwong/rainbowcat/types"
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+const (
+	SlateReasonFingerprint  = "fingerprint"   // SlateReasonFingerprint means a segment matches a known slate fingerprint
+	SlateReasonLooped       = "looped"        // SlateReasonLooped means different segments, including reloaded ones, are identical
+	SlateReasonTinyConstant = "tiny_constant" // SlateReasonTinyConstant means the segments are tiny and of a constant size
+	SlateReasonCrossChannel = "cross_channel" // SlateReasonCrossChannel means a segment is repeated across different urls
+)
+
+const (
+	// DefaultCrossChannelMin is the default min number of different urls sharing a segment of a slate.
+	DefaultCrossChannelMin = 3
+	// minTinyConstantSamples is the min number of different segments of the same tiny size of a slate,
+	// as two segments of a constant bitrate stream are often of the same size. As only the first and last segments
+	// of a playlist are tested, the third one is the reloaded segment, see ValidateSlateDetection.
+	minTinyConstantSamples = 3
+)
+
+// SegmentSample is a segment downloaded in the test of an m3u8 url.
+type SegmentSample struct {
+	Url  string
+	Size int64
+	Hash string // Hash is the hex SHA-256 of the segment, empty if it is not completely downloaded
+}
+
+// SlateReport is a channel url detected as a slate stream, e.g. a static "signal lost" picture or a looping ad.
+type SlateReport struct {
+	TvgName string `json:"tvg_name"`       // TvgName is the main tvg name of the channel
+	Url     string `json:"url"`            // Url is the url of the channel
+	Reason  string `json:"reason"`         // Reason is why the url is detected, see SlateReason*
+	Hash    string `json:"hash,omitempty"` // Hash is the hash of the segment detected, which can be added to the fingerprints
+}
+
+// SlateDetector detects the slate streams of m3u8 urls by the hashes and sizes of their tested segments,
+// and keeps the reports of the detected urls. A nil SlateDetector detects nothing.
+type SlateDetector struct {
+	fingerprints     *types.Set[string]
+	tinySegmentBytes int64 // tinySegmentBytes is the max size of the tiny constant-size segments, 0 disables it
+	crossChannelMin  int
+	reloadDelay      time.Duration
+
+	mu       sync.Mutex
+	hashUrls map[string]*types.Set[string] // hashUrls are the canonical urls of each segment hash
+	reports  []*SlateReport
+}
+
+// ValidateSlateDetection checks the slate detection config. The tiny constant-size segments can only be detected
+// with the reloaded segment, so the reload delay is required by the tiny segment size.
+func ValidateSlateDetection(cfg *proto.SlateDetection) error {
+	if !cfg.GetEnable() {
+		return nil
+	}
+	if cfg.GetTinySegmentBytes() > 0 && cfg.GetReloadDelayMs() <= 0 {
+		return errors.New("tiny segment bytes of slate detection requires reload delay ms, " +
+			"since only the first and last segments are tested without reloading")
+	}
+	return nil
+}
+
+// NewSlateDetector creates a SlateDetector from the config, or returns nil if it is disabled.
+func NewSlateDetector(cfg *proto.SlateDetection) *SlateDetector {
+	if !cfg.GetEnable() {
+		return nil
+	}
+	d := &SlateDetector{
+		fingerprints:     types.NewSet[string](),
+		tinySegmentBytes: cfg.GetTinySegmentBytes(),
+		crossChannelMin:  int(cfg.GetCrossChannelMin()),
+		reloadDelay:      time.Duration(cfg.GetReloadDelayMs()) * time.Millisecond,
+		hashUrls:         make(map[string]*types.Set[string]),
+	}
+	if d.crossChannelMin <= 0 {
+		d.crossChannelMin = DefaultCrossChannelMin
+	}
+	for _, fingerprint := range cfg.GetFingerprints() {
+		d.fingerprints.Put(strings.ToLower(strings.TrimSpace(fingerprint)))
+	}
+	return d
+}
+
+// ReloadDelay returns the delay of reloading the playlist of an m3u8 url to compare its new segments,
+// 0 if the playlist is not reloaded.
+func (d *SlateDetector) ReloadDelay() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.reloadDelay
+}
+
+// inspect detects whether the segments of an m3u8 url are of a slate stream by themselves,
+// and returns the reason and the hash of the detected segment, or "" if they are not.
+func (d *SlateDetector) inspect(segments []SegmentSample) (reason, hash string) {
+	if d == nil {
+		return "", ""
+	}
+	var complete []SegmentSample
+	for _, segment := range segments {
+		if segment.Hash == "" {
+			continue
+		}
+		if d.fingerprints.Exist(segment.Hash) {
+			return SlateReasonFingerprint, segment.Hash
+		}
+		complete = append(complete, segment)
+	}
+	for i, a := range complete {
+		for _, b := range complete[i+1:] {
+			if a.Url != b.Url && a.Hash == b.Hash {
+				return SlateReasonLooped, a.Hash
+			}
+		}
+	}
+	if d.tinySegmentBytes > 0 && countUrls(complete) >= minTinyConstantSamples &&
+		complete[0].Size <= d.tinySegmentBytes &&
+		!slices.ContainsFunc(complete, func(s SegmentSample) bool { return s.Size != complete[0].Size }) {
+		return SlateReasonTinyConstant, complete[0].Hash
+	}
+	return "", ""
+}
+
+// countUrls returns the number of different urls of the segments.
+func countUrls(segments []SegmentSample) int {
+	urls := types.NewSet[string]()
+	for _, segment := range segments {
+		urls.Put(segment.Url)
+	}
+	return int(urls.Size())
+}
+
+// observe records the segment hashes of a tested url. A url shared by several channels is counted once.
+func (d *SlateDetector) observe(url string, result *ChannelTestResult) {
+	if d == nil {
+		return
+	}
+	url = urlx.Canonicalize(url)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, segment := range result.Segments {
+		if segment.Hash == "" {
+			continue
+		}
+		urls, ok := d.hashUrls[segment.Hash]
+		if !ok {
+			urls = types.NewSet[string]()
+			d.hashUrls[segment.Hash] = urls
+		}
+		urls.Put(url)
+	}
+}
+
+// report records a url of the channel of the main tvg name detected as a slate stream.
+func (d *SlateDetector) report(tvgName, url, reason, hash string) {
+	if d == nil {
+		return
+	}
+	log.Warn().Msg("Channel url is a slate stream, ignore.").
+		Str("tvg_name", tvgName).
+		Str("channel_url", url).
+		Str("reason", reason).
+		Str("hash", hash).
+		Done()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reports = append(d.reports, &SlateReport{TvgName: tvgName, Url: url, Reason: reason, Hash: hash})
+}
+
+// removeCrossChannelSlates removes the channels from the source whose segments are repeated
+// across at least the min number of different urls, which must be called after all urls are observed.
+func (d *SlateDetector) removeCrossChannelSlates(source *ProgramListSource) {
+	if d == nil {
+		return
+	}
+	for tvgName, channels := range source.TvgNameChannels {
+		source.TvgNameChannels[tvgName] = slices.DeleteFunc(channels, func(ch *Channel) bool {
+			if ch.Test == nil {
+				return false
+			}
+			for _, segment := range ch.Test.Segments {
+				if segment.Hash == "" {
+					continue
+				}
+				d.mu.Lock()
+				urls, ok := d.hashUrls[segment.Hash]
+				d.mu.Unlock()
+				if ok && urls.Size() >= int64(d.crossChannelMin) {
+					d.report(tvgName, ch.Url, SlateReasonCrossChannel, segment.Hash)
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// Reports returns the reports of the urls detected as slate streams, sorted by the tvg names and the urls.
+func (d *SlateDetector) Reports() []*SlateReport {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	reports := append(make([]*SlateReport, 0, len(d.reports)), d.reports...)
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].TvgName != reports[j].TvgName {
+			return reports[i].TvgName < reports[j].TvgName
+		}
+		return reports[i].Url < reports[j].Url
+	})
+	return reports
+}
@@ -0,0 +1,153 @@
+package m3u8x
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlateDetector_Inspect(t *testing.T) {
+	require.Nil(t, NewSlateDetector(&proto.SlateDetection{}))
+	var disabled *SlateDetector
+	reason, _ := disabled.inspect([]SegmentSample{{Url: "a", Size: 1, Hash: "h"}, {Url: "b", Size: 1, Hash: "h"}})
+	require.Empty(t, reason)
+
+	d := NewSlateDetector(&proto.SlateDetection{Enable: true, Fingerprints: []string{" ABCD "}, TinySegmentBytes: 64 * 1024})
+	for _, c := range []struct {
+		segments []SegmentSample
+		reason   string
+		hash     string
+	}{
+		{[]SegmentSample{{Url: "1.ts", Size: 2 << 20, Hash: "1"}, {Url: "2.ts", Size: 2 << 20, Hash: "abcd"}}, SlateReasonFingerprint, "abcd"},
+		{[]SegmentSample{{Url: "1.ts", Size: 2 << 20, Hash: "1"}, {Url: "9.ts", Size: 2 << 20, Hash: "1"}}, SlateReasonLooped, "1"},
+		{[]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}, {Url: "9.ts", Size: 1000, Hash: "9"}, {Url: "12.ts", Size: 1000, Hash: "12"}}, SlateReasonTinyConstant, "1"},
+		// two segments of a constant bitrate stream, or the reloaded segment of a playlist which has not moved on
+		{[]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}, {Url: "9.ts", Size: 1000, Hash: "9"}}, "", ""},
+		{[]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}, {Url: "9.ts", Size: 1000, Hash: "9"}, {Url: "9.ts", Size: 1000, Hash: "9"}}, "", ""},
+		{[]SegmentSample{{Url: "1.ts", Size: 100 << 10, Hash: "1"}, {Url: "9.ts", Size: 100 << 10, Hash: "9"}, {Url: "12.ts", Size: 100 << 10, Hash: "12"}}, "", ""},
+		// the same segment of a playlist which has not moved on
+		{[]SegmentSample{{Url: "1.ts", Size: 2 << 20, Hash: "1"}, {Url: "1.ts", Size: 2 << 20, Hash: "1"}}, "", ""},
+		{[]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}, {Url: "9.ts", Size: 1001, Hash: "9"}}, "", ""},
+		// incomplete segments are not compared
+		{[]SegmentSample{{Url: "1.ts", Size: 1000}, {Url: "9.ts", Size: 1000}, {Url: "12.ts", Size: 1000}}, "", ""},
+		{[]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}}, "", ""},
+	} {
+		reason, hash := d.inspect(c.segments)
+		require.Equal(t, c.reason, reason, c.segments)
+		require.Equal(t, c.hash, hash, c.segments)
+	}
+
+	// tiny constant-size segments are not detected by default
+	d = NewSlateDetector(&proto.SlateDetection{Enable: true})
+	reason, _ = d.inspect([]SegmentSample{{Url: "1.ts", Size: 1000, Hash: "1"}, {Url: "9.ts", Size: 1000, Hash: "9"}, {Url: "12.ts", Size: 1000, Hash: "12"}})
+	require.Empty(t, reason)
+}
+
+func TestSlateDetector_RemoveCrossChannelSlates(t *testing.T) {
+	d := NewSlateDetector(&proto.SlateDetection{Enable: true, CrossChannelMin: 2})
+	slate := &ChannelTestResult{Passed: true, Segments: []SegmentSample{{Url: "http://a/offline.ts", Hash: "offline"}}}
+	live := &ChannelTestResult{Passed: true, Segments: []SegmentSample{{Url: "http://b/1.ts", Hash: "1"}}}
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://a/cctv1.m3u8", Test: slate},
+		{TvgName: "CCTV1", Url: "http://b/cctv1.m3u8", Test: live},
+	}
+	source.TvgNameChannels["CCTV2"] = []*Channel{{TvgName: "CCTV2", Url: "http://a/cctv2.m3u8", Test: slate}}
+	// the same url shared by different channels is not a slate
+	shared := &ChannelTestResult{Passed: true, Segments: []SegmentSample{{Url: "http://c/3.ts", Hash: "3"}}}
+	source.TvgNameChannels["CCTV3"] = []*Channel{{TvgName: "CCTV3", Url: "http://c/cctv3.m3u8", Test: shared}}
+	source.TvgNameChannels["CCTV3-HD"] = []*Channel{{TvgName: "CCTV3-HD", Url: "http://C/cctv3.m3u8", Test: shared}}
+	for _, channels := range source.TvgNameChannels {
+		for _, ch := range channels {
+			d.observe(ch.Url, ch.Test)
+		}
+	}
+
+	d.removeCrossChannelSlates(source)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 1)
+	require.Equal(t, "http://b/cctv1.m3u8", source.TvgNameChannels["CCTV1"][0].Url)
+	require.Empty(t, source.TvgNameChannels["CCTV2"])
+	require.Len(t, source.TvgNameChannels["CCTV3"], 1)
+	require.Len(t, source.TvgNameChannels["CCTV3-HD"], 1)
+	require.Equal(t, []*SlateReport{
+		{TvgName: "CCTV1", Url: "http://a/cctv1.m3u8", Reason: SlateReasonCrossChannel, Hash: "offline"},
+		{TvgName: "CCTV2", Url: "http://a/cctv2.m3u8", Reason: SlateReasonCrossChannel, Hash: "offline"},
+	}, d.Reports())
+}
+
+func TestTestChannelUrl_Slate(t *testing.T) {
+	slate := bytes.Repeat([]byte{0x47, 0x1f, 0xff, 0x10}, 47*100) // null TS packets
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.m3u8":
+			_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:6,\n1.ts\n#EXTINF:6,\n2.ts\n"))
+		default:
+			_, _ = w.Write(slate)
+		}
+	}))
+	defer server.Close()
+	client := httpx.NewClient()
+	ch := &Channel{TvgName: "CCTV1", Url: server.URL + "/live.m3u8"}
+
+	result := testChannelUrl(context.Background(), client, ch, "CCTV1", SpeedThreshold{}, &TestOptions{})
+	require.True(t, result.Passed)
+	require.Len(t, result.Segments, 1)
+
+	opts := &TestOptions{Slate: NewSlateDetector(&proto.SlateDetection{Enable: true})}
+	result = testChannelUrl(context.Background(), client, ch, "CCTV1", SpeedThreshold{}, opts)
+	require.False(t, result.Passed)
+	require.Equal(t, SlateReasonLooped, result.Slate)
+	require.Len(t, result.Segments, 2)
+	require.Equal(t, result.Segments[0].Hash, result.SlateHash)
+}
+
+func TestValidateSlateDetection(t *testing.T) {
+	require.NoError(t, ValidateSlateDetection(nil))
+	require.NoError(t, ValidateSlateDetection(&proto.SlateDetection{Enable: true}))
+	require.NoError(t, ValidateSlateDetection(&proto.SlateDetection{TinySegmentBytes: 32768}))
+	require.NoError(t, ValidateSlateDetection(&proto.SlateDetection{Enable: true, TinySegmentBytes: 32768, ReloadDelayMs: 1}))
+	require.Error(t, ValidateSlateDetection(&proto.SlateDetection{Enable: true, TinySegmentBytes: 32768}))
+}
+
+func TestTestChannelUrl_TinyConstant(t *testing.T) {
+	var playlists atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/live.m3u8" {
+			// the playlist moves on after it is fetched
+			if playlists.Add(1) == 1 {
+				_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:6,\n1.ts\n#EXTINF:6,\n2.ts\n"))
+			} else {
+				_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:6,\n2.ts\n#EXTINF:6,\n3.ts\n"))
+			}
+			return
+		}
+		// different segments of the same tiny size
+		segment := make([]byte, 188*10)
+		copy(segment, r.URL.Path)
+		_, _ = w.Write(segment)
+	}))
+	defer server.Close()
+	client := httpx.NewClient()
+	ch := &Channel{TvgName: "CCTV1", Url: server.URL + "/live.m3u8"}
+
+	// the default config does not detect tiny segments
+	opts := &TestOptions{Slate: NewSlateDetector(&proto.SlateDetection{Enable: true})}
+	result := testChannelUrl(context.Background(), client, ch, "CCTV1", SpeedThreshold{}, opts)
+	require.True(t, result.Passed)
+	require.Len(t, result.Segments, 2)
+
+	// the reloaded segment is the third one
+	playlists.Store(0)
+	opts = &TestOptions{Slate: NewSlateDetector(&proto.SlateDetection{Enable: true, TinySegmentBytes: 32768, ReloadDelayMs: 1})}
+	result = testChannelUrl(context.Background(), client, ch, "CCTV1", SpeedThreshold{}, opts)
+	require.False(t, result.Passed)
+	require.Equal(t, SlateReasonTinyConstant, result.Slate)
+	require.Len(t, result.Segments, 3)
+}
@@ -0,0 +1,92 @@
+package m3u8x
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbowcat/types"
+)
+
+// SourceStat is the statistics of the channels contributed by an upstream url or local file.
+type SourceStat struct {
+	Source      string `json:"source"`      // Source is the upstream url or local file
+	Contributed int    `json:"contributed"` // Contributed is the number of urls left after filtering by the group list
+	Surviving   int    `json:"surviving"`   // Surviving is the number of contributed urls that passed the tests
+	Unique      int    `json:"unique"`      // Unique is the number of surviving urls contributed by no other source
+	Stale       bool   `json:"stale"`       // Stale means the source is the last mirrored copy because its upstream could not be fetched
+	AvgTTFBMs   int64  `json:"avg_ttfb_ms"` // AvgTTFBMs is the average time to first byte of the surviving channels in milliseconds
+}
+
+// NewSourceStats calculates the statistics of each source from the filtered sources before merging
+// and the tested source.
+// Urls are compared in their canonical form, and a url listed under several channels of a source is counted once.
+// A url contributed by several sources is counted for each of them, because merging keeps only one of them. The result is sorted by the number of surviving channels in descending order.
+func NewSourceStats(filteredSources []*ProgramListSource, testedSource *ProgramListSource) []*SourceStat {
+	survivingUrls := types.NewSet[string]()
+	urlTTFBs := make(map[string]time.Duration)
+	for _, channels := range testedSource.TvgNameChannels {
+		for _, channel := range channels {
+			canonicalUrl := urlx.Canonicalize(channel.Url)
+			survivingUrls.Put(canonicalUrl)
+			if channel.Test != nil {
+				urlTTFBs[canonicalUrl] = channel.Test.TTFB
+			}
+		}
+	}
+
+	urlSources := make(map[string]*types.Set[string])
+	sourceUrls := make(map[string]*types.Set[string])
+	statMap := make(map[string]*SourceStat)
+	for _, source := range filteredSources {
+		for _, channels := range source.TvgNameChannels {
+			for _, channel := range channels {
+				stat, ok := statMap[channel.Source]
+				if !ok {
+					stat = &SourceStat{Source: channel.Source}
+					statMap[channel.Source] = stat
+					sourceUrls[channel.Source] = types.NewSet[string]()
+				}
+				stat.Stale = stat.Stale || source.Stale
+				canonicalUrl := urlx.Canonicalize(channel.Url)
+				sourceUrls[channel.Source].Put(canonicalUrl)
+				if _, ok := urlSources[canonicalUrl]; !ok {
+					urlSources[canonicalUrl] = types.NewSet[string]()
+				}
+				urlSources[canonicalUrl].Put(channel.Source)
+			}
+		}
+	}
+
+	stats := make([]*SourceStat, 0, len(statMap))
+	for source, stat := range statMap {
+		var totalTTFB time.Duration
+		var tested int64
+		stat.Contributed = int(sourceUrls[source].Size())
+		sourceUrls[source].Range(func(u string) bool {
+			if !survivingUrls.Exist(u) {
+				return true
+			}
+			stat.Surviving++
+			if urlSources[u].Size() == 1 {
+				stat.Unique++
+			}
+			if ttfb, ok := urlTTFBs[u]; ok {
+				totalTTFB += ttfb
+				tested++
+			}
+			return true
+		})
+		if tested > 0 {
+			stat.AvgTTFBMs = (totalTTFB / time.Duration(tested)).Milliseconds()
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Surviving != stats[j].Surviving {
+			return stats[i].Surviving > stats[j].Surviving
+		}
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
@@ -0,0 +1,49 @@
+package m3u8x
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSourceStats(t *testing.T) {
+	source1 := NewProgramListSource()
+	source1.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://a/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://b/cctv1.m3u8"},
+	}
+	// the same url listed under another channel is contributed once
+	source1.TvgNameChannels["CCTV1-HD"] = []*Channel{{TvgName: "CCTV1-HD", Url: "http://A/cctv1.m3u8"}}
+	source1.SetChannelSource("source1")
+	source2 := NewProgramListSource()
+	source2.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://b/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://c/cctv1.m3u8"},
+	}
+	source2.SetChannelSource("source2")
+
+	merged := MergeProgramListSources([]*ProgramListSource{source1, source2})
+	ttfbs := map[string]time.Duration{"http://a/cctv1.m3u8": 100 * time.Millisecond, "http://b/cctv1.m3u8": 300 * time.Millisecond}
+	tested := NewProgramListSource()
+	for _, channel := range merged.TvgNameChannels["CCTV1"] {
+		if channel.Url != "http://c/cctv1.m3u8" {
+			testedChannel := *channel
+			testedChannel.Test = &ChannelTestResult{Passed: true, TTFB: ttfbs[channel.Url]}
+			tested.TvgNameChannels["CCTV1"] = append(tested.TvgNameChannels["CCTV1"], &testedChannel)
+		}
+	}
+
+	stats := NewSourceStats([]*ProgramListSource{source1, source2}, tested)
+	require.Equal(t, []*SourceStat{
+		{Source: "source1", Contributed: 2, Surviving: 2, Unique: 1, AvgTTFBMs: 200},
+		{Source: "source2", Contributed: 2, Surviving: 1, Unique: 0, AvgTTFBMs: 300},
+	}, stats)
+
+	groupList := []*proto.GroupList{{Group: "央视", TvgName: []string{"CCTV1"}}}
+	bz := OutputProgramListSourceToM3u8Bz(tested, groupList, nil)
+	require.NotContains(t, string(bz), "x-source")
+	bz = OutputProgramListSourceToM3u8Bz(tested, groupList, &OutputOptions{SourceAttribute: true})
+	require.Contains(t, string(bz), `x-source="source1" group-title="央视",CCTV1`)
+}
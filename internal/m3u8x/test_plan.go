@@ -0,0 +1,118 @@
+package m3u8x
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/rambollwong/rainbowcat/types"
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+// TestPlanStats are the statistics of a test plan.
+type TestPlanStats struct {
+	Channels   int // Channels is the number of channel urls referenced by the group list
+	UniqueUrls int // UniqueUrls is the number of unique urls, each of which is tested once
+	SharedUrls int // SharedUrls is the number of unique urls referenced by different main tvg names
+	Hosts      int // Hosts is the number of hosts of the unique urls
+}
+
+// Saved returns the number of tests saved by deduplicating the urls.
+func (s TestPlanStats) Saved() int {
+	return s.Channels - s.UniqueUrls
+}
+
+// testRef is a channel of a main tvg name referencing a unique url of the test plan.
+type testRef struct {
+	tvgName string
+	ch      *Channel
+}
+
+// testJob is a unique url of the test plan, which is tested once by its first channel.
+type testJob struct {
+	host string
+	refs []testRef // refs are the channels referencing the url, at most one for each main tvg name
+}
+
+// threshold returns the loosest speed threshold of the channels referencing the url. The url is tested
+// with it once, and the result is checked against the threshold of each channel.
+func (j *testJob) threshold(opts *TestOptions, loadMinSpeed int64) SpeedThreshold {
+	threshold := opts.speedThreshold(j.refs[0].tvgName, loadMinSpeed)
+	for _, ref := range j.refs[1:] {
+		if t := opts.speedThreshold(ref.tvgName, loadMinSpeed); t.MinSpeed < threshold.MinSpeed {
+			threshold = t
+		}
+	}
+	return threshold
+}
+
+// testPlan is the plan of testing the channel urls of the group list. The urls are deduplicated globally
+// by their canonical form, so that each unique url is tested once and its result is fanned out
+// to all the channels referencing it, even under aliases of different tvg name entries.
+type testPlan struct {
+	tvgNames     []string         // tvgNames are the main tvg names in the order of the group list
+	jobs         []*testJob       // jobs are the unique urls in the order of the group list
+	channelOrder map[*Channel]int // channelOrder is the order of the channels in the source
+	stats        TestPlanStats
+}
+
+// newTestPlan builds the test plan of the channels of the source listed in the group list.
+// The same url listed under several aliases of a tvg name entry is referenced by its first channel only.
+func newTestPlan(source *ProgramListSource, groupList []*proto.GroupList) *testPlan {
+	plan := &testPlan{channelOrder: make(map[*Channel]int)}
+	jobs := make(map[string]*testJob) // canonical url -> job
+	planned := types.NewSet[string]()
+	hosts := types.NewSet[string]()
+	for _, list := range groupList {
+		for _, tvgName := range list.TvgName {
+			tvgNames := splitTvgNames(tvgName) // Support merging multiple tvgNames
+			tvgNameMain := tvgNames[0]         // Use the first tvgName as the main tvgName
+			if !planned.Put(tvgNameMain) {
+				continue
+			}
+			plan.tvgNames = append(plan.tvgNames, tvgNameMain)
+			referenced := types.NewSet[string]()
+			for _, tn := range tvgNames {
+				for _, ch := range source.TvgNameChannels[tn] {
+					if strings.Contains(ch.Url, "audio") {
+						continue
+					}
+					// The urls of a host are tested in turns with the other hosts
+					// This approach prevents test failures due to server request rate limiting
+					u, err := url.Parse(ch.Url)
+					if err != nil {
+						log.Error().Msg("Failed to parse channel url, ignore.").
+							Str("tvg_name", tvgNameMain).
+							Str("channel_url", ch.Url).
+							Done()
+						continue
+					}
+					plan.stats.Channels++
+					canonicalUrl := urlx.Canonicalize(ch.Url)
+					if !referenced.Put(canonicalUrl) {
+						continue
+					}
+					job, exist := jobs[canonicalUrl]
+					if !exist {
+						job = &testJob{host: u.Host}
+						jobs[canonicalUrl] = job
+						plan.jobs = append(plan.jobs, job)
+						hosts.Put(u.Host)
+					}
+					job.refs = append(job.refs, testRef{tvgName: tvgNameMain, ch: ch})
+					plan.channelOrder[ch] = len(plan.channelOrder)
+				}
+			}
+		}
+	}
+
+	plan.stats.UniqueUrls = len(plan.jobs)
+	plan.stats.Hosts = int(hosts.Size())
+	for _, job := range plan.jobs {
+		if len(job.refs) > 1 {
+			plan.stats.SharedUrls++
+		}
+	}
+	return plan
+}
@@ -0,0 +1,43 @@
+package m3u8x
+
+import (
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestPlan(t *testing.T) {
+	cctv5 := &Channel{TvgName: "CCTV5", Url: "http://a.example.com/live/cctv5.m3u8"}
+	cctv5Dup := &Channel{TvgName: "CCTV-5", Url: "http://A.example.com/live/cctv5.m3u8"}
+	cctv5Other := &Channel{TvgName: "CCTV5", Url: "http://b.example.com/live/cctv5.m3u8"}
+	cctv5Plus := &Channel{TvgName: "CCTV5+", Url: "http://a.example.com/live/cctv5.m3u8"}
+	cctv5PlusAudio := &Channel{TvgName: "CCTV5+", Url: "http://a.example.com/audio/cctv5p.m3u8"}
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV5"] = []*Channel{cctv5, cctv5Other}
+	source.TvgNameChannels["CCTV-5"] = []*Channel{cctv5Dup}
+	source.TvgNameChannels["CCTV5+"] = []*Channel{cctv5Plus, cctv5PlusAudio}
+
+	plan := newTestPlan(source, []*proto.GroupList{
+		{Group: "CCTV", TvgName: []string{"CCTV5,CCTV-5", "CCTV5+", "CCTV6"}},
+		{Group: "Sports", TvgName: []string{"CCTV5"}},
+	})
+	require.Equal(t, []string{"CCTV5", "CCTV5+", "CCTV6"}, plan.tvgNames)
+	require.Equal(t, TestPlanStats{Channels: 4, UniqueUrls: 2, SharedUrls: 1, Hosts: 2}, plan.stats)
+	require.Equal(t, 2, plan.stats.Saved())
+
+	// the duplicate url under the alias is referenced only once, and the url shared by different channels is one job
+	require.Equal(t, []*testJob{
+		{host: "a.example.com", refs: []testRef{{tvgName: "CCTV5", ch: cctv5}, {tvgName: "CCTV5+", ch: cctv5Plus}}},
+		{host: "b.example.com", refs: []testRef{{tvgName: "CCTV5", ch: cctv5Other}}},
+	}, plan.jobs)
+	require.Equal(t, map[*Channel]int{cctv5: 0, cctv5Other: 1, cctv5Plus: 2}, plan.channelOrder)
+}
+
+func TestTestJob_Threshold(t *testing.T) {
+	opts := &TestOptions{SpeedHeadroom: 1.5, MinSpeeds: map[string]int64{"CCTV4K": 3000, "CCTV5": 500}}
+	job := &testJob{refs: []testRef{{tvgName: "CCTV4K"}, {tvgName: "CCTV1"}, {tvgName: "CCTV5"}}}
+	require.Equal(t, SpeedThreshold{MinSpeed: 500, Headroom: 1.5}, job.threshold(opts, 800))
+	job = &testJob{refs: []testRef{{tvgName: "CCTV4K"}}}
+	require.Equal(t, SpeedThreshold{MinSpeed: 3000, Headroom: 1.5}, job.threshold(opts, 800))
+}
@@ -0,0 +1,147 @@
+package m3u8x
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+const (
+	// DefaultHostConcurrency is the max number of urls of a host tested at the same time if its concurrency
+	// is not limited by the host limits, which prevents test failures due to server request rate limiting.
+	DefaultHostConcurrency = 4
+	// testProgressInterval is the interval of logging the progress of testing.
+	testProgressInterval = 10 * time.Second
+)
+
+// TestProgress is the progress of testing the unique urls of a test plan.
+type TestProgress struct {
+	Total   int // Total is the number of urls to test
+	Done    int // Done is the number of urls tested
+	Passed  int // Passed is the number of urls tested which are kept for at least one channel referencing them
+	Running int // Running is the number of urls being tested
+}
+
+// hostQueue is the queue of the jobs of a host.
+type hostQueue struct {
+	host        string
+	jobs        []*testJob
+	running     int
+	concurrency int // concurrency is the max number of running jobs of the host
+}
+
+// testScheduler dispatches the jobs of a test plan from a global queue. The hosts take turns to dispatch
+// their jobs, and each host is tested by at most its concurrency of workers, so that a slow host
+// never holds up the jobs of the other hosts. It also tracks the progress of testing.
+type testScheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	hosts    []*hostQueue // hosts are the queues of the hosts in the order of their first jobs
+	next     int          // next is the index of the host taking the next turn
+	queued   int
+	progress TestProgress
+}
+
+// newTestScheduler creates a testScheduler of the jobs in order, which are dispatched in order within each host.
+// The concurrency of each host is the max concurrency of the host, DefaultHostConcurrency if it returns 0.
+func newTestScheduler(jobs []*testJob, maxConcurrency func(host string) int) *testScheduler {
+	s := &testScheduler{
+		queued:   len(jobs),
+		progress: TestProgress{Total: len(jobs)},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	queues := make(map[string]*hostQueue)
+	for _, job := range jobs {
+		queue, ok := queues[job.host]
+		if !ok {
+			queue = &hostQueue{host: job.host, concurrency: maxConcurrency(job.host)}
+			if queue.concurrency <= 0 {
+				queue.concurrency = DefaultHostConcurrency
+			}
+			queues[job.host] = queue
+			s.hosts = append(s.hosts, queue)
+		}
+		queue.jobs = append(queue.jobs, job)
+	}
+	return s
+}
+
+// dispatch returns the next job to test, waiting until a host with queued jobs is available.
+// It returns false if all jobs are dispatched or the context is done.
+// The job dispatched must be finished by finish.
+func (s *testScheduler) dispatch(ctx context.Context) (*testJob, bool) {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.queued > 0 && ctx.Err() == nil {
+		for i := range s.hosts {
+			queue := s.hosts[(s.next+i)%len(s.hosts)]
+			if len(queue.jobs) == 0 || queue.running >= queue.concurrency {
+				continue
+			}
+			job := queue.jobs[0]
+			queue.jobs = queue.jobs[1:]
+			queue.running++
+			s.queued--
+			s.progress.Running++
+			s.next = (s.next + i + 1) % len(s.hosts)
+			return job, true
+		}
+		// All the hosts with queued jobs are busy
+		s.cond.Wait()
+	}
+	return nil, false
+}
+
+// finish releases the host of a dispatched job and records its result.
+func (s *testScheduler) finish(job *testJob, passed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, queue := range s.hosts {
+		if queue.host == job.host {
+			queue.running--
+			break
+		}
+	}
+	s.progress.Running--
+	s.progress.Done++
+	if passed {
+		s.progress.Passed++
+	}
+	s.cond.Broadcast()
+}
+
+// Progress returns the current progress of testing.
+func (s *testScheduler) Progress() TestProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.progress
+}
+
+// logProgress logs the progress of testing at the interval until the stop channel is closed.
+func (s *testScheduler) logProgress(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			progress := s.Progress()
+			log.Info().Msg("Testing channel urls...").
+				Int("done", progress.Done).
+				Int("total", progress.Total).
+				Int("passed", progress.Passed).
+				Int("running", progress.Running).
+				Done()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package m3u8x
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestScheduler_Dispatch(t *testing.T) {
+	a1, a2, a3 := &testJob{host: "a"}, &testJob{host: "a"}, &testJob{host: "a"}
+	b1, c1 := &testJob{host: "b"}, &testJob{host: "c"}
+	s := newTestScheduler([]*testJob{a1, a2, a3, b1, c1}, func(string) int { return 1 })
+	ctx := context.Background()
+
+	// the hosts take turns
+	for _, want := range []*testJob{a1, b1, c1} {
+		job, ok := s.dispatch(ctx)
+		require.True(t, ok)
+		require.Same(t, want, job)
+	}
+	require.Equal(t, TestProgress{Total: 5, Running: 3}, s.Progress())
+
+	// the busy host waits until its job is finished, while the other hosts are finished independently
+	s.finish(c1, false)
+	s.finish(b1, true)
+	dispatched := make(chan *testJob)
+	go func() {
+		job, _ := s.dispatch(ctx)
+		dispatched <- job
+	}()
+	select {
+	case <-dispatched:
+		t.Fatal("the busy host is dispatched")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.finish(a1, false)
+	require.Same(t, a2, <-dispatched)
+	s.finish(a2, true)
+	job, ok := s.dispatch(ctx)
+	require.True(t, ok)
+	require.Same(t, a3, job)
+	s.finish(a3, true)
+
+	_, ok = s.dispatch(ctx)
+	require.False(t, ok)
+	require.Equal(t, TestProgress{Total: 5, Done: 5, Passed: 3}, s.Progress())
+}
+
+func TestTestScheduler_DispatchCanceled(t *testing.T) {
+	a1, a2 := &testJob{host: "a"}, &testJob{host: "a"}
+	s := newTestScheduler([]*testJob{a1, a2}, func(string) int { return 1 })
+	ctx, cancel := context.WithCancel(context.Background())
+	job, ok := s.dispatch(ctx)
+	require.True(t, ok)
+	require.Same(t, a1, job)
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, ok = s.dispatch(ctx)
+	require.False(t, ok)
+}
+
+func TestNewTestScheduler_Concurrency(t *testing.T) {
+	s := newTestScheduler([]*testJob{{host: "a"}, {host: "b"}}, func(host string) int {
+		if host == "a" {
+			return 2
+		}
+		return 0
+	})
+	require.Equal(t, 2, s.hosts[0].concurrency)
+	require.Equal(t, DefaultHostConcurrency, s.hosts[1].concurrency)
+}
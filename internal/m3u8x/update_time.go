@@ -0,0 +1,143 @@
+package m3u8x
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+const (
+	DefaultUpdateTimeGroup      = "更新时间"
+	DefaultUpdateTimeTimeFormat = "20060102 15:04:05"
+)
+
+// UpdateSummary is the summary of a run shown by the update time channels.
+type UpdateSummary struct {
+	UpdateTime   time.Time     // UpdateTime is the time when the output was generated
+	ChannelCount int           // ChannelCount is the number of channels having at least one url
+	UrlCount     int           // UrlCount is the number of urls of all channels
+	Duration     time.Duration // Duration is how long the run took
+}
+
+// NewUpdateSummary counts the channels and urls of the source.
+func NewUpdateSummary(source *ProgramListSource, updateTime time.Time, duration time.Duration) *UpdateSummary {
+	summary := &UpdateSummary{
+		UpdateTime: updateTime,
+		Duration:   duration,
+	}
+	for _, channels := range source.TvgNameChannels {
+		if len(channels) == 0 {
+			continue
+		}
+		summary.ChannelCount++
+		summary.UrlCount += len(channels)
+	}
+	return summary
+}
+
+// ValidateUpdateTimeChannel checks the update time channel config, which should be done before testing
+// as the channels are only created when writing the outputs. A disabled config is always valid.
+func ValidateUpdateTimeChannel(c *proto.UpdateTimeChannel) error {
+	if c == nil || !c.Enable {
+		return nil
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone of update time channel: %w", err)
+	}
+	_, err := updateTimeChannelUrl(c)
+	return err
+}
+
+// updateTimeChannelUrl returns the absolute url of the update time channels, which is the configured url,
+// or the url of the placeholder file served under the placeholder base url if no url is configured.
+func updateTimeChannelUrl(c *proto.UpdateTimeChannel) (string, error) {
+	if c.Url != "" {
+		if !isAbsoluteUrl(c.Url) {
+			return "", fmt.Errorf("url of update time channel is not absolute: %s", c.Url)
+		}
+		return c.Url, nil
+	}
+	if c.PlaceholderFile == "" {
+		return "", errors.New("neither url nor placeholder file of update time channel is configured")
+	}
+	if !isAbsoluteUrl(c.PlaceholderBaseUrl) {
+		return "", fmt.Errorf("placeholder base url of update time channel is not absolute: %q", c.PlaceholderBaseUrl)
+	}
+	base, _ := url.Parse(c.PlaceholderBaseUrl)
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return base.ResolveReference(&url.URL{Path: filepath.Base(c.PlaceholderFile)}).String(), nil
+}
+
+// isAbsoluteUrl reports whether the url has a scheme and a host.
+func isAbsoluteUrl(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// NewUpdateTimeChannels creates the pseudo channels showing the update time of the output,
+// and the summary of the run if it is enabled.
+// If no url is configured, the url of the placeholder file under the placeholder base url is used,
+// which should be written by OutputPlaceholderM3u8Bz into the directory served at the base url.
+// It returns nil if the update time channel is not configured or disabled.
+func NewUpdateTimeChannels(c *proto.UpdateTimeChannel, summary *UpdateSummary) ([]*Channel, error) {
+	if c == nil || !c.Enable {
+		return nil, nil
+	}
+
+	group := c.Group
+	if group == "" {
+		group = DefaultUpdateTimeGroup
+	}
+	timeFormat := c.TimeFormat
+	if timeFormat == "" {
+		timeFormat = DefaultUpdateTimeTimeFormat
+	}
+	updateTime := summary.UpdateTime
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone of update time channel: %w", err)
+		}
+		updateTime = updateTime.In(loc)
+	}
+	channelUrl, err := updateTimeChannelUrl(c)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := []string{updateTime.Format(timeFormat)}
+	if c.Summary {
+		titles = append(titles,
+			fmt.Sprintf("频道数 %d 地址数 %d", summary.ChannelCount, summary.UrlCount),
+			fmt.Sprintf("耗时 %s", summary.Duration.Round(time.Second)),
+		)
+	}
+	channels := make([]*Channel, 0, len(titles))
+	for _, title := range titles {
+		channels = append(channels, &Channel{
+			TvgName: group,
+			TvgLogo: c.Logo,
+			Group:   group,
+			Title:   title,
+			Url:     channelUrl,
+		})
+	}
+	return channels, nil
+}
+
+// OutputPlaceholderM3u8Bz returns an empty VOD m3u8 playlist,
+// which is used as the stream of the update time channels if no url is configured.
+func OutputPlaceholderM3u8Bz() []byte {
+	return []byte(TagExtm3u + "\n" +
+		TagExtXVersion + ":3\n" +
+		TagExtXTargetDuration + ":10\n" +
+		"#EXT-X-PLAYLIST-TYPE:VOD\n" +
+		"#EXT-X-ENDLIST\n")
+}
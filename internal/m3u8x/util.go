@@ -1,7 +1,14 @@
 package m3u8x
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,12 +38,114 @@ func FilterTvgNameOfSource(source *ProgramListSource, groupList []*proto.GroupLi
 	source.TvgNameChannels = newTvgNameGroup
 }
 
+// FilterLineLabelOfSource removes the channels whose line label matches any excluded label,
+// or matches none of the included labels if any are configured.
+// Channels without line label are always kept. See matchLineLabel for how labels are matched.
+func FilterLineLabelOfSource(source *ProgramListSource, lineLabels *proto.LineLabels) {
+	if lineLabels == nil || (len(lineLabels.Include) == 0 && len(lineLabels.Exclude) == 0) {
+		return
+	}
+	for tvgName, channels := range source.TvgNameChannels {
+		filtered := make([]*Channel, 0, len(channels))
+		for _, channel := range channels {
+			if keepLineLabel(channel.Label, lineLabels) {
+				filtered = append(filtered, channel)
+			}
+		}
+		source.TvgNameChannels[tvgName] = filtered
+	}
+}
+
+// keepLineLabel reports whether a channel with the line label is kept by FilterLineLabelOfSource.
+func keepLineLabel(label string, lineLabels *proto.LineLabels) bool {
+	if label == "" || lineLabels == nil {
+		return true
+	}
+	if matchLineLabel(label, lineLabels.Exclude) >= 0 {
+		return false
+	}
+	return len(lineLabels.Include) == 0 || matchLineLabel(label, lineLabels.Include) >= 0
+}
+
+// NewChannelFilter creates a filter for ParseOptions which keeps the same channels as
+// FilterTvgNameOfSource and FilterLineLabelOfSource, so that they can be filtered while parsing.
+func NewChannelFilter(groupList []*proto.GroupList, lineLabels *proto.LineLabels) func(tvgName, label string) bool {
+	tvgNames := make(map[string]struct{})
+	for _, gl := range groupList {
+		for _, tvgName := range gl.TvgName {
+			for _, tvgName := range splitTvgNames(tvgName) {
+				tvgNames[tvgName] = struct{}{}
+			}
+		}
+	}
+	return func(tvgName, label string) bool {
+		if _, ok := tvgNames[tvgName]; !ok {
+			return false
+		}
+		return keepLineLabel(label, lineLabels)
+	}
+}
+
+// SortChannelsByLineLabelPreference stably sorts the channels of each tvg name by the order of
+// the preferred line labels, channels matching no preferred label come last.
+func SortChannelsByLineLabelPreference(source *ProgramListSource, prefer []string) {
+	if len(prefer) == 0 {
+		return
+	}
+	rank := func(ch *Channel) int {
+		if idx := matchLineLabel(ch.Label, prefer); idx >= 0 {
+			return idx
+		}
+		return len(prefer)
+	}
+	for _, channels := range source.TvgNameChannels {
+		sort.SliceStable(channels, func(i, j int) bool {
+			return rank(channels[i]) < rank(channels[j])
+		})
+	}
+}
+
+// SortChannelsByStartupLatency stably sorts the channels of each tvg name by their startup latency
+// in buckets of the size, so that the order of channels starting similarly fast is kept.
+// Channels without a test result come last.
+func SortChannelsByStartupLatency(source *ProgramListSource, bucket time.Duration) {
+	if bucket <= 0 {
+		return
+	}
+	rank := func(ch *Channel) int64 {
+		if ch.Test == nil {
+			return math.MaxInt64
+		}
+		return int64(ch.Test.Startup.Total() / bucket)
+	}
+	for _, channels := range source.TvgNameChannels {
+		sort.SliceStable(channels, func(i, j int) bool {
+			return rank(channels[i]) < rank(channels[j])
+		})
+	}
+}
+
+// matchLineLabel returns the index of the first label the line label contains, ignoring case,
+// or -1 if none matches. E.g. the line label "广东电信" matches the label "电信".
+func matchLineLabel(lineLabel string, labels []string) int {
+	if lineLabel == "" {
+		return -1
+	}
+	lineLabel = strings.ToUpper(lineLabel)
+	for i, label := range labels {
+		if label != "" && strings.Contains(lineLabel, strings.ToUpper(label)) {
+			return i
+		}
+	}
+	return -1
+}
+
 // FixChannelGroup updates the group information for channels based on the provided group list.
 // It iterates through each group and assigns the group title to all channels under that group's tvg-name.
 func FixChannelGroup(source *ProgramListSource, groupList []*proto.GroupList) {
 	for _, list := range groupList {
 		for _, tvgName := range list.TvgName {
-			chs, ok := source.TvgNameChannels[tvgName]
+			chs, ok := source.TvgNameChannels[MainTvgName(tvgName)]
 			if !ok {
 				continue
 			}
@@ -47,15 +156,78 @@ func FixChannelGroup(source *ProgramListSource, groupList []*proto.GroupList) {
 	}
 }
 
+const (
+	HeaderFormatNone      = ""          // HeaderFormatNone emits no request headers
+	HeaderFormatExtVlcOpt = "extvlcopt" // HeaderFormatExtVlcOpt emits the User-Agent and Referer as #EXTVLCOPT lines
+	HeaderFormatPipe      = "pipe"      // HeaderFormatPipe appends the pipeHeaders to the url after "|", e.g. url|Referer=...
+)
+
+const (
+	ResolutionNone      = ""          // ResolutionNone emits no resolution
+	ResolutionAttribute = "attribute" // ResolutionAttribute adds an x-resolution attribute with the probed resolution, e.g. 1920x1080
+	ResolutionTitle     = "title"     // ResolutionTitle appends the label of the probed resolution to the title, e.g. CCTV4K 4K
+)
+
+// pipeHeaders are the headers appended to the urls in the pipe header format in sorted order.
+// Other headers, e.g. Authorization and Cookie, are never written into the published playlists.
+var pipeHeaders = []string{"Origin", "Referer", "User-Agent"}
+
+// OutputOptions are the options of outputting a ProgramListSource.
+type OutputOptions struct {
+	UpdateTimeChannels []*Channel                   // UpdateTimeChannels are the pseudo channels showing the update time, see NewUpdateTimeChannels
+	SourceAttribute    bool                         // SourceAttribute adds an x-source attribute with the source of each channel
+	HeaderFormat       string                       // HeaderFormat is how the request headers of the channel urls are emitted in m3u outputs, see HeaderFormat*
+	Resolution         string                       // Resolution is how the resolution of the channels is emitted in m3u outputs, see Resolution*
+	Header             func(url string) http.Header // Header returns the request headers of a channel url, e.g. the ones of its header profile
+}
+
+// writeChannelUrl writes the url of the channel, with its request headers in the header format of the options.
+func (opts *OutputOptions) writeChannelUrl(b *strings.Builder, channelUrl string) {
+	var header http.Header
+	if opts.HeaderFormat != HeaderFormatNone && opts.Header != nil {
+		header = opts.Header(channelUrl)
+	}
+	switch {
+	case len(header) == 0:
+	case opts.HeaderFormat == HeaderFormatExtVlcOpt:
+		if ua := header.Get("User-Agent"); ua != "" {
+			b.WriteString(fmt.Sprintf("#EXTVLCOPT:http-user-agent=%s\n", ua))
+		}
+		if referer := header.Get("Referer"); referer != "" {
+			b.WriteString(fmt.Sprintf("#EXTVLCOPT:http-referrer=%s\n", referer))
+		}
+	case opts.HeaderFormat == HeaderFormatPipe:
+		pairs := make([]string, 0, len(pipeHeaders))
+		for _, key := range pipeHeaders {
+			if value := header.Get(key); value != "" {
+				pairs = append(pairs, key+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+			}
+		}
+		if len(pairs) > 0 {
+			channelUrl += "|" + strings.Join(pairs, "&")
+		}
+	}
+	b.WriteString(channelUrl)
+	b.WriteString("\n")
+}
+
 // OutputProgramListSourceToM3u8Bz converts a ProgramListSource into an M3U8 formatted byte slice.
-// It includes metadata such as tvg-url, a timestamp channel, and all filtered channels grouped accordingly.
+// It includes metadata such as tvg-url, the update time channels, and all filtered channels grouped accordingly.
 // Parameters:
 //   - source: The source containing channel data.
 //   - groupList: A list defining how channels should be grouped.
+//   - opts: The output options, nil means the default options.
 //
 // Returns:
 //   - A byte slice representing the M3U8 content.
-func OutputProgramListSourceToM3u8Bz(source *ProgramListSource, groupList []*proto.GroupList) []byte {
+func OutputProgramListSourceToM3u8Bz(
+	source *ProgramListSource,
+	groupList []*proto.GroupList,
+	opts *OutputOptions,
+) []byte {
+	if opts == nil {
+		opts = &OutputOptions{}
+	}
 	b := strings.Builder{}
 	// Write the M3U header with tvg-url information
 	b.WriteString(TagExtm3u)
@@ -64,11 +236,16 @@ func OutputProgramListSourceToM3u8Bz(source *ProgramListSource, groupList []*pro
 	b.WriteString(strings.Join(source.XTvgUrls, "\",\""))
 	b.WriteString("\"\n")
 
-	// Add a special channel indicating the update time
-	b.WriteString("#EXTINF:-1 tvg-name=\"更新时间\" tvg-logo=\"https://avatars.githubusercontent.com/u/125233001?v=4\" group-title=\"更新时间\",")
-	b.WriteString(time.Now().Format("20060102 15:04:05"))
-	b.WriteString("\n")
-	b.WriteString("http://tc-tct.douyucdn2.cn/dyliveflv1/122402rK7MO9bXSq_2000.flv?wsAuth=8cea39337984fd3341cc9ec569502e4f&token=cpn-androidmpro-0-122402-0fcea45d2300cfa0ac75fafd8679bb53af10de8c33ae99d9&logo=0&expire=0&did=d010b07dcb997ada9934081c873542f0&origin=tct&vhost=p\n")
+	// Add the special channels indicating the update time
+	for _, channel := range opts.UpdateTimeChannels {
+		if channel.TvgLogo == "" {
+			b.WriteString(fmt.Sprintf("#EXTINF:-1 tvg-name=\"%s\" group-title=\"%s\",%s\n%s\n",
+				channel.TvgName, channel.Group, channel.Title, channel.Url))
+		} else {
+			b.WriteString(fmt.Sprintf("#EXTINF:-1 tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\",%s\n%s\n",
+				channel.TvgName, channel.TvgLogo, channel.Group, channel.Title, channel.Url))
+		}
+	}
 
 	// Process each group and its associated channels
 	tvgId := 0
@@ -76,19 +253,31 @@ func OutputProgramListSourceToM3u8Bz(source *ProgramListSource, groupList []*pro
 		group := list.Group
 		for _, tvgName := range list.TvgName {
 			tvgId++
-			channels, ok := source.TvgNameChannels[tvgName]
+			channels, ok := source.TvgNameChannels[MainTvgName(tvgName)]
 			if !ok {
 				continue
 			}
 			for _, channel := range channels {
 				// Format each channel line according to M3U8 specification
-				if channel.TvgLogo == "" {
-					b.WriteString(fmt.Sprintf("#EXTINF:-1 tvg-id=\"%d\" tvg-name=\"%s\" group-title=\"%s\",%s\n%s\n",
-						tvgId, channel.TvgName, group, channel.Title, channel.Url))
-				} else {
-					b.WriteString(fmt.Sprintf("#EXTINF:-1 tvg-id=\"%d\" tvg-name=\"%s\" tvg-logo=\"%s\" group-title=\"%s\",%s\n%s\n",
-						tvgId, channel.TvgName, channel.TvgLogo, group, channel.Title, channel.Url))
+				b.WriteString(fmt.Sprintf("#EXTINF:-1 tvg-id=\"%d\" tvg-name=\"%s\"", tvgId, channel.TvgName))
+				if channel.TvgLogo != "" {
+					b.WriteString(fmt.Sprintf(" tvg-logo=\"%s\"", channel.TvgLogo))
+				}
+				if opts.SourceAttribute && channel.Source != "" {
+					b.WriteString(fmt.Sprintf(" x-source=\"%s\"", channel.Source))
 				}
+				title := channel.Title
+				if channel.Test != nil && channel.Test.Media.HasResolution() {
+					media := channel.Test.Media
+					switch opts.Resolution {
+					case ResolutionAttribute:
+						b.WriteString(fmt.Sprintf(" x-resolution=\"%s\"", media.Resolution()))
+					case ResolutionTitle:
+						title += " " + media.ResolutionLabel()
+					}
+				}
+				b.WriteString(fmt.Sprintf(" group-title=\"%s\",%s\n", group, title))
+				opts.writeChannelUrl(&b, channel.Url)
 			}
 		}
 	}
@@ -98,3 +287,151 @@ func OutputProgramListSourceToM3u8Bz(source *ProgramListSource, groupList []*pro
 func splitTvgNames(tvgNames string) []string {
 	return strings.Split(strings.ReplaceAll(tvgNames, "，", ","), ",")
 }
+
+// MainTvgName returns the main tvg name of a tvg name entry of the group list,
+// which is the leftmost one if multiple tvg names are merged.
+func MainTvgName(tvgNames string) string {
+	return splitTvgNames(tvgNames)[0]
+}
+
+const (
+	IpPreferenceAny        = "any"
+	IpPreferenceIpv4       = "ipv4"
+	IpPreferenceIpv6       = "ipv6"
+	IpPreferencePreferIpv4 = "prefer_ipv4"
+	IpPreferencePreferIpv6 = "prefer_ipv6"
+)
+
+var (
+	// outputFormats are the formats of outputs, the format of an output is detected by its file extension if empty.
+	outputFormats = []string{"", "m3u", "m3u8", "txt"}
+	ipPreferences = []string{
+		"", IpPreferenceAny, IpPreferenceIpv4, IpPreferenceIpv6, IpPreferencePreferIpv4, IpPreferencePreferIpv6,
+	}
+	headerFormats = []string{HeaderFormatNone, HeaderFormatExtVlcOpt, HeaderFormatPipe}
+	resolutions   = []string{ResolutionNone, ResolutionAttribute, ResolutionTitle}
+)
+
+// ValidateOutput checks the output config against the group list, which should be done before testing
+// as the outputs are only written after it. Values are case-insensitive like when the output is written.
+func ValidateOutput(output *proto.Output, groupList []*proto.GroupList) error {
+	if output.File == "" {
+		return errors.New("file of output is not configured")
+	}
+	if !slices.Contains(outputFormats, strings.ToLower(output.Format)) {
+		return fmt.Errorf("unknown format of output: %s", output.Format)
+	}
+	if !slices.Contains(ipPreferences, strings.ToLower(output.IpPreference)) {
+		return fmt.Errorf("unknown ip preference of output: %s", output.IpPreference)
+	}
+	if !slices.Contains(headerFormats, strings.ToLower(output.HeaderFormat)) {
+		return fmt.Errorf("unknown header format of output: %s", output.HeaderFormat)
+	}
+	if !slices.Contains(resolutions, strings.ToLower(output.Resolution)) {
+		return fmt.Errorf("unknown resolution of output: %s", output.Resolution)
+	}
+	for _, group := range output.Groups {
+		if !slices.ContainsFunc(groupList, func(list *proto.GroupList) bool { return list.Group == group }) {
+			return fmt.Errorf("unknown group of output: %s", group)
+		}
+	}
+	return nil
+}
+
+// SelectOutputSource builds the source and group list of a single output from the tested source.
+// Only the groups listed in the output are kept (all groups if none are listed), channels are
+// filtered and ordered by the address-family preference of the output, and the number of urls
+// of each channel is limited to MaxUrlsPerChannel if it is positive.
+// The given source is not modified, the returned source shares the channels with it.
+func SelectOutputSource(
+	source *ProgramListSource,
+	groupList []*proto.GroupList,
+	output *proto.Output,
+) (*ProgramListSource, []*proto.GroupList) {
+	selectedGroupList := groupList
+	if len(output.Groups) > 0 {
+		selectedGroupList = make([]*proto.GroupList, 0, len(output.Groups))
+		for _, list := range groupList {
+			if slices.Contains(output.Groups, list.Group) {
+				selectedGroupList = append(selectedGroupList, list)
+			}
+		}
+	}
+
+	selected := NewProgramListSource()
+	selected.XTvgUrls = source.XTvgUrls
+	for _, list := range selectedGroupList {
+		for _, tvgName := range list.TvgName {
+			tvgNameMain := MainTvgName(tvgName)
+			channels, ok := source.TvgNameChannels[tvgNameMain]
+			if !ok {
+				continue
+			}
+			channels = sortChannelsByIpPreference(channels, output.IpPreference)
+			if output.MaxUrlsPerChannel > 0 && int64(len(channels)) > output.MaxUrlsPerChannel {
+				channels = channels[:output.MaxUrlsPerChannel]
+			}
+			selected.TvgNameChannels[tvgNameMain] = channels
+		}
+	}
+	return selected, selectedGroupList
+}
+
+// sortChannelsByIpPreference returns a new slice of the channels filtered or stably ordered
+// by the address family of their urls' hosts.
+// Urls whose host is a domain name are treated as matching any address family.
+func sortChannelsByIpPreference(channels []*Channel, ipPreference string) []*Channel {
+	res := make([]*Channel, 0, len(channels))
+	switch strings.ToLower(ipPreference) {
+	case IpPreferenceIpv4:
+		for _, ch := range channels {
+			if urlIpFamily(ch.Url) != 6 {
+				res = append(res, ch)
+			}
+		}
+	case IpPreferenceIpv6:
+		for _, ch := range channels {
+			if urlIpFamily(ch.Url) != 4 {
+				res = append(res, ch)
+			}
+		}
+	case IpPreferencePreferIpv4, IpPreferencePreferIpv6:
+		preferred, other := 4, 6
+		if strings.ToLower(ipPreference) == IpPreferencePreferIpv6 {
+			preferred, other = 6, 4
+		}
+		res = append(res, channels...)
+		rank := func(ch *Channel) int {
+			switch urlIpFamily(ch.Url) {
+			case preferred:
+				return 0
+			case other:
+				return 2
+			default:
+				return 1
+			}
+		}
+		sort.SliceStable(res, func(i, j int) bool {
+			return rank(res[i]) < rank(res[j])
+		})
+	default:
+		res = append(res, channels...)
+	}
+	return res
+}
+
+// urlIpFamily returns 4 or 6 if the host of the url is an IPv4 or IPv6 address literal, otherwise 0.
+func urlIpFamily(rawUrl string) int {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return 0
+	}
+	ip := net.ParseIP(u.Hostname())
+	if ip == nil {
+		return 0
+	}
+	if ip.To4() != nil {
+		return 4
+	}
+	return 6
+}
@@ -0,0 +1,248 @@
+package m3u8x
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/mediax"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectOutputSource(t *testing.T) {
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Url: "http://[2409:8087::1]/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://cctv.example.com/cctv1.m3u8"},
+		{TvgName: "CCTV1", Url: "http://1.2.3.4/cctv1.m3u8"},
+	}
+	source.TvgNameChannels["金鹰卡通"] = []*Channel{
+		{TvgName: "金鹰卡通", Url: "http://1.2.3.4/jykt.m3u8"},
+	}
+	groupList := []*proto.GroupList{
+		{Group: "央视", TvgName: []string{"CCTV1,CCTV1综合"}},
+		{Group: "少儿动画", TvgName: []string{"金鹰卡通"}},
+	}
+
+	selected, selectedGroupList := SelectOutputSource(source, groupList, &proto.Output{
+		Groups: []string{"少儿动画"},
+	})
+	require.Len(t, selectedGroupList, 1)
+	require.Equal(t, "少儿动画", selectedGroupList[0].Group)
+	require.Len(t, selected.TvgNameChannels, 1)
+	require.Len(t, selected.TvgNameChannels["金鹰卡通"], 1)
+
+	selected, _ = SelectOutputSource(source, groupList, &proto.Output{
+		IpPreference: IpPreferenceIpv4,
+	})
+	require.Len(t, selected.TvgNameChannels["CCTV1"], 2)
+	require.Equal(t, "http://cctv.example.com/cctv1.m3u8", selected.TvgNameChannels["CCTV1"][0].Url)
+
+	selected, _ = SelectOutputSource(source, groupList, &proto.Output{
+		IpPreference:      IpPreferencePreferIpv6,
+		MaxUrlsPerChannel: 2,
+	})
+	require.Len(t, selected.TvgNameChannels["CCTV1"], 2)
+	require.Equal(t, "http://[2409:8087::1]/cctv1.m3u8", selected.TvgNameChannels["CCTV1"][0].Url)
+	require.Equal(t, "http://cctv.example.com/cctv1.m3u8", selected.TvgNameChannels["CCTV1"][1].Url)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 3)
+}
+
+func TestValidateOutput(t *testing.T) {
+	groupList := []*proto.GroupList{{Group: "央视", TvgName: []string{"CCTV1"}}}
+	require.NoError(t, ValidateOutput(&proto.Output{File: "./output/result.m3u"}, groupList))
+	require.NoError(t, ValidateOutput(&proto.Output{
+		File:         "./output/result.txt",
+		Format:       "TXT",
+		Groups:       []string{"央视"},
+		IpPreference: IpPreferencePreferIpv6,
+		HeaderFormat: HeaderFormatPipe,
+		Resolution:   ResolutionTitle,
+	}, groupList))
+
+	for _, output := range []*proto.Output{
+		{},
+		{File: "./output/result.m3u", Format: "json"},
+		{File: "./output/result.m3u", Groups: []string{"卫视"}},
+		{File: "./output/result.m3u", IpPreference: "ipv5"},
+		{File: "./output/result.m3u", HeaderFormat: "vlc"},
+		{File: "./output/result.m3u", Resolution: "label"},
+	} {
+		require.Error(t, ValidateOutput(output, groupList), output)
+	}
+}
+
+func TestNewUpdateTimeChannels(t *testing.T) {
+	summary := &UpdateSummary{
+		UpdateTime:   time.Date(2025, 8, 1, 0, 30, 0, 0, time.UTC),
+		ChannelCount: 10,
+		UrlCount:     25,
+		Duration:     5*time.Minute + 300*time.Millisecond,
+	}
+
+	channels, err := NewUpdateTimeChannels(nil, summary)
+	require.NoError(t, err)
+	require.Nil(t, channels)
+
+	_, err = NewUpdateTimeChannels(&proto.UpdateTimeChannel{Enable: true}, summary)
+	require.Error(t, err)
+
+	channels, err = NewUpdateTimeChannels(&proto.UpdateTimeChannel{
+		Enable:             true,
+		Timezone:           "Asia/Shanghai",
+		PlaceholderFile:    "./output/update_time.m3u8",
+		PlaceholderBaseUrl: "http://192.168.1.2:8080/iptv",
+		Summary:            true,
+	}, summary)
+	require.NoError(t, err)
+	require.Len(t, channels, 3)
+	require.Equal(t, DefaultUpdateTimeGroup, channels[0].Group)
+	require.Equal(t, "20250801 08:30:00", channels[0].Title)
+	require.Equal(t, "http://192.168.1.2:8080/iptv/update_time.m3u8", channels[0].Url)
+	require.Equal(t, "频道数 10 地址数 25", channels[1].Title)
+	require.Equal(t, "耗时 5m0s", channels[2].Title)
+}
+
+func TestValidateUpdateTimeChannel(t *testing.T) {
+	require.NoError(t, ValidateUpdateTimeChannel(nil))
+	require.NoError(t, ValidateUpdateTimeChannel(&proto.UpdateTimeChannel{Url: "update_time.m3u8"}))
+	require.NoError(t, ValidateUpdateTimeChannel(&proto.UpdateTimeChannel{Enable: true, Url: "http://a/update_time.m3u8"}))
+	require.NoError(t, ValidateUpdateTimeChannel(&proto.UpdateTimeChannel{
+		Enable: true, PlaceholderFile: "./output/update_time.m3u8", PlaceholderBaseUrl: "http://a/",
+	}))
+
+	for _, c := range []*proto.UpdateTimeChannel{
+		{Enable: true},
+		{Enable: true, Url: "update_time.m3u8"},
+		{Enable: true, Url: "http://a/update_time.m3u8", Timezone: "Mars/Olympus"},
+		// the placeholder file is not reachable without a base url
+		{Enable: true, PlaceholderFile: "./output/update_time.m3u8"},
+		{Enable: true, PlaceholderFile: "./output/update_time.m3u8", PlaceholderBaseUrl: "/iptv"},
+	} {
+		require.Error(t, ValidateUpdateTimeChannel(c), c)
+	}
+}
+
+func TestFilterLineLabelOfSource(t *testing.T) {
+	newSource := func() *ProgramListSource {
+		source := NewProgramListSource()
+		source.TvgNameChannels["CCTV1"] = []*Channel{
+			{TvgName: "CCTV1", Url: "http://a/cctv1.m3u8", Label: "IPV6"},
+			{TvgName: "CCTV1", Url: "http://b/cctv1.m3u8"},
+			{TvgName: "CCTV1", Url: "http://c/cctv1.m3u8", Label: "广东联通"},
+			{TvgName: "CCTV1", Url: "http://d/cctv1.m3u8", Label: "电信"},
+		}
+		return source
+	}
+	urls := func(source *ProgramListSource) []string {
+		var res []string
+		for _, channel := range source.TvgNameChannels["CCTV1"] {
+			res = append(res, channel.Url)
+		}
+		return res
+	}
+
+	source := newSource()
+	FilterLineLabelOfSource(source, &proto.LineLabels{Exclude: []string{"ipv6"}})
+	require.Equal(t, []string{"http://b/cctv1.m3u8", "http://c/cctv1.m3u8", "http://d/cctv1.m3u8"}, urls(source))
+
+	source = newSource()
+	FilterLineLabelOfSource(source, &proto.LineLabels{Include: []string{"联通"}})
+	require.Equal(t, []string{"http://b/cctv1.m3u8", "http://c/cctv1.m3u8"}, urls(source))
+
+	source = newSource()
+	SortChannelsByLineLabelPreference(source, []string{"电信", "联通"})
+	require.Equal(t, []string{
+		"http://d/cctv1.m3u8", "http://c/cctv1.m3u8", "http://a/cctv1.m3u8", "http://b/cctv1.m3u8",
+	}, urls(source))
+}
+
+func TestSortChannelsByStartupLatency(t *testing.T) {
+	startup := func(ms int) *ChannelTestResult {
+		return &ChannelTestResult{Passed: true, Startup: StartupLatency{
+			Playlist:   time.Duration(ms/2) * time.Millisecond,
+			FirstFrame: time.Duration(ms-ms/2) * time.Millisecond,
+		}}
+	}
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV1"] = []*Channel{
+		{Url: "http://a/cctv1.m3u8"},
+		{Url: "http://b/cctv1.m3u8", Test: startup(1800)},
+		{Url: "http://c/cctv1.m3u8", Test: startup(700)},
+		{Url: "http://d/cctv1.m3u8", Test: startup(300)},
+	}
+	SortChannelsByStartupLatency(source, time.Second)
+	var urls []string
+	for _, channel := range source.TvgNameChannels["CCTV1"] {
+		urls = append(urls, channel.Url)
+	}
+	// c and d are in the same bucket and keep their order
+	require.Equal(t, []string{
+		"http://c/cctv1.m3u8", "http://d/cctv1.m3u8", "http://b/cctv1.m3u8", "http://a/cctv1.m3u8",
+	}, urls)
+}
+
+func TestOutputProgramListSourceToM3u8Bz_Header(t *testing.T) {
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV1"] = []*Channel{
+		{TvgName: "CCTV1", Title: "CCTV1", Url: "http://cdn.example.com/cctv1.m3u8"},
+		{TvgName: "CCTV1", Title: "CCTV1", Url: "http://other/cctv1.m3u8"},
+	}
+	groupList := []*proto.GroupList{{Group: "央视", TvgName: []string{"CCTV1"}}}
+	header := func(url string) http.Header {
+		if !strings.Contains(url, "example.com") {
+			return nil
+		}
+		return http.Header{
+			"User-Agent":    {"okhttp/3.12"},
+			"Referer":       {"http://www.example.com/"},
+			"Authorization": {"Basic dXNlcjpwYXNz"},
+			"Cookie":        {"token=secret"},
+		}
+	}
+
+	bz := string(OutputProgramListSourceToM3u8Bz(source, groupList, &OutputOptions{
+		HeaderFormat: HeaderFormatExtVlcOpt,
+		Header:       header,
+	}))
+	require.Contains(t, bz, "#EXTVLCOPT:http-user-agent=okhttp/3.12\n#EXTVLCOPT:http-referrer=http://www.example.com/\nhttp://cdn.example.com/cctv1.m3u8\n")
+	require.Contains(t, bz, ",CCTV1\nhttp://other/cctv1.m3u8\n")
+
+	bz = string(OutputProgramListSourceToM3u8Bz(source, groupList, &OutputOptions{
+		HeaderFormat: HeaderFormatPipe,
+		Header:       header,
+	}))
+	require.Contains(t, bz, "\nhttp://cdn.example.com/cctv1.m3u8|Referer=http%3A%2F%2Fwww.example.com%2F&User-Agent=okhttp%2F3.12\n")
+	require.Contains(t, bz, "\nhttp://other/cctv1.m3u8\n")
+	// the credentials are never published
+	require.NotContains(t, bz, "Authorization")
+	require.NotContains(t, bz, "Cookie")
+
+	bz = string(OutputProgramListSourceToM3u8Bz(source, groupList, &OutputOptions{Header: header}))
+	require.NotContains(t, bz, "okhttp")
+}
+
+func TestOutputProgramListSourceToM3u8Bz_Resolution(t *testing.T) {
+	source := NewProgramListSource()
+	source.TvgNameChannels["CCTV4K"] = []*Channel{
+		{TvgName: "CCTV4K", Title: "CCTV4K", Url: "http://a/cctv4k.m3u8",
+			Test: &ChannelTestResult{Passed: true, Media: &mediax.StreamInfo{Width: 3840, Height: 2160}}},
+		{TvgName: "CCTV4K", Title: "CCTV4K", Url: "http://b/cctv4k.m3u8",
+			Test: &ChannelTestResult{Passed: true}},
+	}
+	groupList := []*proto.GroupList{{Group: "4K", TvgName: []string{"CCTV4K"}}}
+
+	bz := string(OutputProgramListSourceToM3u8Bz(source, groupList, &OutputOptions{Resolution: ResolutionAttribute}))
+	require.Contains(t, bz, `tvg-name="CCTV4K" x-resolution="3840x2160" group-title="4K",CCTV4K`+"\nhttp://a/cctv4k.m3u8\n")
+	require.Contains(t, bz, `tvg-name="CCTV4K" group-title="4K",CCTV4K`+"\nhttp://b/cctv4k.m3u8\n")
+
+	bz = string(OutputProgramListSourceToM3u8Bz(source, groupList, &OutputOptions{Resolution: ResolutionTitle}))
+	require.Contains(t, bz, `group-title="4K",CCTV4K 4K`+"\nhttp://a/cctv4k.m3u8\n")
+	require.Contains(t, bz, `group-title="4K",CCTV4K`+"\nhttp://b/cctv4k.m3u8\n")
+
+	bz = string(OutputProgramListSourceToM3u8Bz(source, groupList, nil))
+	require.NotContains(t, bz, "x-resolution")
+	require.NotContains(t, bz, "CCTV4K 4K")
+}
@@ -0,0 +1,106 @@
+package mediax
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// codecs of the elementary streams.
+const (
+	CodecH264       = "h264"
+	CodecH265       = "h265"
+	CodecMPEG2Video = "mpeg2video"
+	CodecAAC        = "aac"
+	CodecMP2        = "mp2"
+	CodecAC3        = "ac3"
+)
+
+// StreamInfo is the information of a media stream.
+type StreamInfo struct {
+	Format      Format
+	VideoCodec  string
+	AudioCodecs []string
+	Width       int
+	Height      int
+	FrameRate   float64
+	// Bitrate is the bits per second of the stream, 0 if unknown.
+	Bitrate int64
+}
+
+// GetBitrate returns the bitrate of the stream, or 0 if it is unknown.
+func (i *StreamInfo) GetBitrate() int64 {
+	if i == nil {
+		return 0
+	}
+	return i.Bitrate
+}
+
+// HasResolution reports whether the resolution of the stream is known.
+func (i *StreamInfo) HasResolution() bool {
+	return i != nil && i.Width > 0 && i.Height > 0
+}
+
+// Resolution returns the resolution of the stream like "1920x1080", or "" if it is unknown.
+func (i *StreamInfo) Resolution() string {
+	if !i.HasResolution() {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", i.Width, i.Height)
+}
+
+// Lines returns the lines of the resolution of the stream, which is the shorter side of the picture,
+// or 0 if the resolution is unknown.
+func (i *StreamInfo) Lines() int {
+	if !i.HasResolution() {
+		return 0
+	}
+	return min(i.Width, i.Height)
+}
+
+// ResolutionLabel returns a label of the resolution of the stream like "4K" or "1080P", or "" if it is unknown.
+func (i *StreamInfo) ResolutionLabel() string {
+	lines := i.Lines()
+	switch {
+	case lines == 0:
+		return ""
+	case lines >= 4320:
+		return "8K"
+	case lines >= 2160:
+		return "4K"
+	case lines >= 1440:
+		return "2K"
+	}
+	return strconv.Itoa(lines) + "P"
+}
+
+// ParseResolution parses a resolution like "4K", "1080p", "720" or "1920x1080" and returns its lines,
+// which are compared with StreamInfo.Lines.
+func ParseResolution(s string) (int, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	switch v {
+	case "8k":
+		return 4320, nil
+	case "4k", "uhd":
+		return 2160, nil
+	case "2k":
+		return 1440, nil
+	case "fhd":
+		return 1080, nil
+	case "hd":
+		return 720, nil
+	}
+	if w, h, ok := strings.Cut(v, "x"); ok {
+		width, errW := strconv.Atoi(w)
+		height, errH := strconv.Atoi(h)
+		if errW != nil || errH != nil || width <= 0 || height <= 0 {
+			return 0, fmt.Errorf("invalid resolution: %s", s)
+		}
+		return min(width, height), nil
+	}
+	lines, err := strconv.Atoi(strings.TrimSuffix(v, "p"))
+	if err != nil || lines <= 0 {
+		return 0, fmt.Errorf("invalid resolution: %s", s)
+	}
+	return lines, nil
+}
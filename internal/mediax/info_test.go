@@ -0,0 +1,38 @@
+package mediax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseResolution(t *testing.T) {
+	for s, lines := range map[string]int{
+		"4K":        2160,
+		"uhd":       2160,
+		"1080p":     1080,
+		"1080P":     1080,
+		" 720 ":     720,
+		"1920x1080": 1080,
+		"1080x1920": 1080,
+	} {
+		v, err := ParseResolution(s)
+		require.NoError(t, err, s)
+		require.Equal(t, lines, v, s)
+	}
+	for _, s := range []string{"", "p", "hdr", "1920x", "0x0", "-720p"} {
+		_, err := ParseResolution(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestStreamInfo_ResolutionLabel(t *testing.T) {
+	var unknown *StreamInfo
+	require.Equal(t, "", unknown.ResolutionLabel())
+	require.Equal(t, "", (&StreamInfo{Width: 1920}).ResolutionLabel())
+	require.Equal(t, "4K", (&StreamInfo{Width: 3840, Height: 2160}).ResolutionLabel())
+	require.Equal(t, "4K", (&StreamInfo{Width: 4096, Height: 2160}).ResolutionLabel())
+	require.Equal(t, "1080P", (&StreamInfo{Width: 1920, Height: 1080}).ResolutionLabel())
+	require.Equal(t, "576P", (&StreamInfo{Width: 720, Height: 576}).ResolutionLabel())
+	require.Equal(t, "1920x1080", (&StreamInfo{Width: 1920, Height: 1080}).Resolution())
+}
@@ -0,0 +1,164 @@
+package mediax
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Format is the container format of a media stream.
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatTS      Format = "ts"
+	FormatFLV     Format = "flv"
+)
+
+// maxProbeSize is the max number of bytes of a stream probed before giving up.
+const maxProbeSize = 1 << 20
+
+// Probe probes a media stream fed in chunks.
+// It detects the first decodable bytes of the stream, which are the PMT following the PAT of an MPEG-TS stream,
+// or the first video tag of an FLV stream, and gives up if the format is unknown or nothing is found
+// in the first 1MB of the stream. A TS stream is demuxed further for its StreamInfo.
+type Probe struct {
+	format  Format
+	buf     []byte
+	fed     int
+	started bool
+	done    bool
+
+	ts   *tsDemuxer // ts demuxes a TS stream
+	skip int        // skip is the number of bytes of an FLV stream to skip before the next tag
+}
+
+// NewProbe creates a Probe.
+func NewProbe() *Probe {
+	return &Probe{}
+}
+
+// Feed feeds the next bytes of the stream, and reports whether the first decodable bytes have arrived.
+func (d *Probe) Feed(p []byte) bool {
+	if d.done {
+		return d.started
+	}
+	if d.started {
+		if d.ts != nil {
+			d.ts.feed(p)
+		}
+		return true
+	}
+	d.fed += len(p)
+	d.buf = append(d.buf, p...)
+	if d.format == FormatUnknown {
+		format, offset := detectFormat(d.buf)
+		if format == FormatUnknown {
+			d.giveUpIfTooLong()
+			return false
+		}
+		d.format = format
+		d.buf = d.buf[offset:]
+		switch format {
+		case FormatTS:
+			d.ts = newTSDemuxer()
+		case FormatFLV:
+			d.skip = -1
+		}
+	}
+
+	switch d.format {
+	case FormatTS:
+		d.feedTS()
+	case FormatFLV:
+		d.feedFLV()
+	}
+	if !d.started {
+		d.giveUpIfTooLong()
+	}
+	return d.started
+}
+
+// Format returns the format of the stream, which is unknown until enough bytes are fed.
+func (d *Probe) Format() Format {
+	return d.format
+}
+
+// Info returns the StreamInfo of the bytes fed so far, or nil if the stream has not started.
+// Only the format of an FLV stream is known.
+func (d *Probe) Info() *StreamInfo {
+	switch {
+	case !d.started:
+		return nil
+	case d.ts != nil:
+		return d.ts.info()
+	}
+	return &StreamInfo{Format: d.format}
+}
+
+func (d *Probe) giveUpIfTooLong() {
+	if d.fed >= maxProbeSize {
+		d.done = true
+		d.buf = nil
+		d.ts = nil
+	}
+}
+
+// detectFormat detects the format of the head of a stream and returns the offset of its first packet.
+func detectFormat(head []byte) (Format, int) {
+	if bytes.HasPrefix(head, []byte("FLV")) {
+		return FormatFLV, 0
+	}
+	if offset, ok := syncTS(head); ok {
+		return FormatTS, offset
+	}
+	return FormatUnknown, 0
+}
+
+// feedTS demuxes the buffer, looking for the PMT listed in the PAT.
+func (d *Probe) feedTS() {
+	d.ts.feed(d.buf)
+	d.buf = d.buf[:0]
+	d.started = d.ts.pmtFound
+}
+
+// feedFLV walks the tags of the FLV stream in the buffer, looking for the first complete video tag.
+func (d *Probe) feedFLV() {
+	const (
+		headerSize    = 9
+		tagHeaderSize = 11
+		tagTypeVideo  = 9
+	)
+	b := d.buf
+	if d.skip < 0 {
+		if len(b) < headerSize {
+			return
+		}
+		// skip the header and the first PreviousTagSize
+		d.skip = int(binary.BigEndian.Uint32(b[5:9])) + 4
+	}
+	for {
+		if d.skip > 0 {
+			n := min(d.skip, len(b))
+			b = b[n:]
+			d.skip -= n
+			if d.skip > 0 {
+				break
+			}
+		}
+		if len(b) < tagHeaderSize {
+			break
+		}
+		dataSize := int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		if b[0]&0x1f == tagTypeVideo {
+			if len(b) >= tagHeaderSize+dataSize {
+				d.started = true
+				d.buf = nil
+				return
+			}
+			break
+		}
+		// skip the tag and its PreviousTagSize
+		d.skip = tagHeaderSize + dataSize + 4
+	}
+	d.buf = append(d.buf[:0], b...)
+}
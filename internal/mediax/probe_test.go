@@ -0,0 +1,189 @@
+package mediax
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tsPacketOf builds a TS packet of the pid carrying the PSI section, or a stuffed packet if section is nil.
+func tsPacketOf(pid uint16, section []byte) []byte {
+	pkt := bytes.Repeat([]byte{0xff}, tsPacketSize)
+	pkt[0] = tsSyncByte
+	pkt[1] = byte(pid>>8) & 0x1f
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 // payload only
+	if section != nil {
+		pkt[1] |= 0x40
+		pkt[4] = 0 // pointer field
+		copy(pkt[5:], section)
+	}
+	return pkt
+}
+
+// patSection builds a PAT section of a program whose PMT is on the pid.
+func patSection(pmtPid uint16) []byte {
+	return []byte{
+		tableIdPAT, 0xb0, 13, // section_length 13
+		0x00, 0x01, 0xc1, 0x00, 0x00,
+		0x00, 0x01, 0xe0 | byte(pmtPid>>8), byte(pmtPid),
+		0x00, 0x00, 0x00, 0x00, // CRC
+	}
+}
+
+// pmtSection builds a PMT section of an H.264 stream on the pid.
+func pmtSection(pid uint16) []byte {
+	return pmtSectionOf(pid, tsStream{pid: pid, streamType: streamTypeH264})
+}
+
+// pmtSectionOf builds a PMT section of the elementary streams and the PCR PID.
+func pmtSectionOf(pcrPid uint16, streams ...tsStream) []byte {
+	section := []byte{
+		tableIdPMT, 0xb0, byte(9 + 5*len(streams) + 4),
+		0x00, 0x01, 0xc1, 0x00, 0x00,
+		0xe0 | byte(pcrPid>>8), byte(pcrPid), 0xf0, 0x00, // PCR PID, program_info_length
+	}
+	for _, s := range streams {
+		section = append(section, s.streamType, 0xe0|byte(s.pid>>8), byte(s.pid), 0xf0, 0x00)
+	}
+	return append(section, 0x00, 0x00, 0x00, 0x00) // CRC
+}
+
+// tsPESPacketOf builds a TS packet of the pid starting a PES of the PTS and the data,
+// with the PCR in the adaptation field if it is not negative.
+func tsPESPacketOf(pid uint16, pcr, pts int64, data []byte) []byte {
+	pes := []byte{
+		0x00, 0x00, 0x01, 0xe0, 0x00, 0x00, 0x80, 0x80, 5,
+		0x21 | byte(pts>>29)&0x0e, byte(pts >> 22), 0x01 | byte(pts>>14)&0xfe, byte(pts >> 7), 0x01 | byte(pts<<1),
+	}
+	pes = append(pes, data...)
+	// the adaptation field stuffs the packet
+	adaptationField := bytes.Repeat([]byte{0xff}, tsPacketSize-5-len(pes))
+	adaptationField[0] = 0x00
+	if pcr >= 0 {
+		base, ext := pcr/300, pcr%300
+		adaptationField[0] = 0x10
+		copy(adaptationField[1:], []byte{
+			byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1), byte(base<<7) | 0x7e | byte(ext>>8), byte(ext),
+		})
+	}
+	pkt := []byte{tsSyncByte, 0x40 | byte(pid>>8)&0x1f, byte(pid), 0x30, byte(len(adaptationField))}
+	pkt = append(pkt, adaptationField...)
+	return append(pkt, pes...)
+}
+
+func TestProbe_TS(t *testing.T) {
+	var stream []byte
+	stream = append(stream, 0x00, 0x01) // garbage before the first packet
+	stream = append(stream, tsPacketOf(0x100, nil)...)
+	stream = append(stream, tsPacketOf(patPid, patSection(0x1000))...)
+	stream = append(stream, tsPacketOf(0x100, nil)...)
+	stream = append(stream, tsPacketOf(0x1000, pmtSection(0x100))...)
+	stream = append(stream, tsPacketOf(0x100, nil)...)
+
+	d := NewProbe()
+	pmtEnd := 2 + 4*tsPacketSize
+	for i := 0; i < len(stream); i += 100 {
+		started := d.Feed(stream[i:min(i+100, len(stream))])
+		require.Equal(t, i+100 >= pmtEnd, started, i)
+	}
+	require.Equal(t, FormatTS, d.Format())
+}
+
+func TestProbe_FLV(t *testing.T) {
+	tag := func(tagType byte, data []byte) []byte {
+		size := len(data)
+		b := []byte{tagType, byte(size >> 16), byte(size >> 8), byte(size), 0, 0, 0, 0, 0, 0, 0}
+		b = append(b, data...)
+		return append(b, 0, 0, 0, byte(11+size))
+	}
+	stream := []byte{'F', 'L', 'V', 0x01, 0x05, 0, 0, 0, 9, 0, 0, 0, 0}
+	stream = append(stream, tag(18, bytes.Repeat([]byte{1}, 300))...) // script
+	stream = append(stream, tag(8, bytes.Repeat([]byte{2}, 50))...)   // audio
+	videoEnd := len(stream) + 11 + 20
+	stream = append(stream, tag(9, bytes.Repeat([]byte{3}, 20))...) // video
+
+	d := NewProbe()
+	for i := 0; i < len(stream); i += 7 {
+		end := min(i+7, len(stream))
+		require.Equal(t, end >= videoEnd, d.Feed(stream[i:end]), i)
+	}
+	require.Equal(t, FormatFLV, d.Format())
+}
+
+func TestProbe_Unknown(t *testing.T) {
+	d := NewProbe()
+	chunk := bytes.Repeat([]byte("ftyp"), 1024)
+	for i := 0; i < maxProbeSize/len(chunk)+1; i++ {
+		require.False(t, d.Feed(chunk))
+	}
+	require.Equal(t, FormatUnknown, d.Format())
+	require.Nil(t, d.buf)
+}
+
+func TestProbe_TSInfo(t *testing.T) {
+	const videoPid, pmtPid = 0x100, 0x1000
+	keyFrame := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0, 0x00, 0x00, 0x00, 0x01}
+	keyFrame = append(keyFrame, h264SPS(false)...)
+	keyFrame = append(keyFrame, 0x00, 0x00, 0x01, 0x65, 0x88, 0x84)
+	frame := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0, 0x00, 0x00, 0x01, 0x41, 0x9a, 0x02}
+
+	stream := tsPacketOf(patPid, patSection(pmtPid))
+	stream = append(stream, tsPacketOf(pmtPid, pmtSectionOf(videoPid,
+		tsStream{pid: videoPid, streamType: streamTypeH264},
+		tsStream{pid: 0x101, streamType: streamTypeAAC},
+		tsStream{pid: 0x102, streamType: streamTypeMPEG1Audio},
+		tsStream{pid: 0x103, streamType: streamTypeAACLATM},
+	))...)
+	for i := int64(0); i < 50; i++ {
+		data := frame
+		if i%25 == 0 {
+			data = keyFrame
+		}
+		// a packet of 4ms, the PTS of 25fps with B-frames reordered
+		pts := i * ptsHz / 25
+		if i%2 == 1 {
+			pts += ptsHz / 25
+		} else if i > 0 {
+			pts -= ptsHz / 25
+		}
+		stream = append(stream, tsPESPacketOf(videoPid, i*pcrHz/250, pts, data)...)
+	}
+
+	p := NewProbe()
+	require.Nil(t, p.Info())
+	for i := 0; i < len(stream); i += 1000 {
+		p.Feed(stream[i:min(i+1000, len(stream))])
+	}
+	require.Equal(t, &StreamInfo{
+		Format:      FormatTS,
+		VideoCodec:  CodecH264,
+		AudioCodecs: []string{CodecAAC, CodecMP2},
+		Width:       1920,
+		Height:      1080,
+		FrameRate:   25,
+		Bitrate:     tsPacketSize * 8 * 250,
+	}, p.Info())
+}
+
+func TestProbe_TSInfoWithoutTiming(t *testing.T) {
+	const videoPid, pmtPid = 0x100, 0x1000
+	stream := tsPacketOf(patPid, patSection(pmtPid))
+	stream = append(stream, tsPacketOf(pmtPid, pmtSectionOf(videoPid, tsStream{pid: videoPid, streamType: streamTypeH265}))...)
+	keyFrame := append([]byte{0x00, 0x00, 0x01}, h265SPS(3840, 2160, 0)...)
+	for i := int64(0); i < 10; i++ {
+		stream = append(stream, tsPESPacketOf(videoPid, i*pcrHz/1000, i*ptsHz/50, keyFrame)...)
+	}
+
+	p := NewProbe()
+	p.Feed(stream)
+	// the SPS has no timing information, and the PCR spans too short to measure the bitrate
+	require.Equal(t, &StreamInfo{
+		Format:     FormatTS,
+		VideoCodec: CodecH265,
+		Width:      3840,
+		Height:     2160,
+		FrameRate:  50,
+	}, p.Info())
+}
@@ -0,0 +1,330 @@
+package mediax
+
+import (
+	"bytes"
+	"math"
+)
+
+const (
+	nalTypeH264SPS = 7
+	nalTypeH265SPS = 33
+)
+
+// spsInfo is the video information parsed from an SPS.
+type spsInfo struct {
+	width, height int
+	frameRate     float64 // frameRate is 0 if the SPS has no timing information
+}
+
+// findSPS finds and parses the first SPS in the Annex B byte stream of the codec.
+func findSPS(codec string, stream []byte) *spsInfo {
+	for _, nal := range splitNALUnits(stream) {
+		switch {
+		case codec == CodecH264 && nal[0]&0x1f == nalTypeH264SPS:
+			if sps, ok := parseH264SPS(nal); ok {
+				return sps
+			}
+		case codec == CodecH265 && nal[0]>>1&0x3f == nalTypeH265SPS:
+			if sps, ok := parseH265SPS(nal); ok {
+				return sps
+			}
+		}
+	}
+	return nil
+}
+
+// splitNALUnits splits an Annex B byte stream into its NAL units, which are separated by start codes.
+func splitNALUnits(stream []byte) [][]byte {
+	startCode := []byte{0, 0, 1}
+	var nals [][]byte
+	i := bytes.Index(stream, startCode)
+	for i >= 0 {
+		start := i + len(startCode)
+		next := bytes.Index(stream[start:], startCode)
+		end := len(stream)
+		if next >= 0 {
+			end = start + next
+			next = end
+		}
+		// the trailing zeros belong to the next start code
+		nal := bytes.TrimRight(stream[start:end], "\x00")
+		if len(nal) > 0 {
+			nals = append(nals, nal)
+		}
+		i = next
+	}
+	return nals
+}
+
+// unescapeRBSP removes the emulation prevention bytes of a NAL unit.
+func unescapeRBSP(nal []byte) []byte {
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// bitReader reads the bits of an RBSP. Reading past the end yields zeros and sets overflow.
+type bitReader struct {
+	data     []byte
+	pos      int
+	overflow bool
+}
+
+func (r *bitReader) u(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v <<= 1
+		if r.pos >= len(r.data)*8 {
+			r.overflow = true
+			continue
+		}
+		v |= uint64(r.data[r.pos/8]>>(7-r.pos%8)) & 0x01
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) flag() bool {
+	return r.u(1) == 1
+}
+
+func (r *bitReader) skip(n int) {
+	r.pos += n
+	if r.pos > len(r.data)*8 {
+		r.overflow = true
+	}
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (r *bitReader) ue() uint64 {
+	leadingZeros := 0
+	for !r.flag() {
+		leadingZeros++
+		if leadingZeros > 31 || r.overflow {
+			r.overflow = true
+			return 0
+		}
+	}
+	return 1<<leadingZeros - 1 + r.u(leadingZeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (r *bitReader) se() int64 {
+	v := r.ue()
+	if v%2 == 1 {
+		return int64(v+1) / 2
+	}
+	return -int64(v / 2)
+}
+
+// chromaSubsampling returns the horizontal and vertical chroma subsampling of a chroma_format_idc.
+func chromaSubsampling(chromaFormatIdc uint64) (subWidth, subHeight int) {
+	switch chromaFormatIdc {
+	case 1:
+		return 2, 2
+	case 2:
+		return 2, 1
+	}
+	return 1, 1
+}
+
+// parseH264SPS parses the resolution and the frame rate of an H.264 SPS NAL unit.
+func parseH264SPS(nal []byte) (*spsInfo, bool) {
+	r := &bitReader{data: unescapeRBSP(nal[1:])}
+	profileIdc := r.u(8)
+	r.skip(16) // constraint flags and level_idc
+	r.ue()     // seq_parameter_set_id
+
+	chromaFormatIdc := uint64(1)
+	separateColourPlane := false
+	switch profileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormatIdc = r.ue()
+		if chromaFormatIdc == 3 {
+			separateColourPlane = r.flag()
+		}
+		r.ue()    // bit_depth_luma_minus8
+		r.ue()    // bit_depth_chroma_minus8
+		r.skip(1) // qpprime_y_zero_transform_bypass_flag
+		if r.flag() {
+			count := 8
+			if chromaFormatIdc == 3 {
+				count = 12
+			}
+			for i := 0; i < count; i++ {
+				if r.flag() {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					skipScalingList(r, size)
+				}
+			}
+		}
+	}
+
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.skip(1) // delta_pic_order_always_zero_flag
+		r.se()    // offset_for_non_ref_pic
+		r.se()    // offset_for_top_to_bottom_field
+		n := r.ue()
+		if n > 255 {
+			return nil, false
+		}
+		for i := uint64(0); i < n; i++ {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.skip(1) // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := r.ue() + 1
+	heightInMapUnits := r.ue() + 1
+	frameMbsOnly := r.flag()
+	if !frameMbsOnly {
+		r.skip(1) // mb_adaptive_frame_field_flag
+	}
+	r.skip(1) // direct_8x8_inference_flag
+
+	frameHeightFactor := 1
+	if !frameMbsOnly {
+		frameHeightFactor = 2
+	}
+	width := int(widthInMbs) * 16
+	height := int(heightInMapUnits) * 16 * frameHeightFactor
+	if r.flag() {
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropUnitX, cropUnitY := 1, frameHeightFactor
+		if !separateColourPlane && chromaFormatIdc != 0 {
+			subWidth, subHeight := chromaSubsampling(chromaFormatIdc)
+			cropUnitX, cropUnitY = subWidth, subHeight*frameHeightFactor
+		}
+		width -= cropUnitX * int(left+right)
+		height -= cropUnitY * int(top+bottom)
+	}
+	if r.overflow || width <= 0 || height <= 0 {
+		return nil, false
+	}
+
+	sps := &spsInfo{width: width, height: height}
+	if r.flag() {
+		sps.frameRate = parseH264VUIFrameRate(r)
+	}
+	return sps, true
+}
+
+// skipScalingList skips a scaling_list of an H.264 SPS.
+func skipScalingList(r *bitReader, size int) {
+	last, next := int64(8), int64(8)
+	for j := 0; j < size && !r.overflow; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// parseH264VUIFrameRate parses the frame rate from the timing info of the vui_parameters of an H.264 SPS.
+func parseH264VUIFrameRate(r *bitReader) float64 {
+	const extendedSAR = 255
+	if r.flag() { // aspect_ratio_info_present_flag
+		if r.u(8) == extendedSAR {
+			r.skip(32)
+		}
+	}
+	if r.flag() { // overscan_info_present_flag
+		r.skip(1)
+	}
+	if r.flag() { // video_signal_type_present_flag
+		r.skip(4)
+		if r.flag() { // colour_description_present_flag
+			r.skip(24)
+		}
+	}
+	if r.flag() { // chroma_loc_info_present_flag
+		r.ue()
+		r.ue()
+	}
+	if !r.flag() { // timing_info_present_flag
+		return 0
+	}
+	numUnitsInTick := r.u(32)
+	timeScale := r.u(32)
+	if r.overflow || numUnitsInTick == 0 || timeScale == 0 {
+		return 0
+	}
+	// a frame lasts two ticks
+	return roundFrameRate(float64(timeScale) / float64(2*numUnitsInTick))
+}
+
+// parseH265SPS parses the resolution of an H.265 SPS NAL unit.
+func parseH265SPS(nal []byte) (*spsInfo, bool) {
+	if len(nal) < 2 {
+		return nil, false
+	}
+	r := &bitReader{data: unescapeRBSP(nal[2:])}
+	r.skip(4) // sps_video_parameter_set_id
+	maxSubLayersMinus1 := int(r.u(3))
+	r.skip(1) // sps_temporal_id_nesting_flag
+
+	// profile_tier_level: the general profile and level,
+	// then the sub-layer flags padded to 8 sub-layers and the sub-layer profiles and levels
+	r.skip(88 + 8)
+	subLayerProfilePresent := make([]bool, maxSubLayersMinus1)
+	subLayerLevelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		subLayerProfilePresent[i] = r.flag()
+		subLayerLevelPresent[i] = r.flag()
+	}
+	if maxSubLayersMinus1 > 0 {
+		r.skip(2 * (8 - maxSubLayersMinus1))
+	}
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if subLayerProfilePresent[i] {
+			r.skip(88)
+		}
+		if subLayerLevelPresent[i] {
+			r.skip(8)
+		}
+	}
+
+	r.ue() // sps_seq_parameter_set_id
+	chromaFormatIdc := r.ue()
+	if chromaFormatIdc == 3 && r.flag() { // separate_colour_plane_flag
+		chromaFormatIdc = 0
+	}
+	width := int(r.ue())
+	height := int(r.ue())
+	if r.flag() { // conformance_window_flag
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		subWidth, subHeight := chromaSubsampling(chromaFormatIdc)
+		width -= subWidth * int(left+right)
+		height -= subHeight * int(top+bottom)
+	}
+	if r.overflow || width <= 0 || height <= 0 {
+		return nil, false
+	}
+	return &spsInfo{width: width, height: height}, true
+}
+
+// roundFrameRate rounds a frame rate to 3 decimals, such as 29.97.
+func roundFrameRate(fps float64) float64 {
+	return math.Round(fps*1000) / 1000
+}
@@ -0,0 +1,136 @@
+package mediax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter writes the bits of an RBSP.
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) u(n int, v uint64) *bitWriter {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&0x01) << (7 - w.n%8)
+		w.n++
+	}
+	return w
+}
+
+func (w *bitWriter) ue(v uint64) *bitWriter {
+	v++
+	bits := 0
+	for x := v; x > 0; x >>= 1 {
+		bits++
+	}
+	return w.u(bits-1, 0).u(bits, v)
+}
+
+// nal returns the NAL unit of the header and the RBSP with the trailing bits and the emulation prevention bytes.
+func (w *bitWriter) nal(header ...byte) []byte {
+	w.u(1, 1)
+	nal := header
+	zeros := 0
+	for _, b := range w.data {
+		if zeros >= 2 && b <= 0x03 {
+			nal = append(nal, 0x03)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		nal = append(nal, b)
+	}
+	return nal
+}
+
+// h264SPS builds a high profile H.264 SPS of 1920x1080 at 25fps, progressive or interlaced.
+func h264SPS(interlaced bool) []byte {
+	w := &bitWriter{}
+	w.u(8, 100).u(8, 0).u(8, 40).ue(0) // profile_idc, constraint flags, level_idc, seq_parameter_set_id
+	w.ue(1).ue(0).ue(0).u(1, 0)        // chroma_format_idc, bit depths, qpprime_y_zero_transform_bypass_flag
+	w.u(1, 0)                          // seq_scaling_matrix_present_flag
+	w.ue(0).ue(0).ue(2)                // log2_max_frame_num_minus4, pic_order_cnt_type, log2_max_pic_order_cnt_lsb_minus4
+	w.ue(4).u(1, 0)                    // max_num_ref_frames, gaps_in_frame_num_value_allowed_flag
+	w.ue(119)                          // pic_width_in_mbs_minus1
+	// pic_height_in_map_units_minus1, frame_mbs_only_flag, mb_adaptive_frame_field_flag,
+	// direct_8x8_inference_flag, frame_cropping_flag and the offsets, 1088 lines cropped to 1080
+	if interlaced {
+		w.ue(33).u(1, 0).u(1, 1)
+		w.u(1, 1).u(1, 1).ue(0).ue(0).ue(0).ue(2)
+	} else {
+		w.ue(67).u(1, 1)
+		w.u(1, 1).u(1, 1).ue(0).ue(0).ue(0).ue(4)
+	}
+	w.u(1, 1)                               // vui_parameters_present_flag
+	w.u(1, 1).u(8, 1)                       // aspect_ratio_idc
+	w.u(1, 0)                               // overscan_info_present_flag
+	w.u(1, 1).u(4, 0b1010).u(1, 1).u(24, 0) // video_signal_type_present_flag
+	w.u(1, 0)                               // chroma_loc_info_present_flag
+	w.u(1, 1).u(32, 1).u(32, 50).u(1, 1)    // timing_info_present_flag
+	return w.nal(0x67)
+}
+
+// h265SPS builds an H.265 SPS of the coded size and the bottom conformance window offset,
+// with a sub-layer carrying its profile and level.
+func h265SPS(width, height, cropBottom uint64) []byte {
+	w := &bitWriter{}
+	w.u(4, 0).u(3, 1).u(1, 1)                       // sps_video_parameter_set_id, sps_max_sub_layers_minus1, sps_temporal_id_nesting_flag
+	w.u(32, 0x01600000).u(32, 0).u(24, 0).u(8, 153) // general profile and level
+	w.u(1, 1).u(1, 1).u(14, 0)                      // sub_layer_profile_present_flag, sub_layer_level_present_flag, padding
+	w.u(32, 0x01600000).u(32, 0).u(24, 0).u(8, 120)
+	w.ue(0).ue(1).ue(width).ue(height) // sps_seq_parameter_set_id, chroma_format_idc, size
+	if cropBottom > 0 {
+		w.u(1, 1).ue(0).ue(0).ue(0).ue(cropBottom)
+	} else {
+		w.u(1, 0)
+	}
+	w.ue(0).ue(0) // bit depths
+	return w.nal(0x42, 0x01)
+}
+
+func TestParseH264SPS(t *testing.T) {
+	sps, ok := parseH264SPS(h264SPS(false))
+	require.True(t, ok)
+	require.Equal(t, &spsInfo{width: 1920, height: 1080, frameRate: 25}, sps)
+
+	sps, ok = parseH264SPS(h264SPS(true))
+	require.True(t, ok)
+	require.Equal(t, &spsInfo{width: 1920, height: 1080, frameRate: 25}, sps)
+
+	_, ok = parseH264SPS(h264SPS(false)[:6])
+	require.False(t, ok)
+}
+
+func TestParseH265SPS(t *testing.T) {
+	sps, ok := parseH265SPS(h265SPS(3840, 2160, 0))
+	require.True(t, ok)
+	require.Equal(t, &spsInfo{width: 3840, height: 2160}, sps)
+
+	sps, ok = parseH265SPS(h265SPS(1920, 1088, 4))
+	require.True(t, ok)
+	require.Equal(t, &spsInfo{width: 1920, height: 1080}, sps)
+}
+
+func TestFindSPS(t *testing.T) {
+	var stream []byte
+	stream = append(stream, 0, 0, 0, 1, 0x09, 0xf0) // access unit delimiter
+	stream = append(stream, 0, 0, 0, 1)             // 4 bytes start code
+	stream = append(stream, h264SPS(false)...)
+	stream = append(stream, 0, 0, 1, 0x68, 0xeb, 0xe3, 0xcb)    // PPS
+	stream = append(stream, 0, 0, 1, 0x65, 0x88, 0x84, 0x00, 0) // IDR slice
+	require.Equal(t, &spsInfo{width: 1920, height: 1080, frameRate: 25}, findSPS(CodecH264, stream))
+	require.Nil(t, findSPS(CodecH265, stream))
+
+	stream = append([]byte{0, 0, 1, 0x40, 0x01, 0x0c}, 0, 0, 1) // VPS
+	stream = append(stream, h265SPS(3840, 2160, 0)...)
+	require.Equal(t, &spsInfo{width: 3840, height: 2160}, findSPS(CodecH265, stream))
+}
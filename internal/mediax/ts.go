@@ -0,0 +1,340 @@
+package mediax
+
+import (
+	"bytes"
+	"slices"
+)
+
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+
+	patPid     = 0x0000
+	tableIdPAT = 0x00
+	tableIdPMT = 0x02
+
+	// pcrHz is the frequency of the PCR, ptsHz is the frequency of the PTS.
+	pcrHz = 27_000_000
+	ptsHz = 90_000
+)
+
+// stream types of the elementary streams listed in the PMT.
+const (
+	streamTypeMPEG1Video = 0x01
+	streamTypeMPEG2Video = 0x02
+	streamTypeMPEG1Audio = 0x03
+	streamTypeMPEG2Audio = 0x04
+	streamTypeAAC        = 0x0f
+	streamTypeAACLATM    = 0x11
+	streamTypeH264       = 0x1b
+	streamTypeH265       = 0x24
+	streamTypeAC3        = 0x81
+)
+
+// codecOf returns the codec of a stream type and whether it is a video codec, or "" if it is unknown.
+func codecOf(streamType byte) (codec string, video bool) {
+	switch streamType {
+	case streamTypeMPEG1Video, streamTypeMPEG2Video:
+		return CodecMPEG2Video, true
+	case streamTypeH264:
+		return CodecH264, true
+	case streamTypeH265:
+		return CodecH265, true
+	case streamTypeMPEG1Audio, streamTypeMPEG2Audio:
+		return CodecMP2, false
+	case streamTypeAAC, streamTypeAACLATM:
+		return CodecAAC, false
+	case streamTypeAC3:
+		return CodecAC3, false
+	}
+	return "", false
+}
+
+// tsPacket is a parsed MPEG-TS packet.
+type tsPacket struct {
+	pid       uint16
+	unitStart bool   // unitStart is the payload_unit_start_indicator
+	payload   []byte // payload is nil if the packet carries only an adaptation field
+	pcr       int64  // pcr is the PCR in the adaptation field in 27MHz, -1 if absent
+}
+
+// parseTSPacket parses a TS packet of tsPacketSize bytes starting with the sync byte.
+func parseTSPacket(b []byte) tsPacket {
+	pkt := tsPacket{
+		pid:       uint16(b[1]&0x1f)<<8 | uint16(b[2]),
+		unitStart: b[1]&0x40 != 0,
+		pcr:       -1,
+	}
+	adaptationFieldControl := (b[3] >> 4) & 0x03
+	offset := 4
+	if adaptationFieldControl&0x02 != 0 {
+		adaptationFieldLength := int(b[4])
+		// PCR_flag and the 6 bytes PCR
+		if adaptationFieldLength >= 7 && b[5]&0x10 != 0 {
+			base := int64(b[6])<<25 | int64(b[7])<<17 | int64(b[8])<<9 | int64(b[9])<<1 | int64(b[10])>>7
+			ext := int64(b[10]&0x01)<<8 | int64(b[11])
+			pkt.pcr = base*300 + ext
+		}
+		offset += 1 + adaptationFieldLength
+	}
+	if adaptationFieldControl&0x01 != 0 && offset < len(b) {
+		pkt.payload = b[offset:]
+	}
+	return pkt
+}
+
+// syncTS returns the offset of the first of 3 consecutive TS packets in the data.
+func syncTS(b []byte) (int, bool) {
+	for offset := 0; offset < tsPacketSize && offset+2*tsPacketSize < len(b); offset++ {
+		if b[offset] == tsSyncByte && b[offset+tsPacketSize] == tsSyncByte && b[offset+2*tsPacketSize] == tsSyncByte {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// psiSection returns the PSI section starting in the payload of a packet with the payload_unit_start_indicator,
+// which begins with the pointer field. The section is truncated to its section_length if it fits.
+func psiSection(payload []byte) []byte {
+	if len(payload) == 0 || int(payload[0])+1 >= len(payload) {
+		return nil
+	}
+	section := payload[1+int(payload[0]):]
+	if len(section) < 3 {
+		return nil
+	}
+	sectionLength := int(section[1]&0x0f)<<8 | int(section[2])
+	if 3+sectionLength <= len(section) {
+		section = section[:3+sectionLength]
+	}
+	return section
+}
+
+// parsePAT returns the PMT PIDs of the programs of a PAT section.
+func parsePAT(section []byte) []uint16 {
+	// the programs follow the 8 bytes header and precede the 4 bytes CRC
+	if len(section) < 12 {
+		return nil
+	}
+	var pids []uint16
+	for i := 8; i+4 <= len(section)-4; i += 4 {
+		programNumber := uint16(section[i])<<8 | uint16(section[i+1])
+		if programNumber == 0 {
+			continue // network PID
+		}
+		pids = append(pids, uint16(section[i+2]&0x1f)<<8|uint16(section[i+3]))
+	}
+	return pids
+}
+
+// tsStream is an elementary stream listed in the PMT.
+type tsStream struct {
+	pid        uint16
+	streamType byte
+}
+
+// parsePMT returns the PCR PID and the elementary streams of a PMT section.
+func parsePMT(section []byte) (pcrPid uint16, streams []tsStream) {
+	// the 12 bytes header ends with the PCR PID and the program_info_length, the streams precede the 4 bytes CRC
+	if len(section) < 16 {
+		return 0, nil
+	}
+	pcrPid = uint16(section[8]&0x1f)<<8 | uint16(section[9])
+	i := 12 + (int(section[10]&0x0f)<<8 | int(section[11]))
+	for i+5 <= len(section)-4 {
+		streams = append(streams, tsStream{
+			pid:        uint16(section[i+1]&0x1f)<<8 | uint16(section[i+2]),
+			streamType: section[i],
+		})
+		i += 5 + (int(section[i+3]&0x0f)<<8 | int(section[i+4]))
+	}
+	return pcrPid, streams
+}
+
+// parsePES parses the header of a PES packet starting in the payload,
+// and returns its PTS in 90kHz, -1 if absent, and the elementary stream data following the header.
+func parsePES(payload []byte) (pts int64, data []byte, ok bool) {
+	if len(payload) < 9 || payload[0] != 0 || payload[1] != 0 || payload[2] != 1 {
+		return -1, nil, false
+	}
+	start := 9 + int(payload[8])
+	if start > len(payload) {
+		return -1, nil, false
+	}
+	pts = -1
+	if payload[7]&0x80 != 0 && len(payload) >= 14 {
+		p := payload[9:14]
+		pts = int64(p[0]>>1&0x07)<<30 | int64(p[1])<<22 | int64(p[2]>>1)<<15 | int64(p[3])<<7 | int64(p[4]>>1)
+	}
+	return pts, payload[start:], true
+}
+
+const (
+	// maxPESSize is the max number of bytes of a video PES buffered looking for the SPS.
+	maxPESSize = 1 << 20
+	// maxPTSCount is the max number of video PTS collected to estimate the frame rate.
+	maxPTSCount = 250
+	// minPCRSpan is the min span of the PCR to measure the bitrate.
+	minPCRSpan = pcrHz / 10
+)
+
+// pcrSample is a PCR and the offset of its packet in the stream.
+type pcrSample struct {
+	pcr    int64
+	offset int64
+}
+
+// tsDemuxer demuxes the packets of a TS stream fed in chunks. It finds the elementary streams listed in the PMT,
+// samples the PCR, and collects the PTS and the first SPS of the first video stream.
+type tsDemuxer struct {
+	buf    []byte
+	offset int64 // offset is the offset of the buffer in the stream
+
+	pmtPids  map[uint16]bool // pmtPids are the PMT PIDs listed in the PAT
+	pmtFound bool
+	pcrPid   uint16
+	streams  []tsStream
+
+	videoPid   uint16
+	videoCodec string
+	pes        []byte // pes is the data of the current PES of the video stream until the SPS is found
+	pts        []int64
+	sps        *spsInfo
+
+	firstPCR, lastPCR *pcrSample
+}
+
+func newTSDemuxer() *tsDemuxer {
+	return &tsDemuxer{pmtPids: make(map[uint16]bool)}
+}
+
+// feed demuxes the complete TS packets in the fed bytes, keeping the rest for the next call.
+func (d *tsDemuxer) feed(p []byte) {
+	d.buf = append(d.buf, p...)
+	b := d.buf
+	for len(b) >= tsPacketSize {
+		if b[0] != tsSyncByte {
+			// lost sync, skip to the next sync byte
+			i := bytes.IndexByte(b[1:], tsSyncByte)
+			if i < 0 {
+				i = len(b) - 1
+			}
+			b = b[1+i:]
+			d.offset += int64(1 + i)
+			continue
+		}
+		d.demux(parseTSPacket(b[:tsPacketSize]))
+		b = b[tsPacketSize:]
+		d.offset += tsPacketSize
+	}
+	d.buf = append(d.buf[:0], b...)
+}
+
+func (d *tsDemuxer) demux(pkt tsPacket) {
+	if pkt.pcr >= 0 && d.pmtFound && pkt.pid == d.pcrPid {
+		sample := &pcrSample{pcr: pkt.pcr, offset: d.offset}
+		if d.firstPCR == nil || pkt.pcr < d.lastPCR.pcr {
+			// the first PCR, or a discontinuity
+			d.firstPCR = sample
+		}
+		d.lastPCR = sample
+	}
+
+	if pkt.pid == d.videoPid && d.videoCodec != "" {
+		d.demuxVideo(pkt)
+		return
+	}
+	// only the first PMT is used
+	if !pkt.unitStart || d.pmtFound {
+		return
+	}
+	section := psiSection(pkt.payload)
+	switch {
+	case pkt.pid == patPid:
+		if len(section) > 0 && section[0] == tableIdPAT {
+			for _, pid := range parsePAT(section) {
+				d.pmtPids[pid] = true
+			}
+		}
+	case d.pmtPids[pkt.pid]:
+		if len(section) > 0 && section[0] == tableIdPMT {
+			d.pmtFound = true
+			d.pcrPid, d.streams = parsePMT(section)
+			for _, s := range d.streams {
+				if codec, video := codecOf(s.streamType); video {
+					d.videoPid, d.videoCodec = s.pid, codec
+					break
+				}
+			}
+		}
+	}
+}
+
+func (d *tsDemuxer) demuxVideo(pkt tsPacket) {
+	if !pkt.unitStart {
+		if d.sps == nil && d.pes != nil && len(d.pes) < maxPESSize {
+			d.pes = append(d.pes, pkt.payload...)
+		}
+		return
+	}
+	// the previous PES is complete
+	if d.sps == nil && d.pes != nil {
+		d.sps = findSPS(d.videoCodec, d.pes)
+	}
+	pts, data, ok := parsePES(pkt.payload)
+	if !ok {
+		d.pes = nil
+		return
+	}
+	if pts >= 0 && len(d.pts) < maxPTSCount {
+		d.pts = append(d.pts, pts)
+	}
+	if d.sps == nil {
+		d.pes = append(d.pes[:0], data...)
+	} else {
+		d.pes = nil
+	}
+}
+
+// info returns the StreamInfo of what has been demuxed.
+func (d *tsDemuxer) info() *StreamInfo {
+	info := &StreamInfo{Format: FormatTS, VideoCodec: d.videoCodec}
+	for _, s := range d.streams {
+		if codec, video := codecOf(s.streamType); codec != "" && !video && !slices.Contains(info.AudioCodecs, codec) {
+			info.AudioCodecs = append(info.AudioCodecs, codec)
+		}
+	}
+
+	sps := d.sps
+	if sps == nil && d.pes != nil {
+		// the SPS leads the PES of a key frame, so it is likely complete in the current PES
+		sps = findSPS(d.videoCodec, d.pes)
+	}
+	if sps != nil {
+		info.Width, info.Height, info.FrameRate = sps.width, sps.height, sps.frameRate
+	}
+	if info.FrameRate == 0 {
+		info.FrameRate = frameRateOfPTS(d.pts)
+	}
+
+	if d.firstPCR != nil && d.lastPCR.pcr-d.firstPCR.pcr >= minPCRSpan {
+		n := d.lastPCR.offset - d.firstPCR.offset
+		info.Bitrate = n * 8 * pcrHz / (d.lastPCR.pcr - d.firstPCR.pcr)
+	}
+	return info
+}
+
+// frameRateOfPTS estimates the frame rate from the PTS of the frames, which may be out of order.
+func frameRateOfPTS(pts []int64) float64 {
+	if len(pts) < 2 {
+		return 0
+	}
+	pts = slices.Clone(pts)
+	slices.Sort(pts)
+	pts = slices.Compact(pts)
+	span := pts[len(pts)-1] - pts[0]
+	if len(pts) < 2 || span <= 0 {
+		return 0
+	}
+	return roundFrameRate(float64(len(pts)-1) * ptsHz / float64(span))
+}
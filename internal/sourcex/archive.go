@@ -0,0 +1,176 @@
+package sourcex
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	// MaxExpandedSize limits the size of each member expanded from an archive,
+	// which protects against decompression bombs.
+	MaxExpandedSize int64 = 256 << 20
+	// MaxExpandedTotalSize limits the total size of all the members expanded from an archive,
+	// which protects against archives of many members each within MaxExpandedSize.
+	MaxExpandedTotalSize int64 = 512 << 20
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	tarMagic  = []byte("ustar")
+)
+
+// Member is a playlist file expanded from an archive.
+type Member struct {
+	Name    string // Name is the name of the archive followed by "!" and the path of the member in it
+	Content []byte // Content of the member
+}
+
+// IsArchive reports whether the content is a gzip, zip or tar archive, detected by magic numbers,
+// so that archives are recognized even if served without Content-Encoding or with a wrong extension.
+func IsArchive(content []byte) bool {
+	return bytes.HasPrefix(content, gzipMagic) || bytes.HasPrefix(content, zipMagic) || isTar(content)
+}
+
+func isTar(content []byte) bool {
+	return len(content) > 262 && bytes.Equal(content[257:262], tarMagic)
+}
+
+// ExpandArchive expands a .gz, .zip, .tar or .tar.gz archive into the playlist members in it.
+// A gzip file of a single playlist expands into one member named by the archive itself.
+// Members which are neither a playlist by extension nor by content are skipped.
+// Content that is not an archive is returned as the only member.
+func ExpandArchive(name string, content []byte) ([]*Member, error) {
+	switch {
+	case bytes.HasPrefix(content, gzipMagic):
+		gr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		defer gr.Close()
+		decompressed, err := readLimited(gr, MaxExpandedTotalSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip archive: %w", err)
+		}
+		if isTar(decompressed) {
+			return expandTar(name, decompressed)
+		}
+		if bytes.HasPrefix(decompressed, gzipMagic) || bytes.HasPrefix(decompressed, zipMagic) {
+			return nil, errors.New("nested archive is not supported")
+		}
+		if int64(len(decompressed)) > MaxExpandedSize {
+			return nil, fmt.Errorf("invalid gzip archive: expanded size exceeds %d bytes", MaxExpandedSize)
+		}
+		return []*Member{{Name: name, Content: decompressed}}, nil
+	case bytes.HasPrefix(content, zipMagic):
+		return expandZip(name, content)
+	case isTar(content):
+		return expandTar(name, content)
+	default:
+		return []*Member{{Name: name, Content: content}}, nil
+	}
+}
+
+func expandZip(name string, content []byte) ([]*Member, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	var members []*Member
+	budget := &expansionBudget{remaining: MaxExpandedTotalSize}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s of zip archive: %w", f.Name, err)
+		}
+		memberContent, err := budget.read(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of zip archive: %w", f.Name, err)
+		}
+		if member := newPlaylistMember(name, f.Name, memberContent); member != nil {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func expandTar(name string, content []byte) ([]*Member, error) {
+	tr := tar.NewReader(bytes.NewReader(content))
+	var members []*Member
+	budget := &expansionBudget{remaining: MaxExpandedTotalSize}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		memberContent, err := budget.read(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s of tar archive: %w", header.Name, err)
+		}
+		if member := newPlaylistMember(name, header.Name, memberContent); member != nil {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+// newPlaylistMember returns the member if it is a playlist, otherwise nil.
+func newPlaylistMember(archiveName, memberName string, content []byte) *Member {
+	base := path.Base(memberName)
+	if strings.HasPrefix(base, ".") || strings.HasPrefix(memberName, "__MACOSX/") {
+		return nil
+	}
+	if formatOfExt(memberName) == FormatUnknown && DetectFormat(memberName, content) == FormatUnknown {
+		return nil
+	}
+	return &Member{Name: archiveName + "!" + memberName, Content: content}
+}
+
+// expansionBudget is the size left for the members expanded from an archive.
+type expansionBudget struct {
+	remaining int64
+}
+
+// read reads a member within MaxExpandedSize and the size left, which is reduced by the size of the member.
+func (b *expansionBudget) read(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, min(MaxExpandedSize, b.remaining)+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > MaxExpandedSize {
+		return nil, fmt.Errorf("expanded size exceeds %d bytes", MaxExpandedSize)
+	}
+	if int64(len(content)) > b.remaining {
+		return nil, fmt.Errorf("total expanded size exceeds %d bytes", MaxExpandedTotalSize)
+	}
+	b.remaining -= int64(len(content))
+	return content, nil
+}
+
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("expanded size exceeds %d bytes", limit)
+	}
+	return content, nil
+}
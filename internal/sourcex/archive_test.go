@@ -0,0 +1,121 @@
+package sourcex
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testM3uContent = []byte("#EXTM3U\n#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/cctv1.m3u8\n")
+	testTxtContent = []byte("央视,#genre#\nCCTV1,http://host/cctv1.m3u8\n")
+)
+
+func gzipBytes(t *testing.T, content []byte) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	_, err := gw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestExpandArchive(t *testing.T) {
+	t.Run("not an archive", func(t *testing.T) {
+		members, err := ExpandArchive("live.m3u", testM3uContent)
+		require.NoError(t, err)
+		require.Equal(t, []*Member{{Name: "live.m3u", Content: testM3uContent}}, members)
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		content := gzipBytes(t, testM3uContent)
+		require.True(t, IsArchive(content))
+		members, err := ExpandArchive("http://host/live", content)
+		require.NoError(t, err)
+		require.Equal(t, []*Member{{Name: "http://host/live", Content: testM3uContent}}, members)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		for name, content := range map[string][]byte{
+			"daily/live.m3u":        testM3uContent,
+			"daily/live.txt":        testTxtContent,
+			"daily/README":          []byte("just some text"),
+			"__MACOSX/daily/._live": testTxtContent,
+		} {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write(content)
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+
+		members, err := ExpandArchive("snapshot.zip", buf.Bytes())
+		require.NoError(t, err)
+		names := make(map[string][]byte)
+		for _, member := range members {
+			names[member.Name] = member.Content
+		}
+		require.Equal(t, map[string][]byte{
+			"snapshot.zip!daily/live.m3u": testM3uContent,
+			"snapshot.zip!daily/live.txt": testTxtContent,
+		}, names)
+	})
+
+	tarBytes := func(t *testing.T) []byte {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "daily/", Typeflag: tar.TypeDir, Mode: 0755}))
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name: "daily/live.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(testTxtContent)),
+		}))
+		_, err := tw.Write(testTxtContent)
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		return buf.Bytes()
+	}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		members, err := ExpandArchive("snapshot.tar.gz", gzipBytes(t, tarBytes(t)))
+		require.NoError(t, err)
+		require.Equal(t, []*Member{{Name: "snapshot.tar.gz!daily/live.txt", Content: testTxtContent}}, members)
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		content := tarBytes(t)
+		require.True(t, IsArchive(content))
+		members, err := ExpandArchive("snapshot.tar", content)
+		require.NoError(t, err)
+		require.Equal(t, []*Member{{Name: "snapshot.tar!daily/live.txt", Content: testTxtContent}}, members)
+	})
+
+	t.Run("exceeds max expanded size", func(t *testing.T) {
+		maxExpandedSize := MaxExpandedSize
+		MaxExpandedSize = 16
+		defer func() { MaxExpandedSize = maxExpandedSize }()
+		_, err := ExpandArchive("live.m3u.gz", gzipBytes(t, testM3uContent))
+		require.Error(t, err)
+	})
+
+	t.Run("exceeds max expanded total size", func(t *testing.T) {
+		maxExpandedTotalSize := MaxExpandedTotalSize
+		MaxExpandedTotalSize = int64(len(testTxtContent)) * 3
+		defer func() { MaxExpandedTotalSize = maxExpandedTotalSize }()
+		buf := &bytes.Buffer{}
+		zw := zip.NewWriter(buf)
+		for _, name := range []string{"1.txt", "2.txt", "3.txt", "4.txt"} {
+			w, err := zw.Create(name)
+			require.NoError(t, err)
+			_, err = w.Write(testTxtContent)
+			require.NoError(t, err)
+		}
+		require.NoError(t, zw.Close())
+		_, err := ExpandArchive("snapshot.zip", buf.Bytes())
+		require.ErrorContains(t, err, "total expanded size")
+	})
+}
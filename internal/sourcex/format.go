@@ -0,0 +1,89 @@
+package sourcex
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// Format is the format of a program list source.
+type Format string
+
+const (
+	FormatUnknown Format = ""
+	FormatM3u     Format = "m3u"
+	FormatTxt     Format = "txt"
+	FormatJson    Format = "json"
+	FormatXml     Format = "xml" // FormatXml is detected to reject EPGs configured as sources, no parser is registered by default
+)
+
+// sniffSize is the size of the content head inspected when detecting the format by markers.
+const sniffSize = 64 * 1024
+
+var utf8Bom = []byte{0xEF, 0xBB, 0xBF}
+
+// TrimContent removes the UTF-8 BOM and the leading blank lines of the content.
+func TrimContent(content []byte) []byte {
+	content = bytes.TrimPrefix(content, utf8Bom)
+	return bytes.TrimLeft(content, " \t\r\n")
+}
+
+// DetectFormat detects the format of the content of a source by its BOM, header tokens,
+// "#genre#" markers, JSON structure and XML declaration.
+// The extension of the name (a file path or url) is only used if the content is inconclusive.
+func DetectFormat(name string, content []byte) Format {
+	content = TrimContent(content)
+	head := content
+	if len(head) > sniffSize {
+		head = head[:sniffSize]
+	}
+	upperHead := bytes.ToUpper(head)
+
+	switch {
+	case bytes.HasPrefix(upperHead, []byte("#EXTM3U")):
+		return FormatM3u
+	case bytes.HasPrefix(head, []byte("{")), bytes.HasPrefix(head, []byte("[")):
+		// The head of a content longer than the sniff size can not be validated
+		if len(content) > sniffSize || json.Valid(content) {
+			return FormatJson
+		}
+	case bytes.HasPrefix(head, []byte("<")):
+		return FormatXml
+	}
+
+	switch {
+	case bytes.Contains(head, []byte(",#genre#")):
+		return FormatTxt
+	case bytes.Contains(upperHead, []byte("#EXTINF:")):
+		return FormatM3u
+	}
+
+	if format := formatOfExt(name); format != FormatUnknown {
+		return format
+	}
+	// A list of "name,url" lines without any group marker
+	if bytes.Contains(head, []byte(",")) && bytes.Contains(head, []byte("://")) {
+		return FormatTxt
+	}
+	return FormatUnknown
+}
+
+// formatOfExt returns the format of the extension of a file path or url.
+func formatOfExt(name string) Format {
+	if idx := strings.IndexAny(name, "?#"); idx != -1 {
+		name = name[:idx]
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".m3u", ".m3u8":
+		return FormatM3u
+	case ".txt":
+		return FormatTxt
+	case ".json":
+		return FormatJson
+	case ".xml":
+		return FormatXml
+	default:
+		return FormatUnknown
+	}
+}
@@ -0,0 +1,82 @@
+package sourcex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		content  string
+		expected Format
+	}{
+		{
+			name:     "m3u",
+			source:   "http://host/live",
+			content:  "#EXTM3U x-tvg-url=\"http://host/epg.xml\"\n#EXTINF:-1,CCTV1\nhttp://host/cctv1.m3u8",
+			expected: FormatM3u,
+		},
+		{
+			name:     "m3u with bom and leading blank lines",
+			source:   "http://host/live.txt",
+			content:  "\xEF\xBB\xBF\n\n  #extm3u\n#EXTINF:-1,CCTV1\nhttp://host/cctv1.m3u8",
+			expected: FormatM3u,
+		},
+		{
+			name:     "m3u without header",
+			source:   "live",
+			content:  "#EXTINF:-1 tvg-name=\"CCTV1\",CCTV1\nhttp://host/cctv1.m3u8",
+			expected: FormatM3u,
+		},
+		{
+			name:     "txt starting with comment",
+			source:   "http://host/live.m3u",
+			content:  "# updated daily\n央视,#genre#\nCCTV1,http://host/cctv1.m3u8",
+			expected: FormatTxt,
+		},
+		{
+			name:     "txt without group",
+			source:   "http://host/live",
+			content:  "CCTV1,http://host/cctv1.m3u8\nCCTV2,http://host/cctv2.m3u8",
+			expected: FormatTxt,
+		},
+		{
+			name:     "json",
+			source:   "http://host/tvbox",
+			content:  "\xEF\xBB\xBF{\"lives\":[{\"name\":\"live\",\"url\":\"http://host/live.txt\"}]}",
+			expected: FormatJson,
+		},
+		{
+			name:     "xml",
+			source:   "http://host/epg",
+			content:  "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<tv></tv>",
+			expected: FormatXml,
+		},
+		{
+			name:     "inconclusive content with extension",
+			source:   "/path/to/live.m3u8?token=abc",
+			content:  "",
+			expected: FormatM3u,
+		},
+		{
+			name:     "unknown",
+			source:   "http://host/live",
+			content:  "just some text",
+			expected: FormatUnknown,
+		},
+		{
+			name:     "invalid json",
+			source:   "http://host/live",
+			content:  "{not json",
+			expected: FormatUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, DetectFormat(tt.source, []byte(tt.content)))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package sourcex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/txtx"
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+// Parser parses the content of a source in a specific format read from a reader into a ProgramListSource.
+// The name is the file path or url of the source, which is used for logging and resolving
+// relative references. Line-based parsers should keep only the channels accepted by the options
+// as they read, so that large sources are never held in memory entirely.
+type Parser interface {
+	Parse(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error)
+}
+
+// ParserFunc is an adapter to allow the use of ordinary functions as Parser.
+type ParserFunc func(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error)
+
+// Parse calls f(ctx, name, r, opts).
+func (f ParserFunc) Parse(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+	return f(ctx, name, r, opts)
+}
+
+var (
+	parsers   = make(map[Format]Parser)
+	parsersMu sync.RWMutex
+)
+
+func init() {
+	Register(FormatM3u, ParserFunc(parseM3u))
+	Register(FormatTxt, ParserFunc(parseTxt))
+}
+
+// Register registers the parser of the format, replacing the one registered before.
+// The parsers are shared by local and remote sources.
+func Register(format Format, parser Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[format] = parser
+}
+
+// Parse detects the format of the content and parses it with the parser registered for the format.
+func Parse(ctx context.Context, name string, content []byte) (*m3u8x.ProgramListSource, Format, error) {
+	return ParseReader(ctx, name, bytes.NewReader(content), nil)
+}
+
+// ParseReader detects the format of the content read from the reader by its head,
+// and streams the rest of it to the parser registered for the format.
+func ParseReader(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, Format, error) {
+	return parseReader(ctx, name, r, nil, opts)
+}
+
+// ParseReadCloser parses the content read from the reader like ParseReader, and closes the reader.
+// JSON configs are read entirely and the reader is closed before parsing them, since the lists they reference
+// may be loaded from the same host, whose requests would wait for the reader if their concurrency is limited.
+func ParseReadCloser(ctx context.Context, name string, rc io.ReadCloser, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, Format, error) {
+	defer rc.Close()
+	return parseReader(ctx, name, rc, rc, opts)
+}
+
+// parseReader parses the content read from the reader, and closes the closer before parsing JSON configs if not nil.
+func parseReader(ctx context.Context, name string, r io.Reader, closer io.Closer, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, Format, error) {
+	br, format, err := detectReaderFormat(name, r)
+	if err != nil {
+		return nil, FormatUnknown, err
+	}
+	parsersMu.RLock()
+	parser, ok := parsers[format]
+	parsersMu.RUnlock()
+	if !ok {
+		switch format {
+		case FormatUnknown:
+			return nil, format, fmt.Errorf("unknown format of source")
+		case FormatXml:
+			// XML sources are mostly EPGs, which should be configured as the x-tvg-urls instead
+			return nil, format, fmt.Errorf("EPG/XML is not a playlist source")
+		}
+		return nil, format, fmt.Errorf("no parser registered for format %s", format)
+	}
+	var content io.Reader = br
+	if format == FormatJson && closer != nil {
+		data, err := io.ReadAll(br)
+		_ = closer.Close()
+		if err != nil {
+			return nil, format, err
+		}
+		content = bytes.NewReader(data)
+	}
+	source, err := parser.Parse(ctx, name, content, opts)
+	return source, format, err
+}
+
+// detectReaderFormat detects the format of the content read from the reader by its head,
+// and returns the reader of the content from which the BOM and leading blank lines are skipped.
+func detectReaderFormat(name string, r io.Reader) (*bufio.Reader, Format, error) {
+	br := bufio.NewReaderSize(r, sniffSize+len(utf8Bom)+1)
+	head, err := br.Peek(sniffSize + len(utf8Bom) + 1)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, FormatUnknown, err
+	}
+	// skip the BOM and leading blank lines of the content
+	trimmed := TrimContent(head)
+	_, _ = br.Discard(len(head) - len(trimmed))
+	return br, DetectFormat(name, trimmed), nil
+}
+
+func parseM3u(_ context.Context, _ string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+	source := m3u8x.NewProgramListSource()
+	if err := source.ParseProgramListSourceFromReader(r, opts); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func parseTxt(_ context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+	tncs := txtx.NewTvgNameChannels()
+	report, err := tncs.ParseTxtFromReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	logTxtParseReport(name, report)
+	return txtx.ToM3u(tncs), nil
+}
+
+// logTxtParseReport logs the diagnostics summary of parsing a txt source,
+// and each line that could not be parsed at debug level.
+func logTxtParseReport(source string, report *txtx.TxtParseReport) {
+	log.Info().Msg("Parsed txt source.").
+		Str("source", source).
+		Int("lines", report.Lines).
+		Int("groups", report.Groups).
+		Int("channels", report.Channels).
+		Int("multi_url_lines", report.MultiUrlLines).
+		Int("filtered", report.Filtered).
+		Done()
+	if report.TooLongLines > 0 {
+		log.Warn().Msg("Some lines of txt source are too long, skipped.").
+			Str("source", source).
+			Int("too_long_lines", report.TooLongLines).
+			Done()
+	}
+	if len(report.Issues) == 0 {
+		return
+	}
+	log.Warn().Msg("Some lines of txt source could not be parsed, ignore.").
+		Str("source", source).
+		Int("issues", len(report.Issues)).
+		Any("reasons", report.IssueReasons()).
+		Done()
+	for _, issue := range report.Issues {
+		log.Debug().Msg("Invalid line.").
+			Str("source", source).
+			Int("line_no", issue.LineNo).
+			Str("line", issue.Line).
+			Str("reason", issue.Reason).
+			Done()
+	}
+}
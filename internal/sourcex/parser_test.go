@@ -0,0 +1,76 @@
+package sourcex
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	ctx := context.Background()
+
+	source, format, err := Parse(ctx, "live.txt", []byte("\xEF\xBB\xBF\n#EXTM3U\n#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/cctv1.m3u8\n"))
+	require.NoError(t, err)
+	require.Equal(t, FormatM3u, format)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 1)
+
+	source, format, err = Parse(ctx, "live.m3u", []byte("# comment\n央视,#genre#\nCCTV1,http://host/cctv1.m3u8\n"))
+	require.NoError(t, err)
+	require.Equal(t, FormatTxt, format)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 1)
+
+	_, format, err = Parse(ctx, "epg.xml", []byte("<tv></tv>"))
+	require.EqualError(t, err, "EPG/XML is not a playlist source")
+	require.Equal(t, FormatXml, format)
+
+	_, format, err = Parse(ctx, "live", []byte("\xEF\xBB\xBF\n<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<tv></tv>"))
+	require.EqualError(t, err, "EPG/XML is not a playlist source")
+	require.Equal(t, FormatXml, format)
+
+	Register(FormatXml, ParserFunc(func(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+		return m3u8x.NewProgramListSource(), nil
+	}))
+	defer func() {
+		parsersMu.Lock()
+		delete(parsers, FormatXml)
+		parsersMu.Unlock()
+	}()
+	_, _, err = Parse(ctx, "epg.xml", []byte("<tv></tv>"))
+	require.NoError(t, err)
+}
+
+func TestParseReader(t *testing.T) {
+	ctx := context.Background()
+	opts := &m3u8x.ParseOptions{
+		MaxLineLength: 1024,
+		Filter: func(tvgName, label string) bool {
+			return tvgName == "CCTV1"
+		},
+	}
+
+	content := "#EXTM3U\n" +
+		"#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/cctv1.m3u8\n" +
+		"#EXTINF:-1 tvg-name=\"CCTV2\" group-title=\"央视\",CCTV2\nhttp://host/cctv2.m3u8\n" +
+		"#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/" + strings.Repeat("a", 1<<17) + "\n" +
+		"#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/cctv1_2.m3u8\n"
+	source, format, err := ParseReader(ctx, "live", strings.NewReader(content), opts)
+	require.NoError(t, err)
+	require.Equal(t, FormatM3u, format)
+	require.Len(t, source.TvgNameChannels, 1)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 2)
+	require.Equal(t, "http://host/cctv1_2.m3u8", source.TvgNameChannels["CCTV1"][1].Url)
+
+	content = "央视,#genre#\n" +
+		"CCTV1,http://host/" + strings.Repeat("a", 1<<17) + "\n" +
+		"CCTV1,http://host/cctv1.m3u8\n" +
+		"CCTV2,http://host/cctv2.m3u8\n"
+	source, format, err = ParseReader(ctx, "live", strings.NewReader(content), opts)
+	require.NoError(t, err)
+	require.Equal(t, FormatTxt, format)
+	require.Len(t, source.TvgNameChannels, 1)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 1)
+}
@@ -0,0 +1,170 @@
+package sourcex
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
+	"github.com/rambollwong/rainbowlog/log"
+)
+
+// LoadFunc loads the content of a remote url.
+type LoadFunc func(ctx context.Context, url string) ([]byte, error)
+
+// OpenFunc opens the content of a remote url as a stream, which must be closed by the caller.
+type OpenFunc func(ctx context.Context, url string) (io.ReadCloser, error)
+
+// tvboxConfig is the part of a TVBox/DIYP JSON config describing live sources.
+type tvboxConfig struct {
+	Lives []*tvboxLive `json:"lives"`
+}
+
+type tvboxLive struct {
+	Name     string          `json:"name"`
+	Group    string          `json:"group"`
+	Url      string          `json:"url"`
+	Epg      string          `json:"epg"`
+	Channels []*tvboxChannel `json:"channels"`
+}
+
+type tvboxChannel struct {
+	Name string   `json:"name"`
+	Urls []string `json:"urls"`
+}
+
+// TvboxParser parses TVBox/DIYP style JSON configs.
+// Each entry of "lives" either points to a txt/m3u list by "url" (or by the base64 encoded "ext"
+// parameter of a "proxy://" url), which is opened and parsed as it is read by the registered parsers,
+// or lists its channels directly in the legacy "channels" form.
+type TvboxParser struct {
+	open OpenFunc
+}
+
+// NewTvboxParser creates a TvboxParser which opens the lists of live entries with the open function.
+func NewTvboxParser(open OpenFunc) *TvboxParser {
+	return &TvboxParser{open: open}
+}
+
+// Parse implements Parser.
+func (p *TvboxParser) Parse(ctx context.Context, name string, r io.Reader, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+	config := &tvboxConfig{}
+	if err := json.NewDecoder(r).Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid tvbox config: %w", err)
+	}
+	if len(config.Lives) == 0 {
+		return nil, errors.New("no lives found in tvbox config")
+	}
+
+	sources := make([]*m3u8x.ProgramListSource, 0, len(config.Lives))
+	for _, live := range config.Lives {
+		var source *m3u8x.ProgramListSource
+		if len(live.Channels) > 0 {
+			source = live.toSource(opts)
+		} else {
+			liveUrl := resolveReference(name, tvboxLiveUrl(live.Url))
+			if liveUrl == "" {
+				continue
+			}
+			var err error
+			source, err = p.loadLive(ctx, liveUrl, opts)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.Error().Msg("Failed to load live of tvbox config, ignore.").
+					Str("source", name).
+					Str("live_name", live.Name).
+					Str("live_url", liveUrl).
+					Err(err).
+					Done()
+				continue
+			}
+			source.SetChannelSource(liveUrl)
+		}
+		if live.Epg != "" && !strings.Contains(live.Epg, "{") {
+			source.XTvgUrls = append(source.XTvgUrls, live.Epg)
+		}
+		sources = append(sources, source)
+	}
+	return m3u8x.MergeProgramListSources(sources), nil
+}
+
+func (p *TvboxParser) loadLive(ctx context.Context, liveUrl string, opts *m3u8x.ParseOptions) (*m3u8x.ProgramListSource, error) {
+	body, err := p.open(ctx, liveUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	br, format, err := detectReaderFormat(liveUrl, body)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatJson {
+		return nil, errors.New("nested json config is not supported")
+	}
+	source, _, err := ParseReader(ctx, liveUrl, br, opts)
+	return source, err
+}
+
+func (l *tvboxLive) toSource(opts *m3u8x.ParseOptions) *m3u8x.ProgramListSource {
+	source := m3u8x.NewProgramListSource()
+	for _, ch := range l.Channels {
+		tvgName := strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(ch.Name)), "-", "")
+		for _, u := range ch.Urls {
+			channel := &m3u8x.Channel{
+				TvgName: tvgName,
+				Group:   l.Group,
+				Title:   tvgName,
+			}
+			channel.Url, channel.Label = m3u8x.SplitUrlLabel(u)
+			if !strings.Contains(channel.Url, "://") || !opts.Keep(tvgName, channel.Label) {
+				continue
+			}
+			source.TvgNameChannels[tvgName] = append(source.TvgNameChannels[tvgName], channel)
+		}
+	}
+	return source
+}
+
+// tvboxLiveUrl returns the list url of a live entry, which is the base64 encoded "ext" parameter
+// for "proxy://do=live&type=txt&ext=..." urls.
+func tvboxLiveUrl(liveUrl string) string {
+	liveUrl = strings.TrimSpace(liveUrl)
+	rest, ok := strings.CutPrefix(liveUrl, "proxy://")
+	if !ok {
+		return liveUrl
+	}
+	query, err := url.ParseQuery(rest)
+	if err != nil {
+		return ""
+	}
+	ext := query.Get("ext")
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding} {
+		if decoded, err := encoding.DecodeString(ext); err == nil {
+			return string(decoded)
+		}
+	}
+	return ext
+}
+
+// resolveReference resolves a url relative to the url of the source containing it.
+func resolveReference(base, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	baseUrl, err := url.Parse(base)
+	if err != nil || baseUrl.Scheme == "" {
+		return ref
+	}
+	refUrl, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseUrl.ResolveReference(refUrl).String()
+}
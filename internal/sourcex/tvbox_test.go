@@ -0,0 +1,107 @@
+package sourcex
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/httpx"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// testOpen opens the content of the url with the default http client.
+func testOpen(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func TestTvboxParser_Parse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/live.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("央视,#genre#\nCCTV1,http://host/cctv1.m3u8\n"))
+	})
+	mux.HandleFunc("/live.m3u", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#EXTM3U\n#EXTINF:-1 tvg-name=\"CCTV1\" group-title=\"央视\",CCTV1\nhttp://host/cctv1-2.m3u8\n"))
+	})
+	mux.HandleFunc("/nested.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"lives": [{"name": "txt", "url": "./live.txt"}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ext := base64.StdEncoding.EncodeToString([]byte(server.URL + "/live.m3u"))
+	config := []byte(`{
+		"sites": [],
+		"lives": [
+			{"name": "txt", "type": 0, "url": "./live.txt", "epg": "http://epg/{name}"},
+			{"name": "proxy", "type": 0, "url": "proxy://do=live&type=txt&ext=` + ext + `", "epg": "http://epg/e.xml"},
+			{"name": "missing", "type": 0, "url": "./missing.txt"},
+			{"name": "nested", "type": 0, "url": "./nested.json"},
+			{"group": "卫视", "channels": [{"name": "湖南卫视", "urls": ["http://host/hunan.m3u8$电信", "not a url"]}]}
+		]
+	}`)
+	source, err := NewTvboxParser(testOpen).Parse(context.Background(), server.URL+"/tvbox.json", bytes.NewReader(config), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://epg/e.xml"}, source.XTvgUrls)
+
+	cctv1 := source.TvgNameChannels["CCTV1"]
+	require.Len(t, cctv1, 2)
+	require.Equal(t, "http://host/cctv1.m3u8", cctv1[0].Url)
+	require.Equal(t, server.URL+"/live.txt", cctv1[0].Source)
+	require.Equal(t, "http://host/cctv1-2.m3u8", cctv1[1].Url)
+	require.Equal(t, server.URL+"/live.m3u", cctv1[1].Source)
+
+	hunan := source.TvgNameChannels["湖南卫视"]
+	require.Len(t, hunan, 1)
+	require.Equal(t, "电信", hunan[0].Label)
+	require.Equal(t, "卫视", hunan[0].Group)
+	require.Empty(t, hunan[0].Source)
+
+	_, err = NewTvboxParser(testOpen).Parse(context.Background(), "tvbox.json", strings.NewReader(`{"sites": []}`), nil)
+	require.Error(t, err)
+}
+
+func TestParseReadCloser_TvboxSameHost(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tvbox.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"lives": [{"name": "txt", "url": "./live.txt"}]}`))
+	})
+	mux.HandleFunc("/live.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("央视,#genre#\nCCTV1,http://host/cctv1.m3u8\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// the lives url is on the same host as the config, which allows only one request at a time
+	limiter := httpx.NewHostLimiter([]*proto.HostLimit{{Host: "127.0.0.1", MaxConcurrency: 1}})
+	client := httpx.NewClient(httpx.WithHostLimiter(limiter))
+	Register(FormatJson, NewTvboxParser(client.OpenUrl))
+	defer func() {
+		parsersMu.Lock()
+		delete(parsers, FormatJson)
+		parsersMu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	body, err := client.OpenUrl(ctx, server.URL+"/tvbox.json")
+	require.NoError(t, err)
+	source, format, err := ParseReadCloser(ctx, server.URL+"/tvbox.json", body, nil)
+	require.NoError(t, err)
+	require.Equal(t, FormatJson, format)
+	require.Len(t, source.TvgNameChannels["CCTV1"], 1)
+}
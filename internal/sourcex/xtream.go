@@ -0,0 +1,117 @@
+package sourcex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+const DefaultXtreamOutput = "m3u8"
+
+type xtreamCategory struct {
+	CategoryId   flexString `json:"category_id"`
+	CategoryName string     `json:"category_name"`
+}
+
+type xtreamStream struct {
+	Name       string     `json:"name"`
+	StreamId   flexString `json:"stream_id"`
+	StreamIcon string     `json:"stream_icon"`
+	CategoryId flexString `json:"category_id"`
+}
+
+// flexString is a JSON string or number, Xtream Codes servers differ in the types of ids.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*s = flexString(str)
+		return nil
+	}
+	*s = flexString(data)
+	return nil
+}
+
+// XtreamSourceName returns the name of an Xtream Codes source used as the source of its channels,
+// which does not contain the password.
+func XtreamSourceName(xs *proto.XtreamSource) string {
+	return fmt.Sprintf("%s (%s)", strings.TrimRight(xs.Server, "/"), xs.Username)
+}
+
+// LoadXtreamSource loads the live categories and streams of an Xtream Codes server by its
+// player_api.php, and converts them into a ProgramListSource whose channels are grouped by category.
+func LoadXtreamSource(ctx context.Context, load LoadFunc, xs *proto.XtreamSource) (*m3u8x.ProgramListSource, error) {
+	server := strings.TrimRight(xs.Server, "/")
+	if server == "" || xs.Username == "" {
+		return nil, fmt.Errorf("server and username of xtream source are required")
+	}
+	output := xs.Output
+	if output == "" {
+		output = DefaultXtreamOutput
+	}
+	apiUrl := func(action string) string {
+		return fmt.Sprintf("%s/player_api.php?username=%s&password=%s&action=%s",
+			server, url.QueryEscape(xs.Username), url.QueryEscape(xs.Password), action)
+	}
+
+	var categories []*xtreamCategory
+	if err := loadJson(ctx, load, apiUrl("get_live_categories"), &categories); err != nil {
+		return nil, fmt.Errorf("failed to load live categories: %w", err)
+	}
+	var streams []*xtreamStream
+	if err := loadJson(ctx, load, apiUrl("get_live_streams"), &streams); err != nil {
+		return nil, fmt.Errorf("failed to load live streams: %w", err)
+	}
+
+	categoryNames := make(map[flexString]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.CategoryId] = category.CategoryName
+	}
+
+	source := m3u8x.NewProgramListSource()
+	source.XTvgUrls = []string{fmt.Sprintf("%s/xmltv.php?username=%s&password=%s",
+		server, url.QueryEscape(xs.Username), url.QueryEscape(xs.Password))}
+	for _, stream := range streams {
+		if stream.StreamId == "" {
+			continue
+		}
+		tvgName := strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(stream.Name)), "-", "")
+		source.TvgNameChannels[tvgName] = append(source.TvgNameChannels[tvgName], &m3u8x.Channel{
+			TvgName: tvgName,
+			TvgLogo: stream.StreamIcon,
+			Group:   categoryNames[stream.CategoryId],
+			Title:   tvgName,
+			Url: fmt.Sprintf("%s/live/%s/%s/%s.%s", server,
+				url.PathEscape(xs.Username), url.PathEscape(xs.Password), stream.StreamId, output),
+		})
+	}
+	return source, nil
+}
+
+func loadJson(ctx context.Context, load LoadFunc, apiUrl string, v any) error {
+	content, err := load(ctx, apiUrl)
+	if err != nil {
+		// The error of the request contains the url with the password, strip it
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	return json.Unmarshal(TrimContent(content), v)
+}
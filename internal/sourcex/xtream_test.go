@@ -0,0 +1,77 @@
+package sourcex
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+// testLoad loads the content of the url with the default http client.
+func testLoad(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func TestLoadXtreamSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/player_api.php" || query.Get("username") != "user" || query.Get("password") != "p@ss" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch query.Get("action") {
+		case "get_live_categories":
+			_, _ = w.Write([]byte(`[{"category_id":"1","category_name":"央视","parent_id":0},
+				{"category_id":2,"category_name":"卫视","parent_id":0}]`))
+		case "get_live_streams":
+			_, _ = w.Write([]byte(`[
+				{"num":1,"name":"CCTV-1","stream_type":"live","stream_id":101,"stream_icon":"http://logo/cctv1.png","category_id":"1"},
+				{"num":2,"name":"湖南卫视","stream_type":"live","stream_id":"201","stream_icon":"","category_id":"2"},
+				{"num":3,"name":"broken","stream_type":"live","stream_id":null,"category_id":"2"}]`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	source, err := LoadXtreamSource(context.Background(), testLoad, &proto.XtreamSource{
+		Server:   server.URL + "/",
+		Username: "user",
+		Password: "p@ss",
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{server.URL + "/xmltv.php?username=user&password=p%40ss"}, source.XTvgUrls)
+	require.Len(t, source.TvgNameChannels, 2)
+
+	cctv1 := source.TvgNameChannels["CCTV1"]
+	require.Len(t, cctv1, 1)
+	require.Equal(t, server.URL+"/live/user/p@ss/101.m3u8", cctv1[0].Url)
+	require.Equal(t, "央视", cctv1[0].Group)
+	require.Equal(t, "http://logo/cctv1.png", cctv1[0].TvgLogo)
+
+	hunan := source.TvgNameChannels["湖南卫视"]
+	require.Len(t, hunan, 1)
+	require.Equal(t, server.URL+"/live/user/p@ss/201.m3u8", hunan[0].Url)
+	require.Equal(t, "卫视", hunan[0].Group)
+
+	_, err = LoadXtreamSource(context.Background(), testLoad, &proto.XtreamSource{
+		Server:   server.URL,
+		Username: "user",
+		Password: "wrong",
+	})
+	require.Error(t, err)
+	require.Equal(t, server.URL+" (user)", XtreamSourceName(&proto.XtreamSource{Server: server.URL, Username: "user"}))
+}
@@ -1,12 +1,18 @@
 package txtx
 
 import (
+	"maps"
+	"slices"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/urlx"
 	"github.com/rambollwong/rainbowcat/types"
 	"github.com/rambollwong/rainbowlog/log"
 )
 
 // MergeTvgNameChannels merges multiple TvgNameChannels into one,
-// ensuring that channels with the same URL are not duplicated.
+// ensuring that channels with the same URL (compared in their canonical form, see urlx.Canonicalize)
+// are not duplicated. The TVG names of each TvgNameChannels are merged in sorted order,
+// so a URL listed under several TVG names is always kept under the same one.
 // It returns a merged TvgNameChannels map.
 func MergeTvgNameChannels(tvgNameChannels []TvgNameChannels) (merged TvgNameChannels) {
 	// Initialize the merged result and a set to track existing URLs
@@ -15,8 +21,9 @@ func MergeTvgNameChannels(tvgNameChannels []TvgNameChannels) (merged TvgNameChan
 
 	// Iterate over each TvgNameChannels collection
 	for _, tvgNameChannel := range tvgNameChannels {
-		// Iterate over each TVG name and its associated channels
-		for tvgName, channels := range tvgNameChannel {
+		// Iterate over each TVG name in sorted order and its associated channels
+		for _, tvgName := range slices.Sorted(maps.Keys(tvgNameChannel)) {
+			channels := tvgNameChannel[tvgName]
 			// Check if the TVG name already exists in the merged result
 			ch, ok := merged[tvgName]
 			if !ok {
@@ -27,16 +34,17 @@ func MergeTvgNameChannels(tvgNameChannels []TvgNameChannels) (merged TvgNameChan
 			// Iterate over each channel in the current TVG name's channels
 			for _, c := range channels {
 				c := c // Create copy to avoid loop variable capture
-				// Skip the channel if its URL already exists in the merged result
-				if existUrl.Exist(c.Url) {
+				// Skip the channel if its canonical URL already exists in the merged result
+				canonicalUrl := urlx.Canonicalize(c.Url)
+				if existUrl.Exist(canonicalUrl) {
 					log.Debug().Msg("Channel url already exists, skip.").
 						Str("url", c.Url).
 						Done()
 					continue
 				}
 
-				// Add the channel's URL to the set of existing URLs
-				existUrl.Put(c.Url)
+				// Add the channel's canonical URL to the set of existing URLs
+				existUrl.Put(canonicalUrl)
 				// Append the channel to the merged result
 				ch = append(ch, c)
 			}
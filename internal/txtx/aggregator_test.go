@@ -123,7 +123,24 @@ func TestMergeTvgNameChannels(t *testing.T) {
 		}
 	})
 
-	// Test case 5: Multiple same channel names but different groups
+	// Test case 5: Same URL under different TVG names of one input
+	t.Run("same url under different tvg names", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			channels := NewTvgNameChannels()
+			channels["Channel2"] = []*Channel{{TvgName: "Channel2", Group: "Group1", Url: "url1"}}
+			channels["Channel1"] = []*Channel{{TvgName: "Channel1", Group: "Group1", Url: "url1"}}
+			channels["Channel3"] = []*Channel{{TvgName: "Channel3", Group: "Group1", Url: "url3"}}
+
+			result := MergeTvgNameChannels([]TvgNameChannels{channels})
+
+			// The URL should always be kept under the first TVG name in sorted order
+			if len(result["Channel1"]) != 1 || len(result["Channel2"]) != 0 {
+				t.Errorf("MergeTvgNameChannels() should keep url1 under Channel1, got %v", result)
+			}
+		}
+	})
+
+	// Test case 6: Multiple same channel names but different groups
 	t.Run("multiple same channel names", func(t *testing.T) {
 		channels1 := NewTvgNameChannels()
 		channels1["Channel1"] = []*Channel{
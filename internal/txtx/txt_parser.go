@@ -1,11 +1,13 @@
 package txtx
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
+	"io"
 	"strings"
 
-	"github.com/rambollwong/rainbowlog/log"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/filex"
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
 )
 
 const (
@@ -17,6 +19,7 @@ type Channel struct {
 	TvgName string // TvgName is the name of the channel
 	Group   string // Group is the category or group the channel belongs to
 	Url     string // Url is the streaming URL of the channel
+	Label   string // Label is the line label after "$" of the URL, e.g. 电信, 联通 or IPV6
 }
 
 // TvgNameChannels is a map where the key is the channel name and the value is a slice of Channel pointers
@@ -27,54 +30,142 @@ func NewTvgNameChannels() TvgNameChannels {
 	return make(TvgNameChannels)
 }
 
+// TxtLineIssue describes a line of a txt source that could not be parsed.
+type TxtLineIssue struct {
+	LineNo int    // LineNo is the number of the line, starting from 1
+	Line   string // Line is the content of the line
+	Reason string // Reason why the line could not be parsed
+}
+
+// TxtParseReport is the per-line diagnostics summary of parsing a txt source.
+type TxtParseReport struct {
+	Lines         int             // Lines is the number of non-empty lines
+	Comments      int             // Comments is the number of comment lines starting with "#"
+	Groups        int             // Groups is the number of group marker lines
+	Channels      int             // Channels is the number of channels parsed
+	MultiUrlLines int             // MultiUrlLines is the number of lines with several "#"-joined urls
+	Filtered      int             // Filtered is the number of channels dropped by the filter of the parse options
+	TooLongLines  int             // TooLongLines is the number of lines skipped for exceeding the max line length
+	Issues        []*TxtLineIssue // Issues are the lines or urls that could not be parsed
+}
+
+const (
+	IssueNoComma      = "no comma"
+	IssueEmptyName    = "empty channel name"
+	IssueExtraComma   = "extra comma"
+	IssueMissingGroup = "empty group name"
+)
+
+// IssueReasons counts the issues by reason.
+func (r *TxtParseReport) IssueReasons() map[string]int {
+	reasons := make(map[string]int)
+	for _, issue := range r.Issues {
+		reasons[issue.Reason]++
+	}
+	return reasons
+}
+
+func (r *TxtParseReport) addIssue(lineNo int, line, reason string) {
+	r.Issues = append(r.Issues, &TxtLineIssue{LineNo: lineNo, Line: line, Reason: reason})
+}
+
 // ParseTxt parses the given source byte slice which contains channel data in a specific format
 // and populates the TvgNameChannels map with the parsed information.
-func (t TvgNameChannels) ParseTxt(source []byte) {
-	scanner := bufio.NewScanner(bytes.NewReader(source))
+// It returns the diagnostics summary of the lines parsed. See ParseTxtFromReader.
+func (t TvgNameChannels) ParseTxt(source []byte) *TxtParseReport {
+	// Reading from a byte slice never fails
+	report, _ := t.ParseTxtFromReader(bytes.NewReader(source), nil)
+	return report
+}
+
+// ParseTxtFromReader parses the txt content read line by line from the reader
+// and populates the TvgNameChannels map with the channels accepted by the options.
+// Each line is split on the first comma into the channel name and its urls, so that commas in
+// url query strings are kept, and several urls joined by "#" are parsed as separate channels.
+// Lines longer than the max line length of the options are skipped and counted in the report.
+// It returns the diagnostics summary of the lines parsed, and the error of reading if any.
+func (t TvgNameChannels) ParseTxtFromReader(r io.Reader, opts *m3u8x.ParseOptions) (*TxtParseReport, error) {
+	lr := filex.NewLineReader(r, opts.MaxLineLengthOrDefault())
+	report := &TxtParseReport{}
 	var currentGroup string // Holds the current group name while parsing
 
 	// Iterate through each line in the source
-	lineNo := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNo++
+	for {
+		line, lineNo, err := lr.Next()
+		if err != nil {
+			report.TooLongLines = lr.Skipped()
+			if errors.Is(err, io.EOF) {
+				return report, nil
+			}
+			return report, err
+		}
 		line = strings.TrimSpace(line) // Remove leading and trailing whitespaces
 
 		// Skip empty lines
 		if line == "" {
 			continue
 		}
+		report.Lines++
 
-		// Split the line by comma to extract channel information
-		arr := strings.Split(line, ",")
-		if len(arr) != 2 {
-			log.Debug().Msg("invalid line.").Int("line_no", lineNo).Str("line", line).Done()
-			continue // Skip lines that don't have exactly two parts
+		// Split the line by the first comma to extract channel information
+		name, urls, ok := strings.Cut(line, ",")
+		name, urls = strings.TrimSpace(name), strings.TrimSpace(urls)
+		// Lines starting with "#" are comments, even with commas in them, unless they are group markers
+		if strings.HasPrefix(line, "#") && urls != TxtGenre {
+			report.Comments++
+			continue
+		}
+		if !ok {
+			report.addIssue(lineNo, line, IssueNoComma)
+			continue // Skip lines that don't have any comma
 		}
 
 		// Check if the second part indicates a genre/group marker
-		if arr[1] == TxtGenre {
-			currentGroup = arr[0] // Set the current group name
+		if urls == TxtGenre {
+			if name == "" {
+				report.addIssue(lineNo, line, IssueMissingGroup)
+			}
+			report.Groups++
+			currentGroup = name // Set the current group name
 			continue
 		}
-
-		// Create a new Channel instance with the parsed data
-		channel := &Channel{
-			TvgName: strings.ReplaceAll(strings.ToUpper(arr[0]), "-", ""),
-			Group:   currentGroup,
-			Url:     arr[1],
-		}
-		channel.Url = strings.TrimSpace(channel.Url)
-		if dlIdx := strings.Index(channel.Url, "$"); dlIdx != -1 {
-			channel.Url = channel.Url[:dlIdx]
+		if name == "" {
+			report.addIssue(lineNo, line, IssueEmptyName)
+			continue
 		}
 
-		// Initialize the slice for this channel name if it doesn't exist
-		if _, ok := t[channel.TvgName]; !ok {
-			t[channel.TvgName] = make([]*Channel, 0, 16)
+		alternatives := strings.Split(urls, "#")
+		if len(alternatives) > 1 {
+			report.MultiUrlLines++
 		}
+		for _, alternative := range alternatives {
+			if strings.TrimSpace(alternative) == "" {
+				continue // Tolerate empty alternatives, e.g. a trailing "#"
+			}
+			// Create a new Channel instance with the parsed data
+			channel := &Channel{
+				TvgName: strings.ReplaceAll(strings.ToUpper(name), "-", ""),
+				Group:   currentGroup,
+			}
+			channel.Url, channel.Label = m3u8x.SplitUrlLabel(alternative)
+			// Commas are only kept in urls with a scheme, e.g. in their query strings
+			if strings.Contains(channel.Url, ",") && !strings.Contains(channel.Url, "://") {
+				report.addIssue(lineNo, line, IssueExtraComma)
+				continue
+			}
+			if !opts.Keep(channel.TvgName, channel.Label) {
+				report.Filtered++
+				continue
+			}
 
-		// Append the new channel to the slice for this channel name
-		t[channel.TvgName] = append(t[channel.TvgName], channel)
+			// Initialize the slice for this channel name if it doesn't exist
+			if _, ok := t[channel.TvgName]; !ok {
+				t[channel.TvgName] = make([]*Channel, 0, 16)
+			}
+
+			// Append the new channel to the slice for this channel name
+			t[channel.TvgName] = append(t[channel.TvgName], channel)
+			report.Channels++
+		}
 	}
 }
@@ -2,7 +2,10 @@ package txtx
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
 )
 
 func TestNewTvgNameChannels(t *testing.T) {
@@ -81,6 +84,46 @@ another,invalid,line`),
 				},
 			},
 		},
+		{
+			name: "urls with line labels",
+			source: []byte(`Group1,#genre#
+Channel1,url1$电信
+Channel1,url2 $ IPV6
+Channel1,url3$`),
+			expected: TvgNameChannels{
+				"CHANNEL1": []*Channel{
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "url1", Label: "电信"},
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "url2", Label: "IPV6"},
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "url3"},
+				},
+			},
+		},
+		{
+			name: "urls joined by #",
+			source: []byte(`Group1,#genre#
+Channel1,url1#url2$电信#`),
+			expected: TvgNameChannels{
+				"CHANNEL1": []*Channel{
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "url1"},
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "url2", Label: "电信"},
+				},
+			},
+		},
+		{
+			name: "split on the first comma",
+			source: []byte(`Group1,#genre#
+Channel1,http://host/url1#http://host/url2?a=1,2$电信
+Channel2, http://host/play?ids=1,2,3 `),
+			expected: TvgNameChannels{
+				"CHANNEL1": []*Channel{
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "http://host/url1"},
+					{TvgName: "CHANNEL1", Group: "Group1", Url: "http://host/url2?a=1,2", Label: "电信"},
+				},
+				"CHANNEL2": []*Channel{
+					{TvgName: "CHANNEL2", Group: "Group1", Url: "http://host/play?ids=1,2,3"},
+				},
+			},
+		},
 		{
 			name: "multiple channels with same name",
 			source: []byte(`Group1,#genre#
@@ -127,3 +170,66 @@ Channel1,url2`),
 		})
 	}
 }
+
+func TestTvgNameChannels_ParseTxtReport(t *testing.T) {
+	source := []byte(`# comment
+Group1,#genre#
+# note, foo
+Channel1,http://host/url1#http://host/url2
+invalid_line
+,http://host/url3
+Channel2,invalid,line#http://host/url4
+`)
+	tvc := NewTvgNameChannels()
+	report := tvc.ParseTxt(source)
+
+	if report.Lines != 7 || report.Comments != 2 || report.Groups != 1 ||
+		report.Channels != 3 || report.MultiUrlLines != 2 {
+		t.Errorf("ParseTxt() got report %+v", report)
+	}
+	if _, ok := tvc["# NOTE"]; ok {
+		t.Error("ParseTxt() should skip the comment line with a comma")
+	}
+	expectedIssues := []*TxtLineIssue{
+		{LineNo: 5, Line: "invalid_line", Reason: IssueNoComma},
+		{LineNo: 6, Line: ",http://host/url3", Reason: IssueEmptyName},
+		{LineNo: 7, Line: "Channel2,invalid,line#http://host/url4", Reason: IssueExtraComma},
+	}
+	if !reflect.DeepEqual(report.Issues, expectedIssues) {
+		t.Errorf("ParseTxt() got issues %+v, want %+v", report.Issues, expectedIssues)
+	}
+	if reasons := report.IssueReasons(); reasons[IssueNoComma] != 1 || len(reasons) != 3 {
+		t.Errorf("IssueReasons() got %v", reasons)
+	}
+}
+
+func TestTvgNameChannels_ParseTxtFromReader(t *testing.T) {
+	source := "Group1,#genre#\n" +
+		"Channel1,http://host/" + strings.Repeat("a", 1<<17) + "\n" +
+		"Channel1,http://host/url1$电信\n" +
+		"Channel1,http://host/url2$联通\n" +
+		"Channel2,http://host/url3\n"
+	opts := &m3u8x.ParseOptions{
+		MaxLineLength: 1024,
+		Filter: func(tvgName, label string) bool {
+			return tvgName == "CHANNEL1" && label != "联通"
+		},
+	}
+
+	tvc := NewTvgNameChannels()
+	report, err := tvc.ParseTxtFromReader(strings.NewReader(source), opts)
+	if err != nil {
+		t.Fatalf("ParseTxtFromReader() error = %v", err)
+	}
+	expected := TvgNameChannels{
+		"CHANNEL1": []*Channel{
+			{TvgName: "CHANNEL1", Group: "Group1", Url: "http://host/url1", Label: "电信"},
+		},
+	}
+	if !reflect.DeepEqual(tvc, expected) {
+		t.Errorf("ParseTxtFromReader() = %v, want %v", tvc, expected)
+	}
+	if report.TooLongLines != 1 || report.Filtered != 2 || report.Channels != 1 {
+		t.Errorf("ParseTxtFromReader() report = %+v, want 1 too long line, 2 filtered and 1 channel", report)
+	}
+}
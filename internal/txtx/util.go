@@ -2,7 +2,6 @@ package txtx
 
 import (
 	"strings"
-	"time"
 
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
 	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
@@ -23,6 +22,7 @@ func ToM3u(txt TvgNameChannels) *m3u8x.ProgramListSource {
 				Group:   c.Group,
 				Title:   c.TvgName,
 				Url:     c.Url,
+				Label:   c.Label,
 			})
 		}
 
@@ -43,6 +43,7 @@ func FromM3u(pls *m3u8x.ProgramListSource) TvgNameChannels {
 				TvgName: c.TvgName,
 				Group:   c.Group,
 				Url:     c.Url,
+				Label:   c.Label,
 			})
 		}
 		txt[tvgName] = ch
@@ -50,14 +51,32 @@ func FromM3u(pls *m3u8x.ProgramListSource) TvgNameChannels {
 	return txt
 }
 
-func OutputTvgNameChannelsToTxtBz(txt TvgNameChannels, groupList []*proto.GroupList) []byte {
+// OutputTvgNameChannelsToTxtBz converts the TvgNameChannels into a txt formatted byte slice,
+// with the update time channels of the options in their own group at the beginning.
+// The opts may be nil, and the options not supported by the txt format are ignored.
+func OutputTvgNameChannelsToTxtBz(
+	txt TvgNameChannels,
+	groupList []*proto.GroupList,
+	opts *m3u8x.OutputOptions,
+) []byte {
+	if opts == nil {
+		opts = &m3u8x.OutputOptions{}
+	}
+	updateTimeChannels := opts.UpdateTimeChannels
 	b := strings.Builder{}
-	b.WriteString("更新时间,")
-	b.WriteString(TxtGenre)
-	b.WriteString("\n")
-	b.WriteString(time.Now().Format("20060102 15:04:05"))
-	b.WriteString(",http://tc-tct.douyucdn2.cn/dyliveflv1/122402rK7MO9bXSq_2000.flv?wsAuth=8cea39337984fd3341cc9ec569502e4f&token=cpn-androidmpro-0-122402-0fcea45d2300cfa0ac75fafd8679bb53af10de8c33ae99d9&logo=0&expire=0&did=d010b07dcb997ada9934081c873542f0&origin=tct&vhost=p\n")
-	b.WriteString("\n\n")
+	if len(updateTimeChannels) > 0 {
+		b.WriteString(updateTimeChannels[0].Group)
+		b.WriteString(",")
+		b.WriteString(TxtGenre)
+		b.WriteString("\n")
+		for _, channel := range updateTimeChannels {
+			b.WriteString(channel.Title)
+			b.WriteString(",")
+			b.WriteString(channel.Url)
+			b.WriteString("\n")
+		}
+		b.WriteString("\n\n")
+	}
 
 	for _, list := range groupList {
 		group := list.Group
@@ -68,7 +87,7 @@ func OutputTvgNameChannelsToTxtBz(txt TvgNameChannels, groupList []*proto.GroupL
 		b.WriteString("\n")
 
 		for _, tvgName := range list.TvgName {
-			channels, ok := txt[tvgName]
+			channels, ok := txt[m3u8x.MainTvgName(tvgName)]
 			if !ok {
 				continue
 			}
@@ -76,6 +95,10 @@ func OutputTvgNameChannelsToTxtBz(txt TvgNameChannels, groupList []*proto.GroupL
 				b.WriteString(channel.TvgName)
 				b.WriteString(",")
 				b.WriteString(channel.Url)
+				if channel.Label != "" {
+					b.WriteString("$")
+					b.WriteString(channel.Label)
+				}
 				b.WriteString("\n")
 			}
 		}
@@ -3,6 +3,7 @@ package txtx
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rambollwong/rainbow-iptv-source-filter/internal/m3u8x"
 	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
@@ -203,6 +204,7 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 		}
 		txt["Channel2"] = []*Channel{
 			{TvgName: "Channel2", Group: "Group2", Url: "url2"},
+			{TvgName: "Channel2", Group: "Group2", Url: "url3", Label: "联通"},
 		}
 
 		// Create group list
@@ -218,7 +220,7 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 		}
 
 		// Execute function
-		result := OutputTvgNameChannelsToTxtBz(txt, groupList)
+		result := OutputTvgNameChannelsToTxtBz(txt, groupList, newTestOutputOptions(t))
 
 		// Verify result is not empty
 		if len(result) == 0 {
@@ -246,6 +248,11 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 				strings.Index(resultStr, "Channel2,url2") == -1 {
 				t.Error("OutputTvgNameChannelsToTxtBz() should contain channel data")
 			}
+
+			// Check if line label is re-emitted
+			if strings.Index(resultStr, "Channel2,url3$联通\n") == -1 {
+				t.Error("OutputTvgNameChannelsToTxtBz() should contain channel line label")
+			}
 		}
 	})
 
@@ -254,7 +261,7 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 		txt := NewTvgNameChannels()
 		var groupList []*proto.GroupList
 
-		result := OutputTvgNameChannelsToTxtBz(txt, groupList)
+		result := OutputTvgNameChannelsToTxtBz(txt, groupList, newTestOutputOptions(t))
 
 		if len(result) == 0 {
 			t.Error("OutputTvgNameChannelsToTxtBz() should return non-empty byte slice even with empty inputs")
@@ -285,9 +292,14 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 			},
 		}
 
-		result := OutputTvgNameChannelsToTxtBz(txt, groupList)
+		result := OutputTvgNameChannelsToTxtBz(txt, groupList, nil)
 		resultStr := string(result)
 
+		// Should not contain update time header since no update time channels given
+		if strings.Index(resultStr, "更新时间,#genre#") != -1 {
+			t.Error("OutputTvgNameChannelsToTxtBz() should not contain update time header without update time channels")
+		}
+
 		// Should contain Channel1 but not Channel2
 		if strings.Index(resultStr, "Channel1,url1") == -1 {
 			t.Error("OutputTvgNameChannelsToTxtBz() should contain Channel1 data")
@@ -299,3 +311,14 @@ func TestOutputTvgNameChannelsToTxtBz(t *testing.T) {
 		}
 	})
 }
+
+func newTestOutputOptions(t *testing.T) *m3u8x.OutputOptions {
+	channels, err := m3u8x.NewUpdateTimeChannels(
+		&proto.UpdateTimeChannel{Enable: true, Url: "http://127.0.0.1/update_time.m3u8"},
+		&m3u8x.UpdateSummary{UpdateTime: time.Now()},
+	)
+	if err != nil {
+		t.Fatalf("NewUpdateTimeChannels() failed: %v", err)
+	}
+	return &m3u8x.OutputOptions{UpdateTimeChannels: channels}
+}
@@ -0,0 +1,103 @@
+package urlx
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"rtsp":  "554",
+	"rtmp":  "1935",
+}
+
+var defaultCanonicalizer = NewCanonicalizer(nil)
+
+// Canonicalizer converts urls of the same stream into the same canonical form,
+// which is used as the key for deduplication and caching.
+type Canonicalizer struct {
+	ignoredQueryParams []*proto.IgnoredQueryParams
+}
+
+// NewCanonicalizer creates a Canonicalizer which also drops the query parameters ignorable
+// for the matched hosts, e.g. rotating tokens. See MatchHost for the host patterns.
+func NewCanonicalizer(ignoredQueryParams []*proto.IgnoredQueryParams) *Canonicalizer {
+	return &Canonicalizer{ignoredQueryParams: ignoredQueryParams}
+}
+
+// SetDefaultCanonicalizer sets the Canonicalizer used by Canonicalize.
+func SetDefaultCanonicalizer(c *Canonicalizer) {
+	defaultCanonicalizer = c
+}
+
+// Canonicalize canonicalizes the url with the default Canonicalizer.
+func Canonicalize(rawUrl string) string {
+	return defaultCanonicalizer.Canonicalize(rawUrl)
+}
+
+// Canonicalize returns the canonical form of the url:
+// the scheme and host are lowercased, the default port, the trailing slash of the path,
+// the fragment and the ignorable query parameters are removed, and the query parameters are sorted.
+// The url is returned trimmed but otherwise unchanged if it is not an absolute url.
+func (c *Canonicalizer) Canonicalize(rawUrl string) string {
+	rawUrl = strings.TrimSpace(rawUrl)
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return rawUrl
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := u.Hostname(), u.Port()
+	host = strings.ToLower(host)
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	u.Fragment, u.RawFragment = "", ""
+
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err == nil {
+			for _, rule := range c.ignoredQueryParams {
+				if !MatchHost(rule.Host, host) {
+					continue
+				}
+				for _, param := range rule.Params {
+					query.Del(param)
+				}
+			}
+			// Encode sorts the query parameters by key
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// MatchHost reports whether the host matches the pattern.
+// The pattern "*" matches all hosts, a pattern starting with "*." or "." matches the domain
+// and all its subdomains, and any other pattern matches the host exactly. Matching is case-insensitive.
+func MatchHost(pattern, host string) bool {
+	pattern, host = strings.ToLower(strings.TrimSpace(pattern)), strings.ToLower(host)
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."), strings.HasPrefix(pattern, "."):
+		domain := strings.TrimPrefix(strings.TrimPrefix(pattern, "*"), ".")
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	default:
+		return host == pattern
+	}
+}
@@ -0,0 +1,76 @@
+package urlx
+
+import (
+	"testing"
+
+	"github.com/rambollwong/rainbow-iptv-source-filter/pkg/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizer_Canonicalize(t *testing.T) {
+	c := NewCanonicalizer([]*proto.IgnoredQueryParams{
+		{Host: "*.example.com", Params: []string{"token", "t"}},
+	})
+	tests := []struct {
+		name     string
+		urls     []string
+		expected string
+	}{
+		{
+			name: "case, default port and trailing slash",
+			urls: []string{
+				"HTTP://Live.Test.COM:80/hls/cctv1/",
+				"http://live.test.com/hls/cctv1",
+				" http://live.test.com/hls/cctv1#frag ",
+			},
+			expected: "http://live.test.com/hls/cctv1",
+		},
+		{
+			name: "query order",
+			urls: []string{
+				"http://live.test.com/play?id=1&fmt=m3u8",
+				"http://live.test.com/play?fmt=m3u8&id=1",
+			},
+			expected: "http://live.test.com/play?fmt=m3u8&id=1",
+		},
+		{
+			name: "ignored query params of matched host",
+			urls: []string{
+				"https://cdn.example.com:443/live.m3u8?token=abc&id=2&t=1700000000",
+				"https://cdn.example.com/live.m3u8?id=2&token=def",
+			},
+			expected: "https://cdn.example.com/live.m3u8?id=2",
+		},
+		{
+			name:     "query params of unmatched host are kept",
+			urls:     []string{"https://cdn.example.org/live.m3u8?token=abc"},
+			expected: "https://cdn.example.org/live.m3u8?token=abc",
+		},
+		{
+			name:     "ipv6 host",
+			urls:     []string{"http://[2409:8087::1]:80/live.m3u8"},
+			expected: "http://[2409:8087::1]/live.m3u8",
+		},
+		{
+			name:     "not an absolute url",
+			urls:     []string{"url1"},
+			expected: "url1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, u := range tt.urls {
+				require.Equal(t, tt.expected, c.Canonicalize(u))
+			}
+		})
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	require.True(t, MatchHost("*", "a.b.com"))
+	require.True(t, MatchHost("*.b.com", "a.b.com"))
+	require.True(t, MatchHost(".b.com", "b.com"))
+	require.True(t, MatchHost("A.B.com", "a.b.COM"))
+	require.False(t, MatchHost("*.b.com", "ab.com"))
+	require.False(t, MatchHost("a.b.com", "c.a.b.com"))
+}
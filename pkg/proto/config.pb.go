@@ -33,6 +33,23 @@ type Config struct {
 	CustomUA                       string                 `protobuf:"bytes,8,opt,name=custom_u_a,json=customUA,proto3" json:"custom_u_a,omitempty"`
 	ParallelExecutorNum            int64                  `protobuf:"varint,9,opt,name=parallel_executor_num,json=parallelExecutorNum,proto3" json:"parallel_executor_num,omitempty"`
 	HostCustomUA                   []string               `protobuf:"bytes,10,rep,name=host_custom_u_a,json=hostCustomUA,proto3" json:"host_custom_u_a,omitempty"`
+	Outputs                        []*Output              `protobuf:"bytes,11,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	UpdateTimeChannel              *UpdateTimeChannel     `protobuf:"bytes,12,opt,name=update_time_channel,json=updateTimeChannel,proto3" json:"update_time_channel,omitempty"`
+	SourceStatsFile                string                 `protobuf:"bytes,13,opt,name=source_stats_file,json=sourceStatsFile,proto3" json:"source_stats_file,omitempty"`
+	SourcePriorities               []*SourcePriority      `protobuf:"bytes,14,rep,name=source_priorities,json=sourcePriorities,proto3" json:"source_priorities,omitempty"`
+	IgnoredQueryParams             []*IgnoredQueryParams  `protobuf:"bytes,15,rep,name=ignored_query_params,json=ignoredQueryParams,proto3" json:"ignored_query_params,omitempty"`
+	LineLabels                     *LineLabels            `protobuf:"bytes,16,opt,name=line_labels,json=lineLabels,proto3" json:"line_labels,omitempty"`
+	XtreamSources                  []*XtreamSource        `protobuf:"bytes,17,rep,name=xtream_sources,json=xtreamSources,proto3" json:"xtream_sources,omitempty"`
+	MaxLineLength                  int64                  `protobuf:"varint,18,opt,name=max_line_length,json=maxLineLength,proto3" json:"max_line_length,omitempty"`
+	SourceMirrorDir                string                 `protobuf:"bytes,19,opt,name=source_mirror_dir,json=sourceMirrorDir,proto3" json:"source_mirror_dir,omitempty"`
+	UrlRewriteRules                []*UrlRewriteRule      `protobuf:"bytes,20,rep,name=url_rewrite_rules,json=urlRewriteRules,proto3" json:"url_rewrite_rules,omitempty"`
+	Network                        *Network               `protobuf:"bytes,21,opt,name=network,proto3" json:"network,omitempty"`
+	HeaderProfiles                 []*HeaderProfile       `protobuf:"bytes,22,rep,name=header_profiles,json=headerProfiles,proto3" json:"header_profiles,omitempty"`
+	HostLimits                     []*HostLimit           `protobuf:"bytes,23,rep,name=host_limits,json=hostLimits,proto3" json:"host_limits,omitempty"`
+	RetryPolicies                  *RetryPolicies         `protobuf:"bytes,24,opt,name=retry_policies,json=retryPolicies,proto3" json:"retry_policies,omitempty"`
+	StartupLatency                 *StartupLatency        `protobuf:"bytes,25,opt,name=startup_latency,json=startupLatency,proto3" json:"startup_latency,omitempty"`
+	SpeedThreshold                 *SpeedThreshold        `protobuf:"bytes,26,opt,name=speed_threshold,json=speedThreshold,proto3" json:"speed_threshold,omitempty"`
+	SlateDetection                 *SlateDetection        `protobuf:"bytes,27,opt,name=slate_detection,json=slateDetection,proto3" json:"slate_detection,omitempty"`
 	unknownFields                  protoimpl.UnknownFields
 	sizeCache                      protoimpl.SizeCache
 }
@@ -137,29 +154,1073 @@ func (x *Config) GetHostCustomUA() []string {
 	return nil
 }
 
+func (x *Config) GetOutputs() []*Output {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+func (x *Config) GetUpdateTimeChannel() *UpdateTimeChannel {
+	if x != nil {
+		return x.UpdateTimeChannel
+	}
+	return nil
+}
+
+func (x *Config) GetSourceStatsFile() string {
+	if x != nil {
+		return x.SourceStatsFile
+	}
+	return ""
+}
+
+func (x *Config) GetSourcePriorities() []*SourcePriority {
+	if x != nil {
+		return x.SourcePriorities
+	}
+	return nil
+}
+
+func (x *Config) GetIgnoredQueryParams() []*IgnoredQueryParams {
+	if x != nil {
+		return x.IgnoredQueryParams
+	}
+	return nil
+}
+
+func (x *Config) GetLineLabels() *LineLabels {
+	if x != nil {
+		return x.LineLabels
+	}
+	return nil
+}
+
+func (x *Config) GetXtreamSources() []*XtreamSource {
+	if x != nil {
+		return x.XtreamSources
+	}
+	return nil
+}
+
+func (x *Config) GetMaxLineLength() int64 {
+	if x != nil {
+		return x.MaxLineLength
+	}
+	return 0
+}
+
+func (x *Config) GetSourceMirrorDir() string {
+	if x != nil {
+		return x.SourceMirrorDir
+	}
+	return ""
+}
+
+func (x *Config) GetUrlRewriteRules() []*UrlRewriteRule {
+	if x != nil {
+		return x.UrlRewriteRules
+	}
+	return nil
+}
+
+func (x *Config) GetNetwork() *Network {
+	if x != nil {
+		return x.Network
+	}
+	return nil
+}
+
+func (x *Config) GetHeaderProfiles() []*HeaderProfile {
+	if x != nil {
+		return x.HeaderProfiles
+	}
+	return nil
+}
+
+func (x *Config) GetHostLimits() []*HostLimit {
+	if x != nil {
+		return x.HostLimits
+	}
+	return nil
+}
+
+func (x *Config) GetRetryPolicies() *RetryPolicies {
+	if x != nil {
+		return x.RetryPolicies
+	}
+	return nil
+}
+
+func (x *Config) GetStartupLatency() *StartupLatency {
+	if x != nil {
+		return x.StartupLatency
+	}
+	return nil
+}
+
+func (x *Config) GetSpeedThreshold() *SpeedThreshold {
+	if x != nil {
+		return x.SpeedThreshold
+	}
+	return nil
+}
+
+func (x *Config) GetSlateDetection() *SlateDetection {
+	if x != nil {
+		return x.SlateDetection
+	}
+	return nil
+}
+
 type GroupList struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Group            string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	TvgName          []string               `protobuf:"bytes,2,rep,name=tvg_name,json=tvgName,proto3" json:"tvg_name,omitempty"`
+	MinResolution    string                 `protobuf:"bytes,3,opt,name=min_resolution,json=minResolution,proto3" json:"min_resolution,omitempty"`
+	TestLoadMinSpeed int64                  `protobuf:"varint,4,opt,name=test_load_min_speed,json=testLoadMinSpeed,proto3" json:"test_load_min_speed,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *GroupList) Reset() {
+	*x = GroupList{}
+	mi := &file_config_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupList) ProtoMessage() {}
+
+func (x *GroupList) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupList.ProtoReflect.Descriptor instead.
+func (*GroupList) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GroupList) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *GroupList) GetTvgName() []string {
+	if x != nil {
+		return x.TvgName
+	}
+	return nil
+}
+
+func (x *GroupList) GetMinResolution() string {
+	if x != nil {
+		return x.MinResolution
+	}
+	return ""
+}
+
+func (x *GroupList) GetTestLoadMinSpeed() int64 {
+	if x != nil {
+		return x.TestLoadMinSpeed
+	}
+	return 0
+}
+
+type Output struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	File              string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Format            string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"`
+	Groups            []string               `protobuf:"bytes,3,rep,name=groups,proto3" json:"groups,omitempty"`
+	MaxUrlsPerChannel int64                  `protobuf:"varint,4,opt,name=max_urls_per_channel,json=maxUrlsPerChannel,proto3" json:"max_urls_per_channel,omitempty"`
+	IpPreference      string                 `protobuf:"bytes,5,opt,name=ip_preference,json=ipPreference,proto3" json:"ip_preference,omitempty"`
+	SourceAttribute   bool                   `protobuf:"varint,6,opt,name=source_attribute,json=sourceAttribute,proto3" json:"source_attribute,omitempty"`
+	HeaderFormat      string                 `protobuf:"bytes,7,opt,name=header_format,json=headerFormat,proto3" json:"header_format,omitempty"`
+	Resolution        string                 `protobuf:"bytes,8,opt,name=resolution,proto3" json:"resolution,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Output) Reset() {
+	*x = Output{}
+	mi := &file_config_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Output) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Output) ProtoMessage() {}
+
+func (x *Output) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Output.ProtoReflect.Descriptor instead.
+func (*Output) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Output) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Output) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Output) GetGroups() []string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *Output) GetMaxUrlsPerChannel() int64 {
+	if x != nil {
+		return x.MaxUrlsPerChannel
+	}
+	return 0
+}
+
+func (x *Output) GetIpPreference() string {
+	if x != nil {
+		return x.IpPreference
+	}
+	return ""
+}
+
+func (x *Output) GetSourceAttribute() bool {
+	if x != nil {
+		return x.SourceAttribute
+	}
+	return false
+}
+
+func (x *Output) GetHeaderFormat() string {
+	if x != nil {
+		return x.HeaderFormat
+	}
+	return ""
+}
+
+func (x *Output) GetResolution() string {
+	if x != nil {
+		return x.Resolution
+	}
+	return ""
+}
+
+type UpdateTimeChannel struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Enable             bool                   `protobuf:"varint,1,opt,name=enable,proto3" json:"enable,omitempty"`
+	Group              string                 `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	TimeFormat         string                 `protobuf:"bytes,3,opt,name=time_format,json=timeFormat,proto3" json:"time_format,omitempty"`
+	Timezone           string                 `protobuf:"bytes,4,opt,name=timezone,proto3" json:"timezone,omitempty"`
+	Url                string                 `protobuf:"bytes,5,opt,name=url,proto3" json:"url,omitempty"`
+	Logo               string                 `protobuf:"bytes,6,opt,name=logo,proto3" json:"logo,omitempty"`
+	PlaceholderFile    string                 `protobuf:"bytes,7,opt,name=placeholder_file,json=placeholderFile,proto3" json:"placeholder_file,omitempty"`
+	Summary            bool                   `protobuf:"varint,8,opt,name=summary,proto3" json:"summary,omitempty"`
+	PlaceholderBaseUrl string                 `protobuf:"bytes,9,opt,name=placeholder_base_url,json=placeholderBaseUrl,proto3" json:"placeholder_base_url,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *UpdateTimeChannel) Reset() {
+	*x = UpdateTimeChannel{}
+	mi := &file_config_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateTimeChannel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateTimeChannel) ProtoMessage() {}
+
+func (x *UpdateTimeChannel) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateTimeChannel.ProtoReflect.Descriptor instead.
+func (*UpdateTimeChannel) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateTimeChannel) GetEnable() bool {
+	if x != nil {
+		return x.Enable
+	}
+	return false
+}
+
+func (x *UpdateTimeChannel) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetTimeFormat() string {
+	if x != nil {
+		return x.TimeFormat
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetTimezone() string {
+	if x != nil {
+		return x.Timezone
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetLogo() string {
+	if x != nil {
+		return x.Logo
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetPlaceholderFile() string {
+	if x != nil {
+		return x.PlaceholderFile
+	}
+	return ""
+}
+
+func (x *UpdateTimeChannel) GetSummary() bool {
+	if x != nil {
+		return x.Summary
+	}
+	return false
+}
+
+func (x *UpdateTimeChannel) GetPlaceholderBaseUrl() string {
+	if x != nil {
+		return x.PlaceholderBaseUrl
+	}
+	return ""
+}
+
+type SourcePriority struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Priority      int64                  `protobuf:"varint,2,opt,name=priority,proto3" json:"priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourcePriority) Reset() {
+	*x = SourcePriority{}
+	mi := &file_config_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourcePriority) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourcePriority) ProtoMessage() {}
+
+func (x *SourcePriority) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourcePriority.ProtoReflect.Descriptor instead.
+func (*SourcePriority) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SourcePriority) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SourcePriority) GetPriority() int64 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+type IgnoredQueryParams struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Params        []string               `protobuf:"bytes,2,rep,name=params,proto3" json:"params,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IgnoredQueryParams) Reset() {
+	*x = IgnoredQueryParams{}
+	mi := &file_config_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IgnoredQueryParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IgnoredQueryParams) ProtoMessage() {}
+
+func (x *IgnoredQueryParams) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IgnoredQueryParams.ProtoReflect.Descriptor instead.
+func (*IgnoredQueryParams) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *IgnoredQueryParams) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *IgnoredQueryParams) GetParams() []string {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+type LineLabels struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Include       []string               `protobuf:"bytes,1,rep,name=include,proto3" json:"include,omitempty"`
+	Exclude       []string               `protobuf:"bytes,2,rep,name=exclude,proto3" json:"exclude,omitempty"`
+	Prefer        []string               `protobuf:"bytes,3,rep,name=prefer,proto3" json:"prefer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LineLabels) Reset() {
+	*x = LineLabels{}
+	mi := &file_config_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LineLabels) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LineLabels) ProtoMessage() {}
+
+func (x *LineLabels) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LineLabels.ProtoReflect.Descriptor instead.
+func (*LineLabels) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LineLabels) GetInclude() []string {
+	if x != nil {
+		return x.Include
+	}
+	return nil
+}
+
+func (x *LineLabels) GetExclude() []string {
+	if x != nil {
+		return x.Exclude
+	}
+	return nil
+}
+
+func (x *LineLabels) GetPrefer() []string {
+	if x != nil {
+		return x.Prefer
+	}
+	return nil
+}
+
+type XtreamSource struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Server        string                 `protobuf:"bytes,1,opt,name=server,proto3" json:"server,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	Output        string                 `protobuf:"bytes,4,opt,name=output,proto3" json:"output,omitempty"`
+	Priority      int64                  `protobuf:"varint,5,opt,name=priority,proto3" json:"priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *XtreamSource) Reset() {
+	*x = XtreamSource{}
+	mi := &file_config_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *XtreamSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*XtreamSource) ProtoMessage() {}
+
+func (x *XtreamSource) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use XtreamSource.ProtoReflect.Descriptor instead.
+func (*XtreamSource) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *XtreamSource) GetServer() string {
+	if x != nil {
+		return x.Server
+	}
+	return ""
+}
+
+func (x *XtreamSource) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *XtreamSource) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *XtreamSource) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *XtreamSource) GetPriority() int64 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+type UrlRewriteRule struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Pattern       string                 `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Templates     []string               `protobuf:"bytes,3,rep,name=templates,proto3" json:"templates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UrlRewriteRule) Reset() {
+	*x = UrlRewriteRule{}
+	mi := &file_config_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UrlRewriteRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UrlRewriteRule) ProtoMessage() {}
+
+func (x *UrlRewriteRule) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UrlRewriteRule.ProtoReflect.Descriptor instead.
+func (*UrlRewriteRule) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UrlRewriteRule) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *UrlRewriteRule) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *UrlRewriteRule) GetTemplates() []string {
+	if x != nil {
+		return x.Templates
+	}
+	return nil
+}
+
+type Network struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fetch         *NetworkProfile        `protobuf:"bytes,1,opt,name=fetch,proto3" json:"fetch,omitempty"`
+	Test          *NetworkProfile        `protobuf:"bytes,2,opt,name=test,proto3" json:"test,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Network) Reset() {
+	*x = Network{}
+	mi := &file_config_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Network) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Network) ProtoMessage() {}
+
+func (x *Network) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Network.ProtoReflect.Descriptor instead.
+func (*Network) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Network) GetFetch() *NetworkProfile {
+	if x != nil {
+		return x.Fetch
+	}
+	return nil
+}
+
+func (x *Network) GetTest() *NetworkProfile {
+	if x != nil {
+		return x.Test
+	}
+	return nil
+}
+
+type NetworkProfile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Proxy         string                 `protobuf:"bytes,1,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	HostProxies   []*HostProxy           `protobuf:"bytes,2,rep,name=host_proxies,json=hostProxies,proto3" json:"host_proxies,omitempty"`
+	SourceIp      string                 `protobuf:"bytes,3,opt,name=source_ip,json=sourceIp,proto3" json:"source_ip,omitempty"`
+	Interface     string                 `protobuf:"bytes,4,opt,name=interface,proto3" json:"interface,omitempty"`
+	Timeouts      *Timeouts              `protobuf:"bytes,5,opt,name=timeouts,proto3" json:"timeouts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkProfile) Reset() {
+	*x = NetworkProfile{}
+	mi := &file_config_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkProfile) ProtoMessage() {}
+
+func (x *NetworkProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkProfile.ProtoReflect.Descriptor instead.
+func (*NetworkProfile) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *NetworkProfile) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+func (x *NetworkProfile) GetHostProxies() []*HostProxy {
+	if x != nil {
+		return x.HostProxies
+	}
+	return nil
+}
+
+func (x *NetworkProfile) GetSourceIp() string {
+	if x != nil {
+		return x.SourceIp
+	}
+	return ""
+}
+
+func (x *NetworkProfile) GetInterface() string {
+	if x != nil {
+		return x.Interface
+	}
+	return ""
+}
+
+func (x *NetworkProfile) GetTimeouts() *Timeouts {
+	if x != nil {
+		return x.Timeouts
+	}
+	return nil
+}
+
+type HostProxy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	Proxy         string                 `protobuf:"bytes,2,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HostProxy) Reset() {
+	*x = HostProxy{}
+	mi := &file_config_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostProxy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostProxy) ProtoMessage() {}
+
+func (x *HostProxy) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostProxy.ProtoReflect.Descriptor instead.
+func (*HostProxy) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *HostProxy) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *HostProxy) GetProxy() string {
+	if x != nil {
+		return x.Proxy
+	}
+	return ""
+}
+
+type HeaderProfile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Host          string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	UserAgent     string                 `protobuf:"bytes,2,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	Referer       string                 `protobuf:"bytes,3,opt,name=referer,proto3" json:"referer,omitempty"`
+	Origin        string                 `protobuf:"bytes,4,opt,name=origin,proto3" json:"origin,omitempty"`
+	Headers       []string               `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty"`
+	Cookie        string                 `protobuf:"bytes,6,opt,name=cookie,proto3" json:"cookie,omitempty"`
+	Username      string                 `protobuf:"bytes,7,opt,name=username,proto3" json:"username,omitempty"`
+	Password      string                 `protobuf:"bytes,8,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HeaderProfile) Reset() {
+	*x = HeaderProfile{}
+	mi := &file_config_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HeaderProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HeaderProfile) ProtoMessage() {}
+
+func (x *HeaderProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HeaderProfile.ProtoReflect.Descriptor instead.
+func (*HeaderProfile) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *HeaderProfile) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetReferer() string {
+	if x != nil {
+		return x.Referer
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetOrigin() string {
+	if x != nil {
+		return x.Origin
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetHeaders() []string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *HeaderProfile) GetCookie() string {
+	if x != nil {
+		return x.Cookie
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *HeaderProfile) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type HostLimit struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Host              string                 `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	MaxConcurrency    int64                  `protobuf:"varint,2,opt,name=max_concurrency,json=maxConcurrency,proto3" json:"max_concurrency,omitempty"`
+	RequestsPerSecond float64                `protobuf:"fixed64,3,opt,name=requests_per_second,json=requestsPerSecond,proto3" json:"requests_per_second,omitempty"`
+	MaxBackoffSeconds int64                  `protobuf:"varint,4,opt,name=max_backoff_seconds,json=maxBackoffSeconds,proto3" json:"max_backoff_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *HostLimit) Reset() {
+	*x = HostLimit{}
+	mi := &file_config_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostLimit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostLimit) ProtoMessage() {}
+
+func (x *HostLimit) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostLimit.ProtoReflect.Descriptor instead.
+func (*HostLimit) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *HostLimit) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *HostLimit) GetMaxConcurrency() int64 {
+	if x != nil {
+		return x.MaxConcurrency
+	}
+	return 0
+}
+
+func (x *HostLimit) GetRequestsPerSecond() float64 {
+	if x != nil {
+		return x.RequestsPerSecond
+	}
+	return 0
+}
+
+func (x *HostLimit) GetMaxBackoffSeconds() int64 {
+	if x != nil {
+		return x.MaxBackoffSeconds
+	}
+	return 0
+}
+
+type RetryPolicies struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Group         string                 `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
-	TvgName       []string               `protobuf:"bytes,2,rep,name=tvg_name,json=tvgName,proto3" json:"tvg_name,omitempty"`
+	Source        *RetryPolicy           `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Playlist      *RetryPolicy           `protobuf:"bytes,2,opt,name=playlist,proto3" json:"playlist,omitempty"`
+	Segment       *RetryPolicy           `protobuf:"bytes,3,opt,name=segment,proto3" json:"segment,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *GroupList) Reset() {
-	*x = GroupList{}
-	mi := &file_config_proto_msgTypes[1]
+func (x *RetryPolicies) Reset() {
+	*x = RetryPolicies{}
+	mi := &file_config_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GroupList) String() string {
+func (x *RetryPolicies) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GroupList) ProtoMessage() {}
+func (*RetryPolicies) ProtoMessage() {}
 
-func (x *GroupList) ProtoReflect() protoreflect.Message {
-	mi := &file_config_proto_msgTypes[1]
+func (x *RetryPolicies) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -170,30 +1231,401 @@ func (x *GroupList) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GroupList.ProtoReflect.Descriptor instead.
-func (*GroupList) Descriptor() ([]byte, []int) {
-	return file_config_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use RetryPolicies.ProtoReflect.Descriptor instead.
+func (*RetryPolicies) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *GroupList) GetGroup() string {
+func (x *RetryPolicies) GetSource() *RetryPolicy {
 	if x != nil {
-		return x.Group
+		return x.Source
+	}
+	return nil
+}
+
+func (x *RetryPolicies) GetPlaylist() *RetryPolicy {
+	if x != nil {
+		return x.Playlist
+	}
+	return nil
+}
+
+func (x *RetryPolicies) GetSegment() *RetryPolicy {
+	if x != nil {
+		return x.Segment
+	}
+	return nil
+}
+
+type RetryPolicy struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RetryTimes    int64                  `protobuf:"varint,1,opt,name=retry_times,json=retryTimes,proto3" json:"retry_times,omitempty"`
+	BaseDelayMs   int64                  `protobuf:"varint,2,opt,name=base_delay_ms,json=baseDelayMs,proto3" json:"base_delay_ms,omitempty"`
+	MaxDelayMs    int64                  `protobuf:"varint,3,opt,name=max_delay_ms,json=maxDelayMs,proto3" json:"max_delay_ms,omitempty"`
+	BudgetRatio   float64                `protobuf:"fixed64,4,opt,name=budget_ratio,json=budgetRatio,proto3" json:"budget_ratio,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RetryPolicy) Reset() {
+	*x = RetryPolicy{}
+	mi := &file_config_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetryPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryPolicy) ProtoMessage() {}
+
+func (x *RetryPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryPolicy.ProtoReflect.Descriptor instead.
+func (*RetryPolicy) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RetryPolicy) GetRetryTimes() int64 {
+	if x != nil {
+		return x.RetryTimes
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetBaseDelayMs() int64 {
+	if x != nil {
+		return x.BaseDelayMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetMaxDelayMs() int64 {
+	if x != nil {
+		return x.MaxDelayMs
+	}
+	return 0
+}
+
+func (x *RetryPolicy) GetBudgetRatio() float64 {
+	if x != nil {
+		return x.BudgetRatio
+	}
+	return 0
+}
+
+type Timeouts struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	DialMs              int64                  `protobuf:"varint,1,opt,name=dial_ms,json=dialMs,proto3" json:"dial_ms,omitempty"`
+	TlsHandshakeMs      int64                  `protobuf:"varint,2,opt,name=tls_handshake_ms,json=tlsHandshakeMs,proto3" json:"tls_handshake_ms,omitempty"`
+	ResponseHeaderMs    int64                  `protobuf:"varint,3,opt,name=response_header_ms,json=responseHeaderMs,proto3" json:"response_header_ms,omitempty"`
+	FirstByteMs         int64                  `protobuf:"varint,4,opt,name=first_byte_ms,json=firstByteMs,proto3" json:"first_byte_ms,omitempty"`
+	RequestMs           int64                  `protobuf:"varint,5,opt,name=request_ms,json=requestMs,proto3" json:"request_ms,omitempty"`
+	TestDurationMs      int64                  `protobuf:"varint,6,opt,name=test_duration_ms,json=testDurationMs,proto3" json:"test_duration_ms,omitempty"`
+	SpeedTestDurationMs int64                  `protobuf:"varint,7,opt,name=speed_test_duration_ms,json=speedTestDurationMs,proto3" json:"speed_test_duration_ms,omitempty"`
+	IdleReadMs          int64                  `protobuf:"varint,8,opt,name=idle_read_ms,json=idleReadMs,proto3" json:"idle_read_ms,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Timeouts) Reset() {
+	*x = Timeouts{}
+	mi := &file_config_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Timeouts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Timeouts) ProtoMessage() {}
+
+func (x *Timeouts) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Timeouts.ProtoReflect.Descriptor instead.
+func (*Timeouts) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Timeouts) GetDialMs() int64 {
+	if x != nil {
+		return x.DialMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetTlsHandshakeMs() int64 {
+	if x != nil {
+		return x.TlsHandshakeMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetResponseHeaderMs() int64 {
+	if x != nil {
+		return x.ResponseHeaderMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetFirstByteMs() int64 {
+	if x != nil {
+		return x.FirstByteMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetRequestMs() int64 {
+	if x != nil {
+		return x.RequestMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetTestDurationMs() int64 {
+	if x != nil {
+		return x.TestDurationMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetSpeedTestDurationMs() int64 {
+	if x != nil {
+		return x.SpeedTestDurationMs
+	}
+	return 0
+}
+
+func (x *Timeouts) GetIdleReadMs() int64 {
+	if x != nil {
+		return x.IdleReadMs
+	}
+	return 0
+}
+
+type StartupLatency struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	MaxMs           int64                  `protobuf:"varint,1,opt,name=max_ms,json=maxMs,proto3" json:"max_ms,omitempty"`
+	MaxFirstFrameMs int64                  `protobuf:"varint,2,opt,name=max_first_frame_ms,json=maxFirstFrameMs,proto3" json:"max_first_frame_ms,omitempty"`
+	RankBucketMs    int64                  `protobuf:"varint,3,opt,name=rank_bucket_ms,json=rankBucketMs,proto3" json:"rank_bucket_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StartupLatency) Reset() {
+	*x = StartupLatency{}
+	mi := &file_config_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartupLatency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartupLatency) ProtoMessage() {}
+
+func (x *StartupLatency) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartupLatency.ProtoReflect.Descriptor instead.
+func (*StartupLatency) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *StartupLatency) GetMaxMs() int64 {
+	if x != nil {
+		return x.MaxMs
+	}
+	return 0
+}
+
+func (x *StartupLatency) GetMaxFirstFrameMs() int64 {
+	if x != nil {
+		return x.MaxFirstFrameMs
+	}
+	return 0
+}
+
+func (x *StartupLatency) GetRankBucketMs() int64 {
+	if x != nil {
+		return x.RankBucketMs
+	}
+	return 0
+}
+
+type SpeedThreshold struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Mode          string                 `protobuf:"bytes,1,opt,name=mode,proto3" json:"mode,omitempty"`
+	Headroom      float64                `protobuf:"fixed64,2,opt,name=headroom,proto3" json:"headroom,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SpeedThreshold) Reset() {
+	*x = SpeedThreshold{}
+	mi := &file_config_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SpeedThreshold) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SpeedThreshold) ProtoMessage() {}
+
+func (x *SpeedThreshold) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SpeedThreshold.ProtoReflect.Descriptor instead.
+func (*SpeedThreshold) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *SpeedThreshold) GetMode() string {
+	if x != nil {
+		return x.Mode
 	}
 	return ""
 }
 
-func (x *GroupList) GetTvgName() []string {
+func (x *SpeedThreshold) GetHeadroom() float64 {
 	if x != nil {
-		return x.TvgName
+		return x.Headroom
+	}
+	return 0
+}
+
+type SlateDetection struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Enable           bool                   `protobuf:"varint,1,opt,name=enable,proto3" json:"enable,omitempty"`
+	Fingerprints     []string               `protobuf:"bytes,2,rep,name=fingerprints,proto3" json:"fingerprints,omitempty"`
+	TinySegmentBytes int64                  `protobuf:"varint,3,opt,name=tiny_segment_bytes,json=tinySegmentBytes,proto3" json:"tiny_segment_bytes,omitempty"`
+	CrossChannelMin  int64                  `protobuf:"varint,4,opt,name=cross_channel_min,json=crossChannelMin,proto3" json:"cross_channel_min,omitempty"`
+	ReloadDelayMs    int64                  `protobuf:"varint,5,opt,name=reload_delay_ms,json=reloadDelayMs,proto3" json:"reload_delay_ms,omitempty"`
+	ReportFile       string                 `protobuf:"bytes,6,opt,name=report_file,json=reportFile,proto3" json:"report_file,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *SlateDetection) Reset() {
+	*x = SlateDetection{}
+	mi := &file_config_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SlateDetection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SlateDetection) ProtoMessage() {}
+
+func (x *SlateDetection) ProtoReflect() protoreflect.Message {
+	mi := &file_config_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SlateDetection.ProtoReflect.Descriptor instead.
+func (*SlateDetection) Descriptor() ([]byte, []int) {
+	return file_config_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *SlateDetection) GetEnable() bool {
+	if x != nil {
+		return x.Enable
+	}
+	return false
+}
+
+func (x *SlateDetection) GetFingerprints() []string {
+	if x != nil {
+		return x.Fingerprints
 	}
 	return nil
 }
 
+func (x *SlateDetection) GetTinySegmentBytes() int64 {
+	if x != nil {
+		return x.TinySegmentBytes
+	}
+	return 0
+}
+
+func (x *SlateDetection) GetCrossChannelMin() int64 {
+	if x != nil {
+		return x.CrossChannelMin
+	}
+	return 0
+}
+
+func (x *SlateDetection) GetReloadDelayMs() int64 {
+	if x != nil {
+		return x.ReloadDelayMs
+	}
+	return 0
+}
+
+func (x *SlateDetection) GetReportFile() string {
+	if x != nil {
+		return x.ReportFile
+	}
+	return ""
+}
+
 var File_config_proto protoreflect.FileDescriptor
 
 const file_config_proto_rawDesc = "" +
 	"\n" +
-	"\fconfig.proto\x12\x1eRainbowIPTVSourceFilter.config\"\xf5\x03\n" +
+	"\fconfig.proto\x12\x1eRainbowIPTVSourceFilter.config\"\xa3\x0e\n" +
 	"\x06Config\x127\n" +
 	"\x18program_list_source_urls\x18\x01 \x03(\tR\x15programListSourceUrls\x12K\n" +
 	"#program_list_source_file_local_path\x18\x02 \x01(\tR\x1eprogramListSourceFileLocalPath\x12\x1f\n" +
@@ -209,10 +1641,138 @@ const file_config_proto_rawDesc = "" +
 	"custom_u_a\x18\b \x01(\tR\bcustomUA\x122\n" +
 	"\x15parallel_executor_num\x18\t \x01(\x03R\x13parallelExecutorNum\x12%\n" +
 	"\x0fhost_custom_u_a\x18\n" +
-	" \x03(\tR\fhostCustomUA\"<\n" +
+	" \x03(\tR\fhostCustomUA\x12@\n" +
+	"\aoutputs\x18\v \x03(\v2&.RainbowIPTVSourceFilter.config.OutputR\aoutputs\x12a\n" +
+	"\x13update_time_channel\x18\f \x01(\v21.RainbowIPTVSourceFilter.config.UpdateTimeChannelR\x11updateTimeChannel\x12*\n" +
+	"\x11source_stats_file\x18\r \x01(\tR\x0fsourceStatsFile\x12[\n" +
+	"\x11source_priorities\x18\x0e \x03(\v2..RainbowIPTVSourceFilter.config.SourcePriorityR\x10sourcePriorities\x12d\n" +
+	"\x14ignored_query_params\x18\x0f \x03(\v22.RainbowIPTVSourceFilter.config.IgnoredQueryParamsR\x12ignoredQueryParams\x12K\n" +
+	"\vline_labels\x18\x10 \x01(\v2*.RainbowIPTVSourceFilter.config.LineLabelsR\n" +
+	"lineLabels\x12S\n" +
+	"\x0extream_sources\x18\x11 \x03(\v2,.RainbowIPTVSourceFilter.config.XtreamSourceR\rxtreamSources\x12&\n" +
+	"\x0fmax_line_length\x18\x12 \x01(\x03R\rmaxLineLength\x12*\n" +
+	"\x11source_mirror_dir\x18\x13 \x01(\tR\x0fsourceMirrorDir\x12Z\n" +
+	"\x11url_rewrite_rules\x18\x14 \x03(\v2..RainbowIPTVSourceFilter.config.UrlRewriteRuleR\x0furlRewriteRules\x12A\n" +
+	"\anetwork\x18\x15 \x01(\v2'.RainbowIPTVSourceFilter.config.NetworkR\anetwork\x12V\n" +
+	"\x0fheader_profiles\x18\x16 \x03(\v2-.RainbowIPTVSourceFilter.config.HeaderProfileR\x0eheaderProfiles\x12J\n" +
+	"\vhost_limits\x18\x17 \x03(\v2).RainbowIPTVSourceFilter.config.HostLimitR\n" +
+	"hostLimits\x12T\n" +
+	"\x0eretry_policies\x18\x18 \x01(\v2-.RainbowIPTVSourceFilter.config.RetryPoliciesR\rretryPolicies\x12W\n" +
+	"\x0fstartup_latency\x18\x19 \x01(\v2..RainbowIPTVSourceFilter.config.StartupLatencyR\x0estartupLatency\x12W\n" +
+	"\x0fspeed_threshold\x18\x1a \x01(\v2..RainbowIPTVSourceFilter.config.SpeedThresholdR\x0espeedThreshold\x12W\n" +
+	"\x0fslate_detection\x18\x1b \x01(\v2..RainbowIPTVSourceFilter.config.SlateDetectionR\x0eslateDetection\"\x92\x01\n" +
 	"\tGroupList\x12\x14\n" +
 	"\x05group\x18\x01 \x01(\tR\x05group\x12\x19\n" +
-	"\btvg_name\x18\x02 \x03(\tR\atvgNameB9Z7github.com/ramboll/rainbow-iptv-source-filter/pkg/protob\x06proto3"
+	"\btvg_name\x18\x02 \x03(\tR\atvgName\x12%\n" +
+	"\x0emin_resolution\x18\x03 \x01(\tR\rminResolution\x12-\n" +
+	"\x13test_load_min_speed\x18\x04 \x01(\x03R\x10testLoadMinSpeed\"\x92\x02\n" +
+	"\x06Output\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x16\n" +
+	"\x06groups\x18\x03 \x03(\tR\x06groups\x12/\n" +
+	"\x14max_urls_per_channel\x18\x04 \x01(\x03R\x11maxUrlsPerChannel\x12#\n" +
+	"\rip_preference\x18\x05 \x01(\tR\fipPreference\x12)\n" +
+	"\x10source_attribute\x18\x06 \x01(\bR\x0fsourceAttribute\x12#\n" +
+	"\rheader_format\x18\a \x01(\tR\fheaderFormat\x12\x1e\n" +
+	"\n" +
+	"resolution\x18\b \x01(\tR\n" +
+	"resolution\"\x9b\x02\n" +
+	"\x11UpdateTimeChannel\x12\x16\n" +
+	"\x06enable\x18\x01 \x01(\bR\x06enable\x12\x14\n" +
+	"\x05group\x18\x02 \x01(\tR\x05group\x12\x1f\n" +
+	"\vtime_format\x18\x03 \x01(\tR\n" +
+	"timeFormat\x12\x1a\n" +
+	"\btimezone\x18\x04 \x01(\tR\btimezone\x12\x10\n" +
+	"\x03url\x18\x05 \x01(\tR\x03url\x12\x12\n" +
+	"\x04logo\x18\x06 \x01(\tR\x04logo\x12)\n" +
+	"\x10placeholder_file\x18\a \x01(\tR\x0fplaceholderFile\x12\x18\n" +
+	"\asummary\x18\b \x01(\bR\asummary\x120\n" +
+	"\x14placeholder_base_url\x18\t \x01(\tR\x12placeholderBaseUrl\"D\n" +
+	"\x0eSourcePriority\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1a\n" +
+	"\bpriority\x18\x02 \x01(\x03R\bpriority\"@\n" +
+	"\x12IgnoredQueryParams\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x16\n" +
+	"\x06params\x18\x02 \x03(\tR\x06params\"X\n" +
+	"\n" +
+	"LineLabels\x12\x18\n" +
+	"\ainclude\x18\x01 \x03(\tR\ainclude\x12\x18\n" +
+	"\aexclude\x18\x02 \x03(\tR\aexclude\x12\x16\n" +
+	"\x06prefer\x18\x03 \x03(\tR\x06prefer\"\x92\x01\n" +
+	"\fXtreamSource\x12\x16\n" +
+	"\x06server\x18\x01 \x01(\tR\x06server\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\x12\x16\n" +
+	"\x06output\x18\x04 \x01(\tR\x06output\x12\x1a\n" +
+	"\bpriority\x18\x05 \x01(\x03R\bpriority\"\\\n" +
+	"\x0eUrlRewriteRule\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\x12\x1c\n" +
+	"\ttemplates\x18\x03 \x03(\tR\ttemplates\"\x93\x01\n" +
+	"\aNetwork\x12D\n" +
+	"\x05fetch\x18\x01 \x01(\v2..RainbowIPTVSourceFilter.config.NetworkProfileR\x05fetch\x12B\n" +
+	"\x04test\x18\x02 \x01(\v2..RainbowIPTVSourceFilter.config.NetworkProfileR\x04test\"\xf5\x01\n" +
+	"\x0eNetworkProfile\x12\x14\n" +
+	"\x05proxy\x18\x01 \x01(\tR\x05proxy\x12L\n" +
+	"\fhost_proxies\x18\x02 \x03(\v2).RainbowIPTVSourceFilter.config.HostProxyR\vhostProxies\x12\x1b\n" +
+	"\tsource_ip\x18\x03 \x01(\tR\bsourceIp\x12\x1c\n" +
+	"\tinterface\x18\x04 \x01(\tR\tinterface\x12D\n" +
+	"\btimeouts\x18\x05 \x01(\v2(.RainbowIPTVSourceFilter.config.TimeoutsR\btimeouts\"5\n" +
+	"\tHostProxy\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x14\n" +
+	"\x05proxy\x18\x02 \x01(\tR\x05proxy\"\xde\x01\n" +
+	"\rHeaderProfile\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x02 \x01(\tR\tuserAgent\x12\x18\n" +
+	"\areferer\x18\x03 \x01(\tR\areferer\x12\x16\n" +
+	"\x06origin\x18\x04 \x01(\tR\x06origin\x12\x18\n" +
+	"\aheaders\x18\x05 \x03(\tR\aheaders\x12\x16\n" +
+	"\x06cookie\x18\x06 \x01(\tR\x06cookie\x12\x1a\n" +
+	"\busername\x18\a \x01(\tR\busername\x12\x1a\n" +
+	"\bpassword\x18\b \x01(\tR\bpassword\"\xa8\x01\n" +
+	"\tHostLimit\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12'\n" +
+	"\x0fmax_concurrency\x18\x02 \x01(\x03R\x0emaxConcurrency\x12.\n" +
+	"\x13requests_per_second\x18\x03 \x01(\x01R\x11requestsPerSecond\x12.\n" +
+	"\x13max_backoff_seconds\x18\x04 \x01(\x03R\x11maxBackoffSeconds\"\xe4\x01\n" +
+	"\rRetryPolicies\x12C\n" +
+	"\x06source\x18\x01 \x01(\v2+.RainbowIPTVSourceFilter.config.RetryPolicyR\x06source\x12G\n" +
+	"\bplaylist\x18\x02 \x01(\v2+.RainbowIPTVSourceFilter.config.RetryPolicyR\bplaylist\x12E\n" +
+	"\asegment\x18\x03 \x01(\v2+.RainbowIPTVSourceFilter.config.RetryPolicyR\asegment\"\x97\x01\n" +
+	"\vRetryPolicy\x12\x1f\n" +
+	"\vretry_times\x18\x01 \x01(\x03R\n" +
+	"retryTimes\x12\"\n" +
+	"\rbase_delay_ms\x18\x02 \x01(\x03R\vbaseDelayMs\x12 \n" +
+	"\fmax_delay_ms\x18\x03 \x01(\x03R\n" +
+	"maxDelayMs\x12!\n" +
+	"\fbudget_ratio\x18\x04 \x01(\x01R\vbudgetRatio\"\xbf\x02\n" +
+	"\bTimeouts\x12\x17\n" +
+	"\adial_ms\x18\x01 \x01(\x03R\x06dialMs\x12(\n" +
+	"\x10tls_handshake_ms\x18\x02 \x01(\x03R\x0etlsHandshakeMs\x12,\n" +
+	"\x12response_header_ms\x18\x03 \x01(\x03R\x10responseHeaderMs\x12\"\n" +
+	"\rfirst_byte_ms\x18\x04 \x01(\x03R\vfirstByteMs\x12\x1d\n" +
+	"\n" +
+	"request_ms\x18\x05 \x01(\x03R\trequestMs\x12(\n" +
+	"\x10test_duration_ms\x18\x06 \x01(\x03R\x0etestDurationMs\x123\n" +
+	"\x16speed_test_duration_ms\x18\a \x01(\x03R\x13speedTestDurationMs\x12 \n" +
+	"\fidle_read_ms\x18\b \x01(\x03R\n" +
+	"idleReadMs\"z\n" +
+	"\x0eStartupLatency\x12\x15\n" +
+	"\x06max_ms\x18\x01 \x01(\x03R\x05maxMs\x12+\n" +
+	"\x12max_first_frame_ms\x18\x02 \x01(\x03R\x0fmaxFirstFrameMs\x12$\n" +
+	"\x0erank_bucket_ms\x18\x03 \x01(\x03R\frankBucketMs\"@\n" +
+	"\x0eSpeedThreshold\x12\x12\n" +
+	"\x04mode\x18\x01 \x01(\tR\x04mode\x12\x1a\n" +
+	"\bheadroom\x18\x02 \x01(\x01R\bheadroom\"\xef\x01\n" +
+	"\x0eSlateDetection\x12\x16\n" +
+	"\x06enable\x18\x01 \x01(\bR\x06enable\x12\"\n" +
+	"\ffingerprints\x18\x02 \x03(\tR\ffingerprints\x12,\n" +
+	"\x12tiny_segment_bytes\x18\x03 \x01(\x03R\x10tinySegmentBytes\x12*\n" +
+	"\x11cross_channel_min\x18\x04 \x01(\x03R\x0fcrossChannelMin\x12&\n" +
+	"\x0freload_delay_ms\x18\x05 \x01(\x03R\rreloadDelayMs\x12\x1f\n" +
+	"\vreport_file\x18\x06 \x01(\tR\n" +
+	"reportFileB9Z7github.com/ramboll/rainbow-iptv-source-filter/pkg/protob\x06proto3"
 
 var (
 	file_config_proto_rawDescOnce sync.Once
@@ -226,18 +1786,57 @@ func file_config_proto_rawDescGZIP() []byte {
 	return file_config_proto_rawDescData
 }
 
-var file_config_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_config_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
 var file_config_proto_goTypes = []any{
-	(*Config)(nil),    // 0: RainbowIPTVSourceFilter.config.Config
-	(*GroupList)(nil), // 1: RainbowIPTVSourceFilter.config.GroupList
+	(*Config)(nil),             // 0: RainbowIPTVSourceFilter.config.Config
+	(*GroupList)(nil),          // 1: RainbowIPTVSourceFilter.config.GroupList
+	(*Output)(nil),             // 2: RainbowIPTVSourceFilter.config.Output
+	(*UpdateTimeChannel)(nil),  // 3: RainbowIPTVSourceFilter.config.UpdateTimeChannel
+	(*SourcePriority)(nil),     // 4: RainbowIPTVSourceFilter.config.SourcePriority
+	(*IgnoredQueryParams)(nil), // 5: RainbowIPTVSourceFilter.config.IgnoredQueryParams
+	(*LineLabels)(nil),         // 6: RainbowIPTVSourceFilter.config.LineLabels
+	(*XtreamSource)(nil),       // 7: RainbowIPTVSourceFilter.config.XtreamSource
+	(*UrlRewriteRule)(nil),     // 8: RainbowIPTVSourceFilter.config.UrlRewriteRule
+	(*Network)(nil),            // 9: RainbowIPTVSourceFilter.config.Network
+	(*NetworkProfile)(nil),     // 10: RainbowIPTVSourceFilter.config.NetworkProfile
+	(*HostProxy)(nil),          // 11: RainbowIPTVSourceFilter.config.HostProxy
+	(*HeaderProfile)(nil),      // 12: RainbowIPTVSourceFilter.config.HeaderProfile
+	(*HostLimit)(nil),          // 13: RainbowIPTVSourceFilter.config.HostLimit
+	(*RetryPolicies)(nil),      // 14: RainbowIPTVSourceFilter.config.RetryPolicies
+	(*RetryPolicy)(nil),        // 15: RainbowIPTVSourceFilter.config.RetryPolicy
+	(*Timeouts)(nil),           // 16: RainbowIPTVSourceFilter.config.Timeouts
+	(*StartupLatency)(nil),     // 17: RainbowIPTVSourceFilter.config.StartupLatency
+	(*SpeedThreshold)(nil),     // 18: RainbowIPTVSourceFilter.config.SpeedThreshold
+	(*SlateDetection)(nil),     // 19: RainbowIPTVSourceFilter.config.SlateDetection
 }
 var file_config_proto_depIdxs = []int32{
-	1, // 0: RainbowIPTVSourceFilter.config.Config.group_list:type_name -> RainbowIPTVSourceFilter.config.GroupList
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1,  // 0: RainbowIPTVSourceFilter.config.Config.group_list:type_name -> RainbowIPTVSourceFilter.config.GroupList
+	2,  // 1: RainbowIPTVSourceFilter.config.Config.outputs:type_name -> RainbowIPTVSourceFilter.config.Output
+	3,  // 2: RainbowIPTVSourceFilter.config.Config.update_time_channel:type_name -> RainbowIPTVSourceFilter.config.UpdateTimeChannel
+	4,  // 3: RainbowIPTVSourceFilter.config.Config.source_priorities:type_name -> RainbowIPTVSourceFilter.config.SourcePriority
+	5,  // 4: RainbowIPTVSourceFilter.config.Config.ignored_query_params:type_name -> RainbowIPTVSourceFilter.config.IgnoredQueryParams
+	6,  // 5: RainbowIPTVSourceFilter.config.Config.line_labels:type_name -> RainbowIPTVSourceFilter.config.LineLabels
+	7,  // 6: RainbowIPTVSourceFilter.config.Config.xtream_sources:type_name -> RainbowIPTVSourceFilter.config.XtreamSource
+	8,  // 7: RainbowIPTVSourceFilter.config.Config.url_rewrite_rules:type_name -> RainbowIPTVSourceFilter.config.UrlRewriteRule
+	9,  // 8: RainbowIPTVSourceFilter.config.Config.network:type_name -> RainbowIPTVSourceFilter.config.Network
+	12, // 9: RainbowIPTVSourceFilter.config.Config.header_profiles:type_name -> RainbowIPTVSourceFilter.config.HeaderProfile
+	13, // 10: RainbowIPTVSourceFilter.config.Config.host_limits:type_name -> RainbowIPTVSourceFilter.config.HostLimit
+	14, // 11: RainbowIPTVSourceFilter.config.Config.retry_policies:type_name -> RainbowIPTVSourceFilter.config.RetryPolicies
+	17, // 12: RainbowIPTVSourceFilter.config.Config.startup_latency:type_name -> RainbowIPTVSourceFilter.config.StartupLatency
+	18, // 13: RainbowIPTVSourceFilter.config.Config.speed_threshold:type_name -> RainbowIPTVSourceFilter.config.SpeedThreshold
+	19, // 14: RainbowIPTVSourceFilter.config.Config.slate_detection:type_name -> RainbowIPTVSourceFilter.config.SlateDetection
+	10, // 15: RainbowIPTVSourceFilter.config.Network.fetch:type_name -> RainbowIPTVSourceFilter.config.NetworkProfile
+	10, // 16: RainbowIPTVSourceFilter.config.Network.test:type_name -> RainbowIPTVSourceFilter.config.NetworkProfile
+	11, // 17: RainbowIPTVSourceFilter.config.NetworkProfile.host_proxies:type_name -> RainbowIPTVSourceFilter.config.HostProxy
+	16, // 18: RainbowIPTVSourceFilter.config.NetworkProfile.timeouts:type_name -> RainbowIPTVSourceFilter.config.Timeouts
+	15, // 19: RainbowIPTVSourceFilter.config.RetryPolicies.source:type_name -> RainbowIPTVSourceFilter.config.RetryPolicy
+	15, // 20: RainbowIPTVSourceFilter.config.RetryPolicies.playlist:type_name -> RainbowIPTVSourceFilter.config.RetryPolicy
+	15, // 21: RainbowIPTVSourceFilter.config.RetryPolicies.segment:type_name -> RainbowIPTVSourceFilter.config.RetryPolicy
+	22, // [22:22] is the sub-list for method output_type
+	22, // [22:22] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
 }
 
 func init() { file_config_proto_init() }
@@ -251,7 +1850,7 @@ func file_config_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_config_proto_rawDesc), len(file_config_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   20,
 			NumExtensions: 0,
 			NumServices:   0,
 		},